@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"github.com/bbeck/protohackers/internal"
+	"github.com/bbeck/protohackers/internal/decode"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const usage = `Usage: decode [flags] PROTOCOL [FILE]
+
+Decodes the messages in FILE, or standard input, and prints one per line,
+flagging checksum failures, truncated messages and unknown message types.
+
+PROTOCOL is one of %s.
+
+FILE is either a capture written by a server run with -capture-dir, in which
+case the messages from both sides are printed in the order they were sent, or
+hex sent by one side of a connection.  Whitespace in hex is ignored, as are
+lines starting with #.  For packet based protocols each line of hex is a
+separate packet.
+
+Flags:
+`
+
+func main() {
+	from := flag.String("from", "client", "who sent hex input: client or server")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), usage, strings.Join(decode.Names(), ", "))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 || flag.NArg() > 2 || (*from != "client" && *from != "server") {
+		flag.Usage()
+		os.Exit(2)
+	}
+	protocol := decode.Lookup(flag.Arg(0))
+	if protocol == nil {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	input := io.Reader(os.Stdin)
+	if flag.NArg() == 2 {
+		file, err := os.Open(flag.Arg(1))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	bs, err := io.ReadAll(input)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var entries []entry
+	if trimmed := bytes.TrimSpace(bs); len(trimmed) > 0 && trimmed[0] == '{' {
+		entries, err = decodeCapture(protocol, bs)
+	} else {
+		entries, err = decodeHex(protocol, bs, *from == "client")
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	issues := 0
+	for _, e := range entries {
+		e.print(os.Stdout)
+		if e.Issue != "" {
+			issues++
+		}
+	}
+	if issues > 0 {
+		fmt.Printf("%d of %d messages have issues\n", issues, len(entries))
+		os.Exit(1)
+	}
+}
+
+// entry is a decoded message along with who sent it and, for captures, when.
+type entry struct {
+	decode.Message
+	Time       time.Time
+	FromClient bool
+}
+
+func (e entry) print(w io.Writer) {
+	var when string
+	if !e.Time.IsZero() {
+		when = e.Time.Format("15:04:05.000000") + "  "
+	}
+
+	fmt.Fprintf(w, "%s%-6s  %6d  %s\n", when, sender(e.FromClient), e.Offset, e.Text)
+	if e.Issue != "" {
+		fmt.Fprintf(w, "        ! %s\n", e.Issue)
+		fmt.Fprintf(w, "        ! raw: % x\n", e.Raw)
+	}
+}
+
+func sender(fromClient bool) string {
+	if fromClient {
+		return "client"
+	}
+	return "server"
+}
+
+// decodeHex decodes hex sent by one side of a connection.
+func decodeHex(protocol *decode.Protocol, bs []byte, fromClient bool) ([]entry, error) {
+	var packets [][]byte
+	var stream []byte
+
+	scanner := bufio.NewScanner(bytes.NewReader(bs))
+	scanner.Buffer(nil, len(bs)+1)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		decoded, err := hex.DecodeString(strings.Join(strings.Fields(line), ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex on line %d: %w", n, err)
+		}
+		packets = append(packets, decoded)
+		stream = append(stream, decoded...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !protocol.Datagram {
+		packets = [][]byte{stream}
+	}
+
+	var entries []entry
+	offset := 0
+	for _, packet := range packets {
+		for _, message := range protocol.Decode(packet, fromClient) {
+			message.Offset += offset
+			entries = append(entries, entry{Message: message, FromClient: fromClient})
+		}
+		offset += len(packet)
+	}
+	return entries, nil
+}
+
+// decodeCapture decodes both sides of a captured connection.  Each message is
+// given the time of the frame its first byte arrived in.
+func decodeCapture(protocol *decode.Protocol, bs []byte) ([]entry, error) {
+	_, frames, err := internal.ReadCapture(bytes.NewReader(bs))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []entry
+	for _, fromClient := range []bool{true, false} {
+		direction := internal.CaptureOut
+		if fromClient {
+			direction = internal.CaptureIn
+		}
+
+		var stream []byte
+		var starts []int // The offset in the stream of each frame
+		var times []time.Time
+		for _, frame := range frames {
+			if frame.Direction != direction || frame.EOF {
+				continue
+			}
+
+			if protocol.Datagram {
+				for _, message := range protocol.Decode(frame.Data, fromClient) {
+					entries = append(entries, entry{Message: message, Time: frame.Time, FromClient: fromClient})
+				}
+				continue
+			}
+
+			starts = append(starts, len(stream))
+			times = append(times, frame.Time)
+			stream = append(stream, frame.Data...)
+		}
+
+		if protocol.Datagram {
+			continue
+		}
+		for _, message := range protocol.Decode(stream, fromClient) {
+			i := sort.Search(len(starts), func(i int) bool { return starts[i] > message.Offset }) - 1
+			entries = append(entries, entry{Message: message, Time: times[i], FromClient: fromClient})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries, nil
+}
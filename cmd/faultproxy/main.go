@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/bbeck/protohackers/internal"
+	"github.com/bbeck/protohackers/internal/faults"
+	"log"
+	"log/slog"
+	"os"
+)
+
+const usage = `Usage: faultproxy [flags] tcp|udp UPSTREAM
+
+Relays TCP connections or UDP datagrams to the UPSTREAM address, dropping,
+duplicating, delaying, reordering and truncating packets in both directions.
+The faults are chosen randomly from -seed, so a client that sends the same
+traffic through a proxy with the same flags sees the same faults.
+
+Flags:`
+
+func main() {
+	var profile faults.Profile
+	profile.RegisterFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+
+	config, err := internal.ParseConfig(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf("error parsing configuration: %v", err)
+	}
+	if err := profile.Validate(); err != nil {
+		log.Fatalf("error parsing configuration: %v", err)
+	}
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	slog.SetDefault(config.NewLogger(os.Stderr))
+
+	proxy := &faults.Proxy{Upstream: flag.Arg(1), Profile: profile}
+	serve := map[string]func(context.Context, *internal.Server) error{
+		"tcp": proxy.ServeTCP,
+		"udp": proxy.ServeUDP,
+	}[flag.Arg(0)]
+	if serve == nil {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := internal.SignalContext()
+	defer stop()
+
+	go func() {
+		if err := internal.ServeMetrics(ctx, config.MetricsAddress); err != nil {
+			slog.Error("error serving metrics", "error", err)
+		}
+	}()
+
+	server := &internal.Server{Config: config, Name: "faultproxy"}
+	if err := serve(ctx, server); err != nil {
+		log.Fatalf("error running proxy: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bbeck/protohackers/internal/client"
+	"math/rand"
+	"net"
+	"time"
+)
+
+// Jobs simulates workers that each put a job in one of the queues, get the
+// highest priority job from that queue and delete it, measuring the latency of
+// each request.
+func Jobs(ctx context.Context, options Options, stats *Stats) error {
+	runClients(ctx, options, func(ctx context.Context, id int) {
+		random := rand.New(rand.NewSource(options.Seed + int64(id)))
+		queue := fmt.Sprintf("queue-%d", id%options.Queues)
+		next := pace(ctx, options.Rate)
+
+		for ctx.Err() == nil {
+			conn, err := net.DialTimeout("tcp", options.Addr, options.Timeout)
+			if err != nil {
+				stats.Error("put", err)
+				backoff(ctx)
+				continue
+			}
+			stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+
+			jobs := client.NewJobsClient(conn)
+			for next() {
+				if err = jobsRound(jobs, conn, options, queue, random.Intn(100), stats); err != nil {
+					break
+				}
+			}
+
+			stop()
+			_ = conn.Close()
+		}
+	})
+
+	return nil
+}
+
+// jobsRound puts, gets and deletes a single job.
+func jobsRound(jobs *client.JobsClient, conn net.Conn, options Options, queue string, priority int, stats *Stats) error {
+	op := func(name string, f func() error) error {
+		_ = conn.SetDeadline(time.Now().Add(options.Timeout))
+
+		start := time.Now()
+		err := f()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				stats.Error(name, err)
+			}
+			return err
+		}
+
+		stats.Record(name, time.Since(start))
+		return nil
+	}
+
+	err := op("put", func() error {
+		_, err := jobs.Put(queue, priority, map[string]int{"priority": priority})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	var job *client.Job
+	err = op("get", func() error {
+		job, err = jobs.Get([]string{queue}, false)
+		return err
+	})
+	if err != nil || job == nil {
+		// Other workers on the same queue may have taken every job.
+		return err
+	}
+
+	return op("delete", func() error {
+		deleted, err := jobs.Delete(job.ID)
+		if err == nil && !deleted {
+			err = fmt.Errorf("job %d already deleted", job.ID)
+		}
+		return err
+	})
+}
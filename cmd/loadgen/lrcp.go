@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/bbeck/protohackers/internal/client"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// LRCP simulates sessions that each send a line of random length and wait for
+// it to come back reversed, measuring the round trip.  Packets in both
+// directions are dropped with the configured probability, so the latency
+// includes any retransmissions.
+func LRCP(ctx context.Context, options Options, stats *Stats) error {
+	runClients(ctx, options, func(ctx context.Context, id int) {
+		random := rand.New(rand.NewSource(options.Seed + int64(id)))
+		dialer := &client.LRCPDialer{
+			RetransmissionTimeout: options.Retransmit,
+			DialUDP: func(addr string) (net.Conn, error) {
+				conn, err := net.Dial("udp", addr)
+				if err != nil {
+					return nil, err
+				}
+				// The connection is used by the session's goroutines, so it gets its
+				// own source of randomness.
+				source := rand.NewSource(random.Int63())
+				return &lossyConn{Conn: conn, loss: options.Loss, random: rand.New(source)}, nil
+			},
+		}
+		next := pace(ctx, options.Rate)
+
+		for ctx.Err() == nil {
+			start := time.Now()
+			conn, err := dialer.Dial(options.Addr)
+			if err != nil {
+				stats.Error("connect", err)
+				backoff(ctx)
+				continue
+			}
+			stats.Record("connect", time.Since(start))
+
+			stop := context.AfterFunc(ctx, func() { _ = conn.SetReadDeadline(time.Now()) })
+			reader := bufio.NewReader(conn)
+			for next() {
+				if err = lrcpRound(ctx, conn, reader, random, options, stats); err != nil {
+					break
+				}
+			}
+			stop()
+
+			// Closing waits for outstanding data to be acknowledged, which may never
+			// happen after an error.
+			go conn.Close()
+		}
+	})
+
+	return nil
+}
+
+// lrcpRound sends a single line and waits for it to come back reversed.
+func lrcpRound(ctx context.Context, conn net.Conn, reader *bufio.Reader, random *rand.Rand, options Options, stats *Stats) error {
+	line := make([]byte, 1+random.Intn(options.LineLength))
+	for i := range line {
+		line[i] = byte('a' + random.Intn(26))
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(options.Timeout))
+
+	start := time.Now()
+	if _, err := conn.Write(append(line, '\n')); err != nil {
+		stats.Error("line", err)
+		return err
+	}
+
+	reversed, err := reader.ReadBytes('\n')
+	if err != nil {
+		if ctx.Err() == nil {
+			stats.Error("line", err)
+		}
+		return err
+	}
+
+	for i := range line {
+		if len(reversed) != len(line)+1 || reversed[i] != line[len(line)-1-i] {
+			err := fmt.Errorf("line %q reversed as %q", line, reversed)
+			stats.Error("line", err)
+			return err
+		}
+	}
+
+	stats.Record("line", time.Since(start))
+	return nil
+}
+
+// lossyConn is a datagram connection that drops packets in both directions
+// with probability loss.
+type lossyConn struct {
+	net.Conn
+	loss float64
+
+	mutex  sync.Mutex
+	random *rand.Rand
+}
+
+func (c *lossyConn) drop() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.random.Float64() < c.loss
+}
+
+func (c *lossyConn) Write(bs []byte) (int, error) {
+	if c.drop() {
+		return len(bs), nil
+	}
+	return c.Conn.Write(bs)
+}
+
+func (c *lossyConn) Read(bs []byte) (int, error) {
+	for {
+		n, err := c.Conn.Read(bs)
+		if err != nil || !c.drop() {
+			return n, err
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/bbeck/protohackers/internal"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const usage = `Usage: loadgen [flags] speed|jobs|lrcp
+
+Drives simulated clients against a running problem server and reports the
+throughput, latency percentiles and errors of each operation.
+
+  speed  cameras report speeding cars to a Speed Daemon server (problem 06),
+         the latency is how long it takes for the ticket to be dispatched
+  jobs   workers put, get and delete jobs on a Job Centre server (problem 09)
+  lrcp   sessions send lines to a Line Reversal server (problem 07) and wait
+         for them to come back reversed, optionally dropping packets
+
+Flags:`
+
+// Options are the settings shared by every scenario.
+type Options struct {
+	Addr     string
+	Clients  int
+	Duration time.Duration
+	Rate     float64 // Operations per second per client, 0 for unlimited
+	Timeout  time.Duration
+	Seed     int64
+
+	// Speed Daemon
+	Roads int
+
+	// Job Centre
+	Queues int
+
+	// Line Reversal
+	Loss       float64
+	Retransmit time.Duration
+	LineLength int
+}
+
+// Scenario generates load until ctx is cancelled, recording the outcome of
+// every operation in stats.
+type Scenario func(ctx context.Context, options Options, stats *Stats) error
+
+var scenarios = map[string]Scenario{
+	"speed": Speed,
+	"jobs":  Jobs,
+	"lrcp":  LRCP,
+}
+
+func main() {
+	var options Options
+	flag.StringVar(&options.Addr, "addr", "localhost:40000", "address of the server to load")
+	flag.IntVar(&options.Clients, "clients", 10, "number of concurrent clients")
+	flag.DurationVar(&options.Duration, "duration", 10*time.Second, "how long to generate load for")
+	flag.Float64Var(&options.Rate, "rate", 0, "operations per second per client, 0 for as fast as possible")
+	flag.DurationVar(&options.Timeout, "timeout", 10*time.Second, "how long to wait for a response before counting an error")
+	flag.Int64Var(&options.Seed, "seed", time.Now().UnixNano(), "seed for random choices, such as which packets to drop")
+	flag.IntVar(&options.Roads, "roads", 10, "number of roads the cameras are spread across (speed)")
+	flag.IntVar(&options.Queues, "queues", 10, "number of queues the workers are spread across (jobs)")
+	flag.Float64Var(&options.Loss, "loss", 0, "probability of dropping each packet in either direction (lrcp)")
+	flag.DurationVar(&options.Retransmit, "retransmit", 0, "client retransmission timeout, 0 for the protocol default (lrcp)")
+	flag.IntVar(&options.LineLength, "line-length", 100, "maximum length of each line (lrcp)")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	scenario := scenarios[flag.Arg(0)]
+	if flag.NArg() != 1 || scenario == nil {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if options.Clients < 1 {
+		log.Fatal("-clients must be at least 1")
+	}
+
+	ctx, stop := internal.SignalContext()
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(ctx, options.Duration)
+	defer cancel()
+
+	stats := NewStats()
+	start := time.Now()
+	if err := scenario(ctx, options, stats); err != nil {
+		log.Fatal(err)
+	}
+
+	// Scenarios may spend a while after the load stops waiting for the last
+	// responses, which shouldn't count against the throughput.
+	elapsed := time.Since(start)
+	if elapsed > options.Duration {
+		elapsed = options.Duration
+	}
+	stats.Report(os.Stdout, elapsed)
+}
+
+// runClients runs the client function once for each client concurrently and
+// waits for all of them to return.
+func runClients(ctx context.Context, options Options, client func(ctx context.Context, id int)) {
+	var wg sync.WaitGroup
+	for id := 0; id < options.Clients; id++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			client(ctx, id)
+		}(id)
+	}
+	wg.Wait()
+}
+
+// pace returns a function that blocks until the client may perform its next
+// operation, according to the rate limit.  It returns false once ctx is
+// cancelled.
+func pace(ctx context.Context, rate float64) func() bool {
+	if rate <= 0 {
+		return func() bool { return ctx.Err() == nil }
+	}
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	return func() bool {
+		select {
+		case <-ctx.Done():
+			ticker.Stop()
+			return false
+		case <-ticker.C:
+			return true
+		}
+	}
+}
+
+// backoff waits a moment before a client reconnects after an error, so that a
+// server that's down isn't hammered with connection attempts.
+func backoff(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(100 * time.Millisecond):
+	}
+}
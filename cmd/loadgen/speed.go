@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bbeck/protohackers/internal/client"
+	"net"
+	"sync"
+	"time"
+)
+
+// Speed simulates pairs of cameras one mile apart on each road that every car
+// passes at 120 mph, twice the limit, so that every car earns a ticket.  A
+// single dispatcher for every road receives the tickets, and the latency of
+// each is measured from when the second camera reported the car.
+func Speed(ctx context.Context, options Options, stats *Stats) error {
+	roads := make([]uint16, options.Roads)
+	for i := range roads {
+		roads[i] = uint16(i)
+	}
+
+	conn, err := net.DialTimeout("tcp", options.Addr, options.Timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	dispatcher, err := client.NewDispatcher(conn, roads...)
+	if err != nil {
+		return err
+	}
+
+	var pending sync.Map // The time each plate was reported by its second camera
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			ticket, err := dispatcher.Ticket()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					stats.Error("ticket", err)
+				}
+				return
+			}
+
+			if sent, found := pending.LoadAndDelete(ticket.Plate); found {
+				stats.Record("ticket", time.Since(sent.(time.Time)))
+			} else {
+				stats.Error("ticket", fmt.Errorf("unexpected ticket for %s", ticket.Plate))
+			}
+		}
+	}()
+
+	// Each client is a pair of cameras, so the number of cameras is twice the
+	// number of clients.
+	runClients(ctx, options, func(ctx context.Context, id int) {
+		road := roads[id%len(roads)]
+		mile := uint16(id / len(roads) * 2)
+		next := pace(ctx, options.Rate)
+
+		for ctx.Err() == nil {
+			first, err := client.DialCamera(options.Addr, road, mile, 60)
+			if err != nil {
+				stats.Error("plate", err)
+				backoff(ctx)
+				continue
+			}
+			second, err := client.DialCamera(options.Addr, road, mile+1, 60)
+			if err != nil {
+				_ = first.Close()
+				stats.Error("plate", err)
+				backoff(ctx)
+				continue
+			}
+
+			for car := 0; next(); car++ {
+				plate := fmt.Sprintf("C%dN%d", id, car)
+				timestamp := uint32(car) * 60
+
+				start := time.Now()
+				if err = first.Plate(plate, timestamp); err != nil {
+					break
+				}
+				if err = second.Plate(plate, timestamp+30); err != nil {
+					break
+				}
+				pending.Store(plate, time.Now())
+				stats.Record("plate", time.Since(start))
+			}
+			if err != nil && ctx.Err() == nil {
+				stats.Error("plate", err)
+			}
+
+			_ = first.Close()
+			_ = second.Close()
+		}
+	})
+
+	// Give the server a chance to dispatch the tickets for the last cars before
+	// counting any that are missing.
+	deadline := time.Now().Add(options.Timeout)
+	for time.Now().Before(deadline) {
+		var outstanding bool
+		pending.Range(func(any, any) bool {
+			outstanding = true
+			return false
+		})
+		if !outstanding {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_ = conn.Close()
+	<-done
+
+	pending.Range(func(plate, _ any) bool {
+		stats.Error("ticket", fmt.Errorf("no ticket for %s", plate))
+		return true
+	})
+	return nil
+}
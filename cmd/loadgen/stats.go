@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Stats collects the latency of every operation and the errors that happen
+// while generating load.  It's safe for concurrent use.
+type Stats struct {
+	mutex     sync.Mutex
+	latencies map[string][]time.Duration
+	errors    map[string]int
+	first     map[string]error // The first error seen for each operation
+}
+
+func NewStats() *Stats {
+	return &Stats{
+		latencies: make(map[string][]time.Duration),
+		errors:    make(map[string]int),
+		first:     make(map[string]error),
+	}
+}
+
+// Record records that an operation completed successfully in d.
+func (s *Stats) Record(op string, d time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.latencies[op] = append(s.latencies[op], d)
+}
+
+// Error records that an operation failed.
+func (s *Stats) Error(op string, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.errors[op]++
+	if s.first[op] == nil {
+		s.first[op] = err
+	}
+}
+
+// Report writes a table with the throughput, latency percentiles and error
+// count of each operation to w, followed by the first error of each operation
+// that had any.
+func (s *Stats) Report(w io.Writer, elapsed time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ops := make(map[string]bool)
+	for op := range s.latencies {
+		ops[op] = true
+	}
+	for op := range s.errors {
+		ops[op] = true
+	}
+
+	var names []string
+	for op := range ops {
+		names = append(names, op)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "op\tcount\tops/s\tp50\tp90\tp99\tmax\terrors\t")
+	for _, op := range names {
+		latencies := s.latencies[op]
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%s\t%s\t%s\t%s\t%d\t\n",
+			op,
+			len(latencies),
+			float64(len(latencies))/elapsed.Seconds(),
+			percentile(latencies, 50),
+			percentile(latencies, 90),
+			percentile(latencies, 99),
+			percentile(latencies, 100),
+			s.errors[op],
+		)
+	}
+	tw.Flush()
+
+	for _, op := range names {
+		if err := s.first[op]; err != nil {
+			fmt.Fprintf(w, "first %s error: %v\n", op, err)
+		}
+	}
+}
+
+// percentile returns the p-th percentile of the sorted latencies using the
+// nearest-rank method.
+func percentile(latencies []time.Duration, p int) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+
+	rank := (p*len(latencies) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return latencies[rank-1].Round(time.Microsecond)
+}
@@ -2,13 +2,9 @@ package main
 
 import (
 	"github.com/bbeck/protohackers/internal"
-	"io"
-	"net"
+	"github.com/bbeck/protohackers/internal/problem00"
 )
 
 func main() {
-	internal.RunTCPServer(func(conn net.Conn) {
-		defer conn.Close()
-		io.Copy(conn, conn)
-	})
+	internal.Main(problem00.Problem)
 }
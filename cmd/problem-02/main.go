@@ -1,60 +1,21 @@
 package main
 
 import (
-	"encoding/binary"
+	"context"
+	"flag"
 	"github.com/bbeck/protohackers/internal"
-	"net"
+	"github.com/bbeck/protohackers/internal/problem02"
 )
 
-type Price struct {
-	Timestamp, Price int32
-}
-
 func main() {
-	internal.RunTCPServer(func(conn net.Conn) {
-		var err error
-		read := func(data ...any) {
-			for i := 0; err == nil && i < len(data); i++ {
-				err = binary.Read(conn, binary.BigEndian, data[i])
-			}
-		}
-
-		write := func(data ...any) {
-			for i := 0; err == nil && i < len(data); i++ {
-				err = binary.Write(conn, binary.BigEndian, data[i])
-			}
-		}
-
-		defer conn.Close()
-
-		var prices []Price
-		for {
-			var kind byte
-			var a, b int32
-			read(&kind, &a, &b)
-
-			switch kind {
-			case 'I':
-				prices = append(prices, Price{Timestamp: a, Price: b})
-
-			case 'Q':
-				var sum, count int
-				for _, p := range prices {
-					if a <= p.Timestamp && p.Timestamp <= b {
-						sum += int(p.Price)
-						count++
-					}
-				}
-
-				if count != 0 {
-					write(int32(sum / count))
-				} else {
-					write(int32(0))
-				}
-
-			default:
-				return
-			}
-		}
-	})
+	var mode problem02.Mode
+	mode.RegisterFlags(flag.CommandLine)
+
+	// The flags aren't parsed until Main runs, so the mode is read when the
+	// server starts.
+	problem := problem02.Problem
+	problem.Run = func(ctx context.Context, server *internal.Server) error {
+		return mode.Run(ctx, server)
+	}
+	internal.Main(problem)
 }
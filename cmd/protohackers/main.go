@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/bbeck/protohackers/internal"
+	"github.com/bbeck/protohackers/internal/problem00"
+	"github.com/bbeck/protohackers/internal/problem01"
+	"github.com/bbeck/protohackers/internal/problem02"
+	"github.com/bbeck/protohackers/internal/problem03"
+	"github.com/bbeck/protohackers/internal/problem04"
+	"github.com/bbeck/protohackers/internal/problem05"
+	"github.com/bbeck/protohackers/internal/problem06"
+	"github.com/bbeck/protohackers/internal/problem07"
+	"github.com/bbeck/protohackers/internal/problem08"
+	"github.com/bbeck/protohackers/internal/problem09"
+	"github.com/bbeck/protohackers/internal/problem10"
+	"github.com/bbeck/protohackers/internal/problem11"
+	"log"
+	"log/slog"
+	"os"
+	"strconv"
+)
+
+// problems is every problem that the launcher knows how to serve, in order.
+var problems = []internal.Problem{
+	problem00.Problem,
+	problem01.Problem,
+	problem02.Problem,
+	problem03.Problem,
+	problem04.Problem,
+	problem05.Problem,
+	problem06.Problem,
+	problem07.Problem,
+	problem08.Problem,
+	problem09.Problem,
+	problem10.Problem,
+	problem11.Problem,
+}
+
+const usage = `Usage:
+  protohackers list
+  protohackers serve [flags] all|ID...
+
+When more than one problem is served each listens on -port plus its ID.  The
+flags include those of problems that have their own, such as -duplicates for
+problem 2.`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "list":
+		for _, problem := range problems {
+			fmt.Printf("%02d  %s\n", problem.ID, problem.Name)
+		}
+
+	case "serve":
+		if err := serve(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+
+	var mode problem02.Mode
+	mode.RegisterFlags(fs)
+
+	config, err := internal.ParseConfig(fs, args)
+	if err != nil {
+		return fmt.Errorf("error parsing configuration: %w", err)
+	}
+
+	selected, err := selectProblems(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	// The problems with flags of their own run with the values they were given.
+	for i, problem := range selected {
+		if problem.ID == problem02.Problem.ID {
+			selected[i].Run = mode.Run
+		}
+	}
+
+	slog.SetDefault(config.NewLogger(os.Stderr))
+
+	ctx, stop := internal.SignalContext()
+	defer stop()
+
+	return internal.Serve(ctx, config, selected...)
+}
+
+// selectProblems returns the problems named by args, which are either problem
+// IDs or the word "all".
+func selectProblems(args []string) ([]internal.Problem, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no problems specified\n%s", usage)
+	}
+
+	var selected []internal.Problem
+	seen := make(map[int]bool)
+	for _, arg := range args {
+		if arg == "all" {
+			return append([]internal.Problem(nil), problems...), nil
+		}
+
+		id, err := strconv.Atoi(arg)
+		if err != nil || id < 0 || id >= len(problems) {
+			return nil, fmt.Errorf("unknown problem: %s", arg)
+		}
+
+		if !seen[id] {
+			seen[id] = true
+			selected = append(selected, problems[id])
+		}
+	}
+
+	return selected, nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"github.com/bbeck/protohackers/internal"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const usage = `Usage: replay [flags] FILE...
+
+Replays the client side of each connection captured by a server run with
+-capture-dir against the server at -addr, and compares what the server sends
+back with what was recorded.  Before sending each chunk of data the replay
+waits for the server to send as much as it had when the client originally sent
+it, so request and response protocols are replayed in step.
+
+Flags:`
+
+// Options control how captures are replayed.
+type Options struct {
+	Addr     string
+	Timeout  time.Duration
+	Settle   time.Duration
+	Realtime bool
+}
+
+func main() {
+	var options Options
+	flag.StringVar(&options.Addr, "addr", "localhost:40000", "address of the server to replay against")
+	flag.DurationVar(&options.Timeout, "timeout", 2*time.Second, "how long to wait for the server to send the recorded responses")
+	flag.DurationVar(&options.Settle, "settle", 100*time.Millisecond, "how long to keep reading after the recorded responses, to catch extra data")
+	flag.BoolVar(&options.Realtime, "realtime", false, "keep the recorded gaps between the chunks the client sent")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, path := range flag.Args() {
+		if !replayFile(os.Stdout, path, options) {
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// replayFile replays a single capture file and reports the outcome to w,
+// returning whether the server's responses matched.
+func replayFile(w io.Writer, path string, options Options) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(w, "%s: %v\n", path, err)
+		return false
+	}
+	defer file.Close()
+
+	header, frames, err := internal.ReadCapture(file)
+	if err != nil {
+		fmt.Fprintf(w, "%s: %v\n", path, err)
+		return false
+	}
+
+	expected, actual, err := replay(frames, options)
+	if err != nil {
+		fmt.Fprintf(w, "%s: %v\n", path, err)
+		return false
+	}
+
+	if bytes.Equal(expected, actual) {
+		fmt.Fprintf(w, "%s: ok, %s connection %d from %s, %d bytes\n", path, header.Server, header.ConnID, header.RemoteAddr, len(actual))
+		return true
+	}
+
+	fmt.Fprintf(w, "%s: %s connection %d from %s\n%s", path, header.Server, header.ConnID, header.RemoteAddr, diff(expected, actual))
+	return false
+}
+
+// replay sends the client's side of the frames to the server and returns the
+// data the server was recorded sending along with the data it sent this time.
+func replay(frames []internal.CaptureFrame, options Options) ([]byte, []byte, error) {
+	conn, err := net.DialTimeout("tcp", options.Addr, options.Timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+
+	// The reader stops once replay returns, even if it has a chunk that will
+	// never be received.
+	chunks := make(chan []byte)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(chunks)
+		for {
+			bs := make([]byte, 4096)
+			n, err := conn.Read(bs)
+			if n > 0 {
+				select {
+				case chunks <- bs[:n]:
+				case <-done:
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// wait receives data from the server until at least n bytes have arrived in
+	// total, the server closes the connection or the timeout passes.
+	var expected, actual []byte
+	wait := func(n int, timeout time.Duration) {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		for len(actual) < n {
+			select {
+			case chunk, ok := <-chunks:
+				if !ok {
+					return
+				}
+				actual = append(actual, chunk...)
+			case <-timer.C:
+				return
+			}
+		}
+	}
+
+	var start time.Time
+	for _, frame := range frames {
+		if frame.Direction == internal.CaptureOut {
+			expected = append(expected, frame.Data...)
+			continue
+		}
+
+		wait(len(expected), options.Timeout)
+		if options.Realtime {
+			if start.IsZero() {
+				start = time.Now().Add(-frame.Time.Sub(frames[0].Time))
+			}
+			time.Sleep(time.Until(start.Add(frame.Time.Sub(frames[0].Time))))
+		}
+
+		if frame.EOF {
+			if tcp, ok := conn.(*net.TCPConn); ok {
+				_ = tcp.CloseWrite()
+			}
+			continue
+		}
+		if _, err := conn.Write(frame.Data); err != nil {
+			return expected, actual, fmt.Errorf("error sending: %w", err)
+		}
+	}
+
+	wait(len(expected), options.Timeout)
+	wait(len(actual)+1, options.Settle)
+	return expected, actual, nil
+}
+
+// diff describes where the actual data first differs from the expected data,
+// with a hex dump of each around that point.
+func diff(expected, actual []byte) string {
+	offset := 0
+	for offset < len(expected) && offset < len(actual) && expected[offset] == actual[offset] {
+		offset++
+	}
+
+	// Start the dumps on a line boundary shortly before the difference so that
+	// there's some context.
+	from := max(offset-32, 0) &^ 15
+	window := func(bs []byte) []byte {
+		return bs[min(from, len(bs)):min(from+96, len(bs))]
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "  responses differ at byte %d, expected %d bytes, got %d\n", offset, len(expected), len(actual))
+	fmt.Fprintf(&sb, "  expected, from byte %d:\n%s", from, indent(hex.Dump(window(expected))))
+	fmt.Fprintf(&sb, "  got, from byte %d:\n%s", from, indent(hex.Dump(window(actual))))
+	return sb.String()
+}
+
+func indent(s string) string {
+	if s == "" {
+		return "    (nothing)\n"
+	}
+	return "    " + strings.ReplaceAll(strings.TrimSuffix(s, "\n"), "\n", "\n    ") + "\n"
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/bbeck/protohackers/internal"
+	"github.com/bbeck/protohackers/internal/problem00"
+	"github.com/bbeck/protohackers/internal/servertest"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// record runs an echo server that captures its connections to dir, and records
+// a connection that sends each chunk after waiting gap.  It returns the path
+// of the capture file.
+func record(t *testing.T, dir string, gap time.Duration, chunks ...string) string {
+	t.Helper()
+
+	addr := servertest.Start(t, problem00.Problem, func(config *internal.Config) {
+		config.CaptureDir = dir
+	})
+
+	conn := servertest.Dial(t, "tcp", addr)
+	for _, chunk := range chunks {
+		time.Sleep(gap)
+		conn.SendString(chunk)
+		conn.ExpectString(chunk)
+	}
+	if err := conn.Conn.(interface{ CloseWrite() error }).CloseWrite(); err != nil {
+		t.Fatalf("error closing: %v", err)
+	}
+	conn.ExpectClosed()
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil || len(paths) != 1 {
+		t.Fatalf("expected one capture file, found %v (%v)", paths, err)
+	}
+	return paths[0]
+}
+
+// frames returns a description of the frames in a capture file.
+func frames(t *testing.T, path string) string {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("error opening capture: %v", err)
+	}
+	defer file.Close()
+
+	_, frames, err := internal.ReadCapture(file)
+	if err != nil {
+		t.Fatalf("error reading capture: %v", err)
+	}
+
+	var descriptions []string
+	for _, frame := range frames {
+		if frame.EOF {
+			descriptions = append(descriptions, frame.Direction+" EOF")
+		} else {
+			descriptions = append(descriptions, frame.Direction+" "+string(frame.Data))
+		}
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+func TestReplayMatch(t *testing.T) {
+	path := record(t, t.TempDir(), 0, "hello\n", "world\n")
+
+	// The replay is captured too, so that what it sent can be compared with the
+	// original connection.
+	dir := t.TempDir()
+	addr := servertest.Start(t, problem00.Problem, func(config *internal.Config) {
+		config.CaptureDir = dir
+	})
+
+	var out strings.Builder
+	if !replayFile(&out, path, Options{Addr: addr, Timeout: servertest.Timeout, Settle: 50 * time.Millisecond}) {
+		t.Fatalf("replay didn't match:\n%s", out.String())
+	}
+	if want := fmt.Sprintf("%s: ok, problem-00 connection ", path); !strings.HasPrefix(out.String(), want) {
+		t.Errorf("unexpected output %q, want it to start with %q", out.String(), want)
+	}
+	if want := ", 12 bytes\n"; !strings.HasSuffix(out.String(), want) {
+		t.Errorf("unexpected output %q, want it to end with %q", out.String(), want)
+	}
+
+	// The server sees the same data, including the client closing its side of
+	// the connection, which is what made the echo server close it.
+	paths, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil || len(paths) != 1 {
+		t.Fatalf("expected one capture file, found %v (%v)", paths, err)
+	}
+	if got, want := frames(t, paths[0]), frames(t, path); got != want {
+		t.Errorf("replayed frames %q, want %q", got, want)
+	}
+}
+
+func TestReplayRealtime(t *testing.T) {
+	path := record(t, t.TempDir(), 200*time.Millisecond, "hello\n", "world\n")
+	addr := servertest.Start(t, problem00.Problem)
+
+	tests := []struct {
+		realtime bool
+		min, max time.Duration
+	}{
+		{false, 0, 150 * time.Millisecond},
+		{true, 150 * time.Millisecond, servertest.Timeout},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("realtime=%t", test.realtime), func(t *testing.T) {
+			var out strings.Builder
+			start := time.Now()
+			if !replayFile(&out, path, Options{Addr: addr, Timeout: servertest.Timeout, Realtime: test.realtime}) {
+				t.Fatalf("replay didn't match:\n%s", out.String())
+			}
+
+			// The recorded gap is between the two chunks, the wait before the
+			// first one isn't kept.
+			if elapsed := time.Since(start); elapsed < test.min || elapsed > test.max {
+				t.Errorf("replay took %v, want between %v and %v", elapsed, test.min, test.max)
+			}
+		})
+	}
+}
+
+// shout is a server that sends back each line in upper case, and once the
+// client has closed its side of the connection sends more data as fast as it
+// can until the client goes away.
+var shout = internal.Problem{
+	ID:   99,
+	Name: "Shout",
+	Run: func(ctx context.Context, server *internal.Server) error {
+		return server.ServeTCP(ctx, func(conn net.Conn) {
+			defer conn.Close()
+
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				if _, err := io.WriteString(conn, strings.ToUpper(scanner.Text())+"\n"); err != nil {
+					return
+				}
+			}
+
+			for {
+				if _, err := io.WriteString(conn, "more\n"); err != nil {
+					return
+				}
+			}
+		})
+	},
+}
+
+func TestReplayMismatch(t *testing.T) {
+	path := record(t, t.TempDir(), 0, "hello\n", "world\n")
+	addr := servertest.Start(t, shout)
+
+	goroutines := runtime.NumGoroutine()
+
+	var out strings.Builder
+	if replayFile(&out, path, Options{Addr: addr, Timeout: servertest.Timeout, Settle: 50 * time.Millisecond}) {
+		t.Fatalf("replay matched:\n%s", out.String())
+	}
+	for _, want := range []string{
+		path + ": problem-00 connection ",
+		"  responses differ at byte 0, expected 12 bytes, got ",
+		"  expected, from byte 0:\n    00000000  68 65 6c 6c 6f 0a 77 6f  72 6c 64 0a              |hello.world.|\n",
+		"  got, from byte 0:\n    00000000  48 45 4c 4c 4f 0a 57 4f  52 4c 44 0a 6d 6f 72 65  |HELLO.WORLD.more|\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output doesn't contain %q:\n%s", want, out.String())
+		}
+	}
+
+	// Nothing is left reading the data the server is still sending.
+	deadline := time.Now().Add(servertest.Timeout)
+	for runtime.NumGoroutine() > goroutines {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines are still running after the replay, want %d", runtime.NumGoroutine(), goroutines)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	expected := []byte(strings.Repeat("a", 100) + "b")
+	actual := []byte(strings.Repeat("a", 100))
+
+	// The dumps start on a line boundary before the difference.
+	got := diff(expected, actual)
+	for _, want := range []string{
+		"  responses differ at byte 100, expected 101 bytes, got 100\n",
+		"  expected, from byte 64:\n    00000000  61 61",
+		"  got, from byte 64:\n    00000000  61 61",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("diff doesn't contain %q:\n%s", want, got)
+		}
+	}
+
+	if got := diff([]byte("hello"), nil); !strings.HasSuffix(got, "  got, from byte 0:\n    (nothing)\n") {
+		t.Errorf("diff doesn't show that nothing was received:\n%s", got)
+	}
+}
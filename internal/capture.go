@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CaptureVersion is the version of the capture format that's written.
+const CaptureVersion = 1
+
+// A capture file records the traffic of a single TCP connection as JSON
+// values, one per line.  The first line is a CaptureHeader describing the
+// connection and every following line is a CaptureFrame holding the bytes
+// returned by one read from the client or passed to one write to the client,
+// in the order they happened.  For example:
+//
+//	{"version":1,"server":"problem-00","conn_id":1,"remote_addr":"127.0.0.1:5555","local_addr":"127.0.0.1:40000","time":"2024-01-02T15:04:05.000000001Z"}
+//	{"time":"2024-01-02T15:04:05.100000001Z","direction":"in","data":"aGVsbG8K"}
+//	{"time":"2024-01-02T15:04:05.100000002Z","direction":"out","data":"aGVsbG8K"}
+//	{"time":"2024-01-02T15:04:05.200000001Z","direction":"in","eof":true}
+//
+// When TLS is terminated by the server the plaintext is recorded.
+type CaptureHeader struct {
+	Version    int       `json:"version"`
+	Server     string    `json:"server"`
+	ConnID     uint64    `json:"conn_id"`
+	RemoteAddr string    `json:"remote_addr"`
+	LocalAddr  string    `json:"local_addr"`
+	Time       time.Time `json:"time"`
+}
+
+// Directions of a CaptureFrame.
+const (
+	// CaptureIn is data sent by the client to the server.
+	CaptureIn = "in"
+
+	// CaptureOut is data sent by the server to the client.
+	CaptureOut = "out"
+)
+
+// CaptureFrame is a chunk of data sent in one direction of a connection.  Data
+// is base64 encoded.  A frame with EOF set records that the client closed its
+// side of the connection and has no data.
+type CaptureFrame struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"`
+	Data      []byte    `json:"data,omitempty"`
+	EOF       bool      `json:"eof,omitempty"`
+}
+
+// capture writes the frames of a connection to its capture file.  It's safe
+// for concurrent use, and does nothing once closed.
+type capture struct {
+	mutex   sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+	eof     bool // Whether the end of the client's stream has been recorded
+}
+
+// openCapture creates a capture file for a connection in dir and writes its
+// header.
+func openCapture(dir string, header CaptureHeader) (*capture, error) {
+	// Server names default to the listening address, which isn't a good file
+	// name.
+	server := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`:/\[]`, r) {
+			return '_'
+		}
+		return r
+	}, header.Server)
+
+	name := fmt.Sprintf("%s-%s-%d.jsonl", server, header.Time.UTC().Format("20060102T150405.000"), header.ConnID)
+	file, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("error creating capture file: %w", err)
+	}
+
+	c := &capture{file: file, encoder: json.NewEncoder(file)}
+	header.Version = CaptureVersion
+	if err := c.encoder.Encode(header); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("error writing capture header: %w", err)
+	}
+
+	return c, nil
+}
+
+// record writes a frame to the capture file.  Errors are returned so that they
+// can be logged, but the connection carries on regardless.
+func (c *capture) record(direction string, data []byte, eof bool) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.file == nil || (eof && c.eof) {
+		return nil
+	}
+	c.eof = c.eof || eof
+
+	return c.encoder.Encode(CaptureFrame{
+		Time:      time.Now(),
+		Direction: direction,
+		Data:      data,
+		EOF:       eof,
+	})
+}
+
+func (c *capture) close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.file == nil {
+		return nil
+	}
+
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
+
+// ReadCapture reads a capture file written by a server with CaptureDir set.
+func ReadCapture(r io.Reader) (CaptureHeader, []CaptureFrame, error) {
+	var header CaptureHeader
+	var frames []CaptureFrame
+
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	if err := decoder.Decode(&header); err != nil {
+		return header, nil, fmt.Errorf("error reading capture header: %w", err)
+	}
+	if header.Version != CaptureVersion {
+		return header, nil, fmt.Errorf("unsupported capture version: %d", header.Version)
+	}
+
+	for {
+		var frame CaptureFrame
+		err := decoder.Decode(&frame)
+		if errors.Is(err, io.EOF) {
+			return header, frames, nil
+		}
+		if err != nil {
+			// A capture that's cut short, for example by a crash, is still useful.
+			return header, frames, fmt.Errorf("error reading capture frame %d: %w", len(frames)+1, err)
+		}
+
+		if frame.Direction != CaptureIn && frame.Direction != CaptureOut {
+			return header, frames, fmt.Errorf("invalid direction in capture frame %d: %q", len(frames)+1, frame.Direction)
+		}
+		frames = append(frames, frame)
+	}
+}
@@ -0,0 +1,73 @@
+package internal_test
+
+import (
+	"github.com/bbeck/protohackers/internal"
+	"github.com/bbeck/protohackers/internal/problem00"
+	"github.com/bbeck/protohackers/internal/servertest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCapture(t *testing.T) {
+	dir := t.TempDir()
+	addr := servertest.Start(t, problem00.Problem, func(config *internal.Config) {
+		config.CaptureDir = dir
+	})
+
+	conn := servertest.Dial(t, "tcp", addr)
+	conn.SendString("hello")
+	conn.ExpectString("hello")
+	if err := conn.Conn.(interface{ CloseWrite() error }).CloseWrite(); err != nil {
+		t.Fatalf("error closing: %v", err)
+	}
+	conn.ExpectClosed()
+
+	paths, err := filepath.Glob(filepath.Join(dir, "problem-00-*.jsonl"))
+	if err != nil || len(paths) != 1 {
+		t.Fatalf("expected one capture file, found %v (%v)", paths, err)
+	}
+
+	file, err := os.Open(paths[0])
+	if err != nil {
+		t.Fatalf("error opening capture: %v", err)
+	}
+	defer file.Close()
+
+	header, frames, err := internal.ReadCapture(file)
+	if err != nil {
+		t.Fatalf("error reading capture: %v", err)
+	}
+	if header.Server != "problem-00" || header.RemoteAddr != conn.LocalAddr().String() {
+		t.Errorf("unexpected header: %+v", header)
+	}
+
+	var got []string
+	for _, frame := range frames {
+		if frame.Time.Before(header.Time) {
+			t.Errorf("frame recorded before the connection was accepted: %+v", frame)
+		}
+		if frame.EOF {
+			got = append(got, frame.Direction+" EOF")
+		} else {
+			got = append(got, frame.Direction+" "+string(frame.Data))
+		}
+	}
+	if want := "in hello, out hello, in EOF"; strings.Join(got, ", ") != want {
+		t.Errorf("unexpected frames: %q, want %q", got, want)
+	}
+}
+
+func TestReadCaptureRejectsInvalidFrames(t *testing.T) {
+	for _, capture := range []string{
+		``,
+		`{"version":2}`,
+		`{"version":1}` + "\n" + `{"direction":"sideways"}`,
+		`{"version":1}` + "\n" + `{"direction":"in","data":`,
+	} {
+		if _, _, err := internal.ReadCapture(strings.NewReader(capture)); err == nil {
+			t.Errorf("capture %q was read without an error", capture)
+		}
+	}
+}
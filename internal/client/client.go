@@ -0,0 +1,27 @@
+// Package client contains clients for the protocols spoken by the problem
+// servers, so that tests and tools can talk to a server without hand-crafting
+// its wire format.
+//
+// Unless stated otherwise a client isn't safe for concurrent use.
+package client
+
+import (
+	"net"
+	"time"
+)
+
+// DialTimeout limits how long the Dial functions wait for a connection to be
+// established.
+const DialTimeout = 10 * time.Second
+
+// ServerError is an error that was reported by the server, the string is the
+// message that the server sent.
+type ServerError string
+
+func (e ServerError) Error() string {
+	return "server error: " + string(e)
+}
+
+func dial(addr string) (net.Conn, error) {
+	return net.DialTimeout("tcp", addr, DialTimeout)
+}
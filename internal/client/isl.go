@@ -0,0 +1,90 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/bbeck/protohackers/internal/problem08"
+	"io"
+	"net"
+	"strings"
+)
+
+// ISLClient is an Insecure Sockets Layer client that asks the server which toy
+// to make.
+type ISLClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	writer io.Writer
+}
+
+// DialISL connects to the Insecure Sockets Layer server at addr and negotiates
+// the cipher spec.  The spec is a sequence of cipher operations, e.g.
+// []byte{0x02, 0x7b, 0x05} for xor(123) followed by addpos, without the
+// trailing 0x00 that ends it on the wire.
+func DialISL(addr string, spec []byte) (*ISLClient, error) {
+	conn, err := dial(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := NewISLClient(conn, spec)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// NewISLClient negotiates the cipher spec on an existing connection to an
+// Insecure Sockets Layer server.
+func NewISLClient(conn net.Conn, spec []byte) (*ISLClient, error) {
+	if err := checkSpec(spec); err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Write(append(append([]byte(nil), spec...), 0x00)); err != nil {
+		return nil, err
+	}
+
+	encrypt, decrypt := problem08.GetCiphers(spec)
+	return &ISLClient{
+		conn:   conn,
+		reader: bufio.NewReader(problem08.NewDecrypter(conn, decrypt)),
+		writer: problem08.NewEncrypter(conn, encrypt),
+	}, nil
+}
+
+// ChooseToy sends a request, a comma separated list of toys such as
+// "10x toy car,15x dog on a string", and returns the toy the server chose.
+func (c *ISLClient) ChooseToy(request string) (string, error) {
+	if _, err := io.WriteString(c.writer, request+"\n"); err != nil {
+		return "", err
+	}
+
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+// Close closes the connection to the server.
+func (c *ISLClient) Close() error {
+	return c.conn.Close()
+}
+
+// checkSpec checks that spec is made up of complete, known cipher operations.
+func checkSpec(spec []byte) error {
+	for i := 0; i < len(spec); i++ {
+		switch spec[i] {
+		case 0x01, 0x03, 0x05: // reversebits, xorpos, addpos
+		case 0x02, 0x04: // xor(N), add(N)
+			if i++; i == len(spec) {
+				return fmt.Errorf("cipher spec is missing the operand of %#02x", spec[i-1])
+			}
+		default:
+			return fmt.Errorf("cipher spec contains unknown operation %#02x", spec[i])
+		}
+	}
+	return nil
+}
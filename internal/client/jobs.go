@@ -0,0 +1,160 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// Job is a job that was assigned to a Job Centre client.
+type Job struct {
+	ID       uint64
+	Queue    string
+	Priority int
+	Payload  json.RawMessage
+}
+
+// JobsClient is a Job Centre client.
+type JobsClient struct {
+	conn    net.Conn
+	decoder *json.Decoder
+}
+
+// DialJobs connects to the Job Centre server at addr.
+func DialJobs(addr string) (*JobsClient, error) {
+	conn, err := dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewJobsClient(conn), nil
+}
+
+// NewJobsClient returns a client that uses an existing connection to a Job
+// Centre server.
+func NewJobsClient(conn net.Conn) *JobsClient {
+	return &JobsClient{
+		conn:    conn,
+		decoder: json.NewDecoder(conn),
+	}
+}
+
+type jobsResponse struct {
+	Status   string          `json:"status"`
+	Error    string          `json:"error"`
+	ID       json.Number     `json:"id"`
+	Job      json.RawMessage `json:"job"`
+	Priority int             `json:"pri"`
+	Queue    string          `json:"queue"`
+}
+
+// request sends a request and reads the server's response to it.  An error
+// response is returned as a ServerError.
+func (c *JobsClient) request(request map[string]any) (*jobsResponse, error) {
+	bs, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := c.conn.Write(append(bs, '\n')); err != nil {
+		return nil, err
+	}
+
+	var response jobsResponse
+	if err := c.decoder.Decode(&response); err != nil {
+		return nil, err
+	}
+
+	switch response.Status {
+	case "ok", "no-job":
+		return &response, nil
+	case "error":
+		return nil, ServerError(response.Error)
+	default:
+		return nil, fmt.Errorf("unexpected status: %q", response.Status)
+	}
+}
+
+// Put adds a job to queue with priority and returns its ID.  The job is
+// marshalled to JSON.
+func (c *JobsClient) Put(queue string, priority int, job any) (uint64, error) {
+	response, err := c.request(map[string]any{
+		"request": "put",
+		"queue":   queue,
+		"pri":     priority,
+		"job":     job,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return parseJobID(response.ID)
+}
+
+// Get returns the highest priority job in any of queues, which is then
+// assigned to this client until it's deleted, aborted or the client
+// disconnects.  If there are no jobs it returns nil, unless wait is true in
+// which case it waits for one to be added.
+func (c *JobsClient) Get(queues []string, wait bool) (*Job, error) {
+	response, err := c.request(map[string]any{
+		"request": "get",
+		"queues":  queues,
+		"wait":    wait,
+	})
+	if err != nil || response.Status == "no-job" {
+		return nil, err
+	}
+
+	id, err := parseJobID(response.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Job{
+		ID:       id,
+		Queue:    response.Queue,
+		Priority: response.Priority,
+		Payload:  response.Job,
+	}, nil
+}
+
+// Delete deletes a job, it returns false if the job didn't exist.
+func (c *JobsClient) Delete(id uint64) (bool, error) {
+	response, err := c.request(map[string]any{
+		"request": "delete",
+		"id":      id,
+	})
+	if err != nil {
+		return false, err
+	}
+	return response.Status == "ok", nil
+}
+
+// Abort puts a job that's assigned to this client back in its queue, it returns
+// false if the job didn't exist or isn't assigned to this client.
+func (c *JobsClient) Abort(id uint64) (bool, error) {
+	response, err := c.request(map[string]any{
+		"request": "abort",
+		"id":      id,
+	})
+	if err != nil {
+		return false, err
+	}
+	return response.Status == "ok", nil
+}
+
+// Close closes the connection to the server, which aborts any jobs that are
+// assigned to this client.
+func (c *JobsClient) Close() error {
+	return c.conn.Close()
+}
+
+// parseJobID parses a job's ID, which the server may send as either a number
+// or a string.
+func parseJobID(n json.Number) (uint64, error) {
+	id, err := strconv.ParseUint(n.String(), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid job id: %q", n)
+	}
+	return id, nil
+}
@@ -0,0 +1,124 @@
+package client_test
+
+import (
+	"bufio"
+	"errors"
+	"github.com/bbeck/protohackers/internal/client"
+	"github.com/bbeck/protohackers/internal/problem09"
+	"github.com/bbeck/protohackers/internal/servertest"
+	"net"
+	"testing"
+	"time"
+)
+
+func jobs(t *testing.T) *client.JobsClient {
+	t.Helper()
+
+	addr := servertest.Start(t, problem09.Problem)
+	conn := servertest.Dial(t, "tcp", addr)
+	_ = conn.SetDeadline(time.Now().Add(servertest.Timeout))
+	return client.NewJobsClient(conn)
+}
+
+func TestJobsClient(t *testing.T) {
+	c := jobs(t)
+
+	low, err := c.Put("queue1", 1, map[string]string{"title": "low"})
+	if err != nil {
+		t.Fatalf("error putting job: %v", err)
+	}
+	high, err := c.Put("queue2", 2, map[string]string{"title": "high"})
+	if err != nil {
+		t.Fatalf("error putting job: %v", err)
+	}
+	if low == high {
+		t.Fatalf("both jobs have id %d", low)
+	}
+
+	// The highest priority job in any of the queues is returned.
+	job, err := c.Get([]string{"queue1", "queue2"}, false)
+	if err != nil {
+		t.Fatalf("error getting job: %v", err)
+	}
+	if job == nil || job.ID != high || job.Queue != "queue2" || job.Priority != 2 || string(job.Payload) != `{"title":"high"}` {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+
+	// Aborting it puts it back in its queue.
+	if ok, err := c.Abort(high); !ok || err != nil {
+		t.Fatalf("Abort(%d) = %t, %v, want true, nil", high, ok, err)
+	}
+	if ok, err := c.Delete(high); !ok || err != nil {
+		t.Fatalf("Delete(%d) = %t, %v, want true, nil", high, ok, err)
+	}
+	if ok, err := c.Delete(high); ok || err != nil {
+		t.Fatalf("Delete(%d) = %t, %v, want false, nil", high, ok, err)
+	}
+	if ok, err := c.Abort(high); ok || err != nil {
+		t.Fatalf("Abort(%d) = %t, %v, want false, nil", high, ok, err)
+	}
+
+	// Once a queue is empty there's no job to get.
+	job, err = c.Get([]string{"queue2"}, false)
+	if job != nil || err != nil {
+		t.Fatalf("Get() = %+v, %v, want nil, nil", job, err)
+	}
+
+	job, err = c.Get([]string{"queue1"}, true)
+	if err != nil {
+		t.Fatalf("error getting job: %v", err)
+	}
+	if job == nil || job.ID != low {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+}
+
+// respond returns a client whose server answers every request with one of
+// responses in turn.
+func respond(t *testing.T, responses ...string) *client.JobsClient {
+	t.Helper()
+
+	conn, server := net.Pipe()
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		defer server.Close()
+
+		reader := bufio.NewReader(server)
+		for _, response := range responses {
+			if _, err := reader.ReadString('\n'); err != nil {
+				return
+			}
+			if _, err := server.Write([]byte(response + "\n")); err != nil {
+				return
+			}
+		}
+	}()
+
+	return client.NewJobsClient(conn)
+}
+
+func TestJobsClientIDs(t *testing.T) {
+	c := respond(t,
+		`{"status":"ok","id":42}`,
+		`{"status":"ok","id":"43"}`,
+		`{"status":"ok","id":"forty-four"}`,
+		`{"status":"error","error":"invalid request"}`,
+	)
+
+	// The server may send an id as a number or as a string.
+	if id, err := c.Put("queue", 1, nil); id != 42 || err != nil {
+		t.Errorf("Put() = %d, %v, want 42, nil", id, err)
+	}
+	if id, err := c.Put("queue", 1, nil); id != 43 || err != nil {
+		t.Errorf("Put() = %d, %v, want 43, nil", id, err)
+	}
+	if _, err := c.Put("queue", 1, nil); err == nil {
+		t.Error("Put() with a malformed id succeeded, want an error")
+	}
+
+	var serverErr client.ServerError
+	if _, err := c.Put("queue", 1, nil); !errors.As(err, &serverErr) || serverErr != "invalid request" {
+		t.Errorf("Put() error = %v, want a server error", err)
+	}
+}
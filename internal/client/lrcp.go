@@ -0,0 +1,394 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bbeck/protohackers/internal/problem07"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxLRCPChunk is the most unescaped data sent in a single data message.  Even
+// if every byte has to be escaped the message stays under the 1000 byte limit.
+const maxLRCPChunk = 450
+
+// LRCPDialer opens LRCP sessions.  The zero value uses the same timeouts as the
+// Line Reversal server.
+type LRCPDialer struct {
+	// RetransmissionTimeout is how long to wait for a message to be
+	// acknowledged before sending it again.
+	RetransmissionTimeout time.Duration
+
+	// SessionExpiry is how long to keep retransmitting a message that isn't
+	// acknowledged before giving up on the session.
+	SessionExpiry time.Duration
+
+	// DialUDP opens the datagram connection that the session runs over, if nil
+	// net.Dial is used.  It allows packets to be dropped, delayed or otherwise
+	// tampered with, for example.
+	DialUDP func(addr string) (net.Conn, error)
+}
+
+// DialLRCP opens an LRCP session with the server at addr using the default
+// dialer.
+func DialLRCP(addr string) (net.Conn, error) {
+	return new(LRCPDialer).Dial(addr)
+}
+
+// Dial opens an LRCP session with the server at addr and returns it as a
+// connection.  Writes never block, data is buffered until the server
+// acknowledges it.  Reads return io.EOF once the server closes the session.
+func (d *LRCPDialer) Dial(addr string) (net.Conn, error) {
+	var udp net.Conn
+	var err error
+	if d.DialUDP != nil {
+		udp, err = d.DialUDP(addr)
+	} else {
+		udp, err = net.Dial("udp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &lrcpConn{
+		udp:        udp,
+		session:    rand.Intn(math.MaxInt32),
+		retransmit: d.RetransmissionTimeout,
+		expiry:     d.SessionExpiry,
+		notify:     make(chan struct{}),
+	}
+	if c.retransmit == 0 {
+		c.retransmit = problem07.RetransmissionTimeout
+	}
+	if c.expiry == 0 {
+		c.expiry = problem07.SessionExpiration
+	}
+
+	go c.receive()
+
+	if err := c.connect(); err != nil {
+		_ = udp.Close()
+		return nil, err
+	}
+
+	go c.retransmitter()
+	return c, nil
+}
+
+// lrcpConn is the client side of an LRCP session.
+type lrcpConn struct {
+	udp        net.Conn
+	session    int
+	retransmit time.Duration
+	expiry     time.Duration
+
+	mutex     sync.Mutex
+	notify    chan struct{} // Closed and replaced whenever the state changes
+	connected bool
+	closed    bool
+	err       error // Why the session was closed
+
+	readDeadline time.Time
+	in           []byte // Data received but not read yet
+	received     int    // The position in the stream we've received up to
+
+	out      []byte    // Data written but not acknowledged yet
+	acked    int       // The position in the stream the server has acked
+	sent     int       // The position in the stream we've sent up to
+	lastSend time.Time // When data was last sent
+	progress time.Time // When the server last acknowledged new data
+}
+
+// connect sends connect messages until the server acknowledges one.
+func (c *lrcpConn) connect() error {
+	deadline := time.Now().Add(c.expiry)
+	for time.Now().Before(deadline) {
+		c.mutex.Lock()
+		c.send("/connect/%d/", c.session)
+		notify := c.notify
+		c.mutex.Unlock()
+
+		timer := time.NewTimer(c.retransmit)
+		select {
+		case <-notify:
+		case <-timer.C:
+		}
+		timer.Stop()
+
+		c.mutex.Lock()
+		connected, err := c.connected, c.err
+		c.mutex.Unlock()
+
+		if err != nil {
+			return err
+		}
+		if connected {
+			return nil
+		}
+	}
+
+	return errors.New("lrcp: timed out connecting")
+}
+
+// receive handles packets from the server until the connection is closed.
+func (c *lrcpConn) receive() {
+	buf := make([]byte, 1000)
+	for {
+		n, err := c.udp.Read(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			// Errors such as an ICMP port unreachable are transient as far as LRCP
+			// is concerned, the session expiry will take care of a dead server.
+			continue
+		}
+
+		packet, err := problem07.ParsePacket(buf[:n])
+		if err != nil || packet.Session != c.session {
+			continue
+		}
+
+		c.handle(packet)
+	}
+}
+
+func (c *lrcpConn) handle(packet *problem07.Packet) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		if packet.IsData || packet.IsAck {
+			c.send("/close/%d/", c.session)
+		}
+		return
+	}
+
+	switch {
+	case packet.IsAck:
+		if !c.connected {
+			c.connected = true
+			c.broadcast()
+			return
+		}
+
+		if packet.Length <= c.acked {
+			return
+		}
+		if packet.Length > c.sent {
+			c.send("/close/%d/", c.session)
+			c.close(errors.New("lrcp: server acknowledged data that was never sent"))
+			return
+		}
+
+		c.out = c.out[packet.Length-c.acked:]
+		c.acked = packet.Length
+		c.progress = time.Now()
+		c.broadcast()
+
+	case packet.IsData:
+		if packet.Pos > c.received {
+			c.send("/ack/%d/%d/", c.session, c.received)
+			return
+		}
+
+		start := c.received - packet.Pos
+		if start < len(packet.Data) {
+			c.in = append(c.in, packet.Data[start:]...)
+			c.received += len(packet.Data) - start
+			c.broadcast()
+		}
+		c.send("/ack/%d/%d/", c.session, c.received)
+
+	case packet.IsClose:
+		c.send("/close/%d/", c.session)
+		c.close(io.EOF)
+	}
+}
+
+// retransmitter resends unacknowledged data until the session is closed, and
+// closes the session if the server stops acknowledging data.
+func (c *lrcpConn) retransmitter() {
+	ticker := time.NewTicker(c.retransmit / 10)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		c.mutex.Lock()
+		if c.closed {
+			c.mutex.Unlock()
+			return
+		}
+
+		if c.sent > c.acked {
+			if time.Since(c.progress) > c.expiry {
+				c.close(errors.New("lrcp: session expired"))
+			} else if time.Since(c.lastSend) > c.retransmit {
+				c.sent = c.acked
+				c.flush()
+			}
+		}
+		c.mutex.Unlock()
+	}
+}
+
+// flush sends any buffered data that hasn't been sent yet.  The lock must be
+// held by the caller.
+func (c *lrcpConn) flush() {
+	for c.sent < c.acked+len(c.out) {
+		start := c.sent - c.acked
+		end := min(start+maxLRCPChunk, len(c.out))
+		c.send("/data/%d/%d/%s/", c.session, c.sent, problem07.Escape(c.out[start:end]))
+		c.sent += end - start
+		c.lastSend = time.Now()
+	}
+}
+
+func (c *lrcpConn) send(format string, args ...any) {
+	_, _ = fmt.Fprintf(c.udp, format, args...)
+}
+
+// broadcast wakes up everything waiting for the state to change.  The lock must
+// be held by the caller.
+func (c *lrcpConn) broadcast() {
+	close(c.notify)
+	c.notify = make(chan struct{})
+}
+
+// close marks the session as closed because of err.  The lock must be held by
+// the caller.
+func (c *lrcpConn) close(err error) {
+	if c.closed {
+		return
+	}
+
+	c.closed = true
+	c.err = err
+	c.broadcast()
+}
+
+func (c *lrcpConn) Read(bs []byte) (int, error) {
+	for {
+		c.mutex.Lock()
+		if len(c.in) > 0 {
+			n := copy(bs, c.in)
+			c.in = c.in[n:]
+			c.mutex.Unlock()
+			return n, nil
+		}
+		if c.closed {
+			err := c.err
+			c.mutex.Unlock()
+			return 0, err
+		}
+		deadline, notify := c.readDeadline, c.notify
+		c.mutex.Unlock()
+
+		if err := wait(notify, deadline); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (c *lrcpConn) Write(bs []byte) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		if c.err == io.EOF {
+			return 0, net.ErrClosed
+		}
+		return 0, c.err
+	}
+	if c.acked+len(c.out)+len(bs) > math.MaxInt32 {
+		return 0, errors.New("lrcp: stream too long")
+	}
+
+	if c.sent == c.acked {
+		c.progress = time.Now()
+	}
+	c.out = append(c.out, bs...)
+	c.flush()
+	return len(bs), nil
+}
+
+// Close waits for any buffered data to be acknowledged, for at most the session
+// expiry, and then closes the session.
+func (c *lrcpConn) Close() error {
+	deadline := time.Now().Add(c.expiry)
+	for {
+		c.mutex.Lock()
+		if c.closed || len(c.out) == 0 {
+			break
+		}
+		notify := c.notify
+		c.mutex.Unlock()
+
+		if err := wait(notify, deadline); err != nil {
+			c.mutex.Lock()
+			break
+		}
+	}
+
+	if !c.closed {
+		c.send("/close/%d/", c.session)
+	}
+	c.close(net.ErrClosed)
+	c.mutex.Unlock()
+
+	return c.udp.Close()
+}
+
+func (c *lrcpConn) LocalAddr() net.Addr {
+	return c.udp.LocalAddr()
+}
+
+func (c *lrcpConn) RemoteAddr() net.Addr {
+	return c.udp.RemoteAddr()
+}
+
+func (c *lrcpConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *lrcpConn) SetReadDeadline(t time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.readDeadline = t
+	c.broadcast()
+	return nil
+}
+
+// SetWriteDeadline does nothing since writes never block.
+func (c *lrcpConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+// wait waits for notify to be closed, or returns os.ErrDeadlineExceeded if the
+// deadline (if not zero) passes first.
+func wait(notify <-chan struct{}, deadline time.Time) error {
+	if deadline.IsZero() {
+		<-notify
+		return nil
+	}
+
+	d := time.Until(deadline)
+	if d <= 0 {
+		return os.ErrDeadlineExceeded
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-notify:
+		return nil
+	case <-timer.C:
+		return os.ErrDeadlineExceeded
+	}
+}
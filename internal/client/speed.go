@@ -0,0 +1,236 @@
+package client
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// Ticket is a speeding ticket issued by a Speed Daemon server.
+type Ticket struct {
+	Plate      string
+	Road       uint16
+	Mile1      uint16
+	Timestamp1 uint32
+	Mile2      uint16
+	Timestamp2 uint32
+	Speed      uint16 // In hundredths of a mile per hour
+}
+
+// speedConn is the part of the Speed Daemon protocol that's common to cameras
+// and dispatchers.
+type speedConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// WantHeartbeat asks the server to send a heartbeat every interval
+// deciseconds, an interval of zero disables heartbeats.
+func (c *speedConn) WantHeartbeat(interval uint32) error {
+	return c.write(0x40, interval)
+}
+
+// Close closes the connection to the server.
+func (c *speedConn) Close() error {
+	return c.conn.Close()
+}
+
+// write writes a single message made up of a type and the fields that follow
+// it.  Strings are written with their length prefix.
+func (c *speedConn) write(kind uint8, fields ...any) error {
+	bs := []byte{kind}
+	for _, field := range fields {
+		switch f := field.(type) {
+		case string:
+			if len(f) > 255 {
+				return fmt.Errorf("string too long: %q", f)
+			}
+			bs = append(bs, uint8(len(f)))
+			bs = append(bs, f...)
+		case []uint16:
+			if len(f) > 255 {
+				return errors.New("too many roads")
+			}
+			bs = append(bs, uint8(len(f)))
+			for _, n := range f {
+				bs = binary.BigEndian.AppendUint16(bs, n)
+			}
+		case uint16:
+			bs = binary.BigEndian.AppendUint16(bs, f)
+		case uint32:
+			bs = binary.BigEndian.AppendUint32(bs, f)
+		}
+	}
+
+	_, err := c.conn.Write(bs)
+	return err
+}
+
+// read reads the next message from the server, which is either a *Ticket or
+// nil for a heartbeat.  An error message from the server is returned as a
+// ServerError.
+func (c *speedConn) read() (*Ticket, error) {
+	kind, err := c.reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case 0x10: // Error
+		msg, err := c.readString()
+		if err != nil {
+			return nil, err
+		}
+		return nil, ServerError(msg)
+
+	case 0x21: // Ticket
+		var t Ticket
+		if t.Plate, err = c.readString(); err != nil {
+			return nil, err
+		}
+
+		var fields [16]byte
+		if _, err := io.ReadFull(c.reader, fields[:]); err != nil {
+			return nil, err
+		}
+		t.Road = binary.BigEndian.Uint16(fields[0:])
+		t.Mile1 = binary.BigEndian.Uint16(fields[2:])
+		t.Timestamp1 = binary.BigEndian.Uint32(fields[4:])
+		t.Mile2 = binary.BigEndian.Uint16(fields[8:])
+		t.Timestamp2 = binary.BigEndian.Uint32(fields[10:])
+		t.Speed = binary.BigEndian.Uint16(fields[14:])
+		return &t, nil
+
+	case 0x41: // Heartbeat
+		return nil, nil
+
+	default:
+		return nil, fmt.Errorf("unexpected message type: %#02x", kind)
+	}
+}
+
+func (c *speedConn) readString() (string, error) {
+	length, err := c.reader.ReadByte()
+	if err != nil {
+		return "", err
+	}
+
+	bs := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, bs); err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+// =============================================================================
+
+// Camera is a Speed Daemon client that reports the plates it observes at a
+// fixed position on a road.
+type Camera struct {
+	speedConn
+}
+
+// DialCamera connects to the Speed Daemon server at addr and identifies as a
+// camera at mile on road, which has a speed limit of limit miles per hour.
+func DialCamera(addr string, road, mile, limit uint16) (*Camera, error) {
+	conn, err := dial(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	camera, err := NewCamera(conn, road, mile, limit)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return camera, nil
+}
+
+// NewCamera identifies as a camera on an existing connection to a Speed Daemon
+// server.
+func NewCamera(conn net.Conn, road, mile, limit uint16) (*Camera, error) {
+	c := &Camera{speedConn{conn: conn, reader: bufio.NewReader(conn)}}
+	if err := c.write(0x80, road, mile, limit); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Plate reports that the car with plate passed the camera at timestamp.
+func (c *Camera) Plate(plate string, timestamp uint32) error {
+	return c.write(0x20, plate, timestamp)
+}
+
+// Heartbeat waits for the next heartbeat from the server.  If the server sends
+// an error instead, e.g. because the camera misbehaved, it's returned as a
+// ServerError.
+func (c *Camera) Heartbeat() error {
+	ticket, err := c.read()
+	if err == nil && ticket != nil {
+		err = errors.New("unexpected ticket sent to camera")
+	}
+	return err
+}
+
+// =============================================================================
+
+// Dispatcher is a Speed Daemon client that receives the tickets issued on a
+// set of roads.
+type Dispatcher struct {
+	speedConn
+}
+
+// DialDispatcher connects to the Speed Daemon server at addr and identifies as
+// a dispatcher for roads.
+func DialDispatcher(addr string, roads ...uint16) (*Dispatcher, error) {
+	conn, err := dial(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	dispatcher, err := NewDispatcher(conn, roads...)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return dispatcher, nil
+}
+
+// NewDispatcher identifies as a dispatcher on an existing connection to a
+// Speed Daemon server.
+func NewDispatcher(conn net.Conn, roads ...uint16) (*Dispatcher, error) {
+	d := &Dispatcher{speedConn{conn: conn, reader: bufio.NewReader(conn)}}
+	if err := d.write(0x81, roads); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Ticket waits for the next ticket from the server, skipping over any
+// heartbeats.  If the server sends an error instead it's returned as a
+// ServerError.
+func (d *Dispatcher) Ticket() (Ticket, error) {
+	for {
+		ticket, err := d.read()
+		if err != nil {
+			return Ticket{}, err
+		}
+		if ticket != nil {
+			return *ticket, nil
+		}
+	}
+}
+
+// Heartbeat waits for the next heartbeat from the server.  It fails if a
+// ticket arrives first, so it should only be used when no tickets are
+// expected.
+func (d *Dispatcher) Heartbeat() error {
+	ticket, err := d.read()
+	if err == nil && ticket != nil {
+		err = fmt.Errorf("unexpected ticket for %s", ticket.Plate)
+	}
+	return err
+}
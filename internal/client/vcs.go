@@ -0,0 +1,198 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// VCSEntry is an entry in a Voracious Code Storage directory listing.
+type VCSEntry struct {
+	Name     string
+	Dir      bool
+	Revision int // The latest revision of a file
+}
+
+// VCSClient is a Voracious Code Storage client.
+type VCSClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// DialVCS connects to the Voracious Code Storage server at addr.
+func DialVCS(addr string) (*VCSClient, error) {
+	conn, err := dial(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := NewVCSClient(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// NewVCSClient returns a client that uses an existing connection to a
+// Voracious Code Storage server.  It waits for the server to say that it's
+// ready.
+func NewVCSClient(conn net.Conn) (*VCSClient, error) {
+	c := &VCSClient{conn: conn, reader: bufio.NewReader(conn)}
+	if err := c.ready(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Put stores data as the newest revision of file and returns the revision.
+func (c *VCSClient) Put(file string, data []byte) (int, error) {
+	if _, err := fmt.Fprintf(c.conn, "PUT %s %d\n", file, len(data)); err != nil {
+		return 0, err
+	}
+	if _, err := c.conn.Write(data); err != nil {
+		return 0, err
+	}
+
+	status, err := c.response()
+	if err != nil {
+		return 0, err
+	}
+
+	revision, err := parseRevision(status)
+	if err != nil {
+		return 0, err
+	}
+	return revision, c.ready()
+}
+
+// Get returns a revision of file, or the newest revision if revision is 0.
+func (c *VCSClient) Get(file string, revision int) ([]byte, error) {
+	var err error
+	if revision == 0 {
+		_, err = fmt.Fprintf(c.conn, "GET %s\n", file)
+	} else {
+		_, err = fmt.Fprintf(c.conn, "GET %s r%d\n", file, revision)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := c.response()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(status)
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid length: %q", status)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, data); err != nil {
+		return nil, err
+	}
+	return data, c.ready()
+}
+
+// List returns the entries in dir, sorted by name.
+func (c *VCSClient) List(dir string) ([]VCSEntry, error) {
+	if _, err := fmt.Fprintf(c.conn, "LIST %s\n", dir); err != nil {
+		return nil, err
+	}
+
+	status, err := c.response()
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := strconv.Atoi(status)
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("invalid count: %q", status)
+	}
+
+	entries := make([]VCSEntry, 0, count)
+	for i := 0; i < count; i++ {
+		line, err := c.line()
+		if err != nil {
+			return nil, err
+		}
+
+		name, detail, found := strings.Cut(line, " ")
+		if !found {
+			return nil, fmt.Errorf("invalid entry: %q", line)
+		}
+
+		if detail == "DIR" {
+			entries = append(entries, VCSEntry{Name: strings.TrimSuffix(name, "/"), Dir: true})
+			continue
+		}
+
+		revision, err := parseRevision(detail)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, VCSEntry{Name: name, Revision: revision})
+	}
+
+	return entries, c.ready()
+}
+
+// Close closes the connection to the server.
+func (c *VCSClient) Close() error {
+	return c.conn.Close()
+}
+
+// response reads the response to a command, returning what follows the OK.
+// An ERR response is returned as a ServerError after waiting for the server
+// to be ready for the next command.
+func (c *VCSClient) response() (string, error) {
+	line, err := c.line()
+	if err != nil {
+		return "", err
+	}
+
+	if msg, found := strings.CutPrefix(line, "ERR "); found {
+		if err := c.ready(); err != nil {
+			return "", err
+		}
+		return "", ServerError(msg)
+	}
+
+	status, found := strings.CutPrefix(line, "OK ")
+	if !found {
+		return "", fmt.Errorf("unexpected response: %q", line)
+	}
+	return status, nil
+}
+
+// ready waits for the server to say that it's ready for the next command.
+func (c *VCSClient) ready() error {
+	line, err := c.line()
+	if err != nil {
+		return err
+	}
+	if line != "READY" {
+		return fmt.Errorf("unexpected response: %q", line)
+	}
+	return nil
+}
+
+func (c *VCSClient) line() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+func parseRevision(s string) (int, error) {
+	revision, err := strconv.Atoi(strings.TrimPrefix(s, "r"))
+	if err != nil || !strings.HasPrefix(s, "r") {
+		return 0, fmt.Errorf("invalid revision: %q", s)
+	}
+	return revision, nil
+}
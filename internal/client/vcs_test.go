@@ -0,0 +1,111 @@
+package client_test
+
+import (
+	"errors"
+	"github.com/bbeck/protohackers/internal/client"
+	"github.com/bbeck/protohackers/internal/problem10"
+	"github.com/bbeck/protohackers/internal/servertest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func vcs(t *testing.T) *client.VCSClient {
+	t.Helper()
+
+	addr := servertest.Start(t, problem10.Problem)
+	conn := servertest.Dial(t, "tcp", addr)
+	_ = conn.SetDeadline(time.Now().Add(servertest.Timeout))
+
+	c, err := client.NewVCSClient(conn)
+	if err != nil {
+		t.Fatalf("error waiting for server: %v", err)
+	}
+	return c
+}
+
+func put(t *testing.T, c *client.VCSClient, file, data string, want int) {
+	t.Helper()
+
+	revision, err := c.Put(file, []byte(data))
+	if err != nil {
+		t.Fatalf("error putting %s: %v", file, err)
+	}
+	if revision != want {
+		t.Fatalf("Put(%s) = r%d, want r%d", file, revision, want)
+	}
+}
+
+func get(t *testing.T, c *client.VCSClient, file string, revision int, want string) {
+	t.Helper()
+
+	data, err := c.Get(file, revision)
+	if err != nil {
+		t.Fatalf("error getting %s: %v", file, err)
+	}
+	if string(data) != want {
+		t.Fatalf("Get(%s, %d) = %q, want %q", file, revision, data, want)
+	}
+}
+
+func TestVCSClient(t *testing.T) {
+	c := vcs(t)
+
+	put(t, c, "/a.txt", "hello\n", 1)
+	put(t, c, "/a.txt", "world\n", 2)
+	put(t, c, "/a.txt", "world\n", 2)
+	put(t, c, "/dir/b.txt", "b\n", 1)
+
+	get(t, c, "/a.txt", 0, "world\n")
+	get(t, c, "/a.txt", 1, "hello\n")
+	get(t, c, "/dir/b.txt", 0, "b\n")
+
+	// Directories are listed without their trailing slash.
+	entries, err := c.List("/")
+	if err != nil {
+		t.Fatalf("error listing /: %v", err)
+	}
+	want := []client.VCSEntry{
+		{Name: "a.txt", Revision: 2},
+		{Name: "dir", Dir: true},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("unexpected entries\nwant: %+v\n got: %+v", want, entries)
+	}
+
+	entries, err = c.List("/missing")
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("List(/missing) = %+v, %v, want no entries", entries, err)
+	}
+}
+
+func TestVCSClientErrors(t *testing.T) {
+	c := vcs(t)
+
+	put(t, c, "/a.txt", "hello\n", 1)
+
+	// After an error the client waits for the server to be ready again, so
+	// the next command reads its own response.
+	tests := []struct {
+		name string
+		run  func() error
+		want client.ServerError
+	}{
+		{"no such revision", func() error { _, err := c.Get("/a.txt", 5); return err }, "no such revision"},
+		{"no such file", func() error { _, err := c.Get("/b.txt", 0); return err }, "no such revision"},
+		{"illegal file name", func() error { _, err := c.Get("a.txt", 0); return err }, "illegal file name"},
+		{"usage", func() error { _, err := c.List("/a b"); return err }, "usage: LIST dir"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.run()
+			var serverErr client.ServerError
+			if !errors.As(err, &serverErr) || serverErr != test.want {
+				t.Fatalf("error = %v, want %v", err, test.want)
+			}
+
+			get(t, c, "/a.txt", 0, "hello\n")
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package internal
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvironmentPrefix is prepended to the upper-cased name of a flag to form the
+// name of the environment variable that can also be used to set it.
+const EnvironmentPrefix = "PROTOHACKERS_"
+
+// Config holds the settings shared by every problem server.  The zero value
+// for Config listens on an ephemeral port on all interfaces.
+type Config struct {
+	// Host is the host or IP address to listen on.  If empty the server listens
+	// on all available interfaces.
+	Host string
+
+	// Port is the port to listen on.  If zero an ephemeral port is chosen and
+	// reported once the server is listening.
+	Port int
+
+	// Network selects which IP versions to listen with, one of "dual", "ipv4"
+	// or "ipv6".  If empty "dual" is used.
+	Network string
+
+	// ShutdownTimeout is how long to wait for in-flight handlers to finish once
+	// the server is asked to stop.  If zero DefaultShutdownTimeout is used.
+	ShutdownTimeout time.Duration
+
+	// MaxConnections limits how many TCP connections may be open at once.  If
+	// zero the number of connections is unlimited.
+	MaxConnections int
+
+	// ConnectionPolicy decides what happens to new TCP connections once
+	// MaxConnections are open, either RejectPolicy or QueuePolicy.  If empty
+	// RejectPolicy is used.
+	ConnectionPolicy string
+
+	// IdleTimeout limits how long a read from a TCP connection may wait for
+	// data.  If zero reads may wait forever.
+	IdleTimeout time.Duration
+
+	// WriteTimeout limits how long a write to a TCP connection may take.  If
+	// zero writes may take forever.
+	WriteTimeout time.Duration
+
+	// MaxLifetime limits how long a TCP connection may stay open.  Once it has
+	// passed all reads and writes on the connection fail.  If zero connections
+	// may stay open forever.
+	MaxLifetime time.Duration
+
+	// UDPWorkers is the number of goroutines that handle UDP datagrams
+	// concurrently.  If zero a single goroutine is used.
+	UDPWorkers int
+
+	// OrderBySource guarantees that UDP datagrams from the same address are
+	// handled one at a time, in the order they were received.
+	OrderBySource bool
+
+	// LogLevel is the minimum level of the log records that are written.
+	// Protocol events are logged at debug level.
+	LogLevel slog.Level
+
+	// LogFormat is the format log records are written in, either "text" or
+	// "json".  If empty "text" is used.
+	LogFormat string
+
+	// MetricsAddress is the address to serve metrics over HTTP on.  If empty
+	// metrics aren't served.
+	MetricsAddress string
+
+	// TLSCertFile and TLSKeyFile are the PEM encoded certificate and private
+	// key to terminate TLS on TCP connections with.  If empty TLS isn't used,
+	// unless TLSSelfSigned is set.
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// TLSSelfSigned terminates TLS on TCP connections using a freshly generated
+	// self-signed certificate.  This is only meant for local testing.
+	TLSSelfSigned bool
+
+	// TLSClientCAFile is a PEM encoded bundle of certificate authorities.  If
+	// set clients must present a certificate signed by one of them.
+	TLSClientCAFile string
+
+	// ProxyProtocol requires every TCP connection to start with a PROXY
+	// protocol v1 or v2 header, as sent by a load balancer, and reports the
+	// client address from the header as the connection's remote address.
+	ProxyProtocol bool
+
+	// CaptureDir is a directory to record the traffic of every TCP connection
+	// in, one file per connection in the format described by CaptureHeader and
+	// CaptureFrame.  If empty traffic isn't recorded.
+	CaptureDir string
+}
+
+const (
+	// RejectPolicy closes new connections immediately when the server is full.
+	RejectPolicy = "reject"
+
+	// QueuePolicy leaves new connections waiting to be accepted until a slot
+	// frees up when the server is full.
+	QueuePolicy = "queue"
+)
+
+// DefaultConfig returns the configuration used when no flags or environment
+// variables are set.
+func DefaultConfig() Config {
+	return Config{
+		Port:             40000,
+		Network:          "dual",
+		ShutdownTimeout:  DefaultShutdownTimeout,
+		ConnectionPolicy: RejectPolicy,
+		UDPWorkers:       runtime.GOMAXPROCS(0),
+		LogLevel:         slog.LevelInfo,
+		LogFormat:        "text",
+	}
+}
+
+// RegisterFlags defines a flag on fs for each setting, using the current value
+// of the setting as the flag's default.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Host, "host", c.Host, "host or IP address to listen on, empty for all interfaces")
+	fs.IntVar(&c.Port, "port", c.Port, "port to listen on, 0 for an ephemeral port")
+	fs.StringVar(&c.Network, "network", c.Network, "IP versions to listen with: dual, ipv4 or ipv6")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "how long to wait for handlers to finish when stopping")
+	fs.IntVar(&c.MaxConnections, "max-connections", c.MaxConnections, "maximum number of open TCP connections, 0 for unlimited")
+	fs.StringVar(&c.ConnectionPolicy, "connection-policy", c.ConnectionPolicy, "what to do with connections beyond the maximum: reject or queue")
+	fs.DurationVar(&c.IdleTimeout, "idle-timeout", c.IdleTimeout, "how long a TCP read may wait for data, 0 for forever")
+	fs.DurationVar(&c.WriteTimeout, "write-timeout", c.WriteTimeout, "how long a TCP write may take, 0 for forever")
+	fs.DurationVar(&c.MaxLifetime, "max-lifetime", c.MaxLifetime, "how long a TCP connection may stay open, 0 for forever")
+	fs.IntVar(&c.UDPWorkers, "udp-workers", c.UDPWorkers, "number of goroutines handling UDP datagrams")
+	fs.BoolVar(&c.OrderBySource, "order-by-source", c.OrderBySource, "handle UDP datagrams from the same address in order")
+	fs.TextVar(&c.LogLevel, "log-level", c.LogLevel, "minimum level of log records to write: debug, info, warn or error")
+	fs.StringVar(&c.LogFormat, "log-format", c.LogFormat, "format to write log records in: text or json")
+	fs.StringVar(&c.MetricsAddress, "metrics-address", c.MetricsAddress, "address to serve Prometheus metrics on at /metrics, empty to disable")
+	fs.StringVar(&c.TLSCertFile, "tls-cert", c.TLSCertFile, "PEM certificate file to terminate TLS with")
+	fs.StringVar(&c.TLSKeyFile, "tls-key", c.TLSKeyFile, "PEM private key file to terminate TLS with")
+	fs.BoolVar(&c.TLSSelfSigned, "tls-self-signed", c.TLSSelfSigned, "terminate TLS with a generated self-signed certificate")
+	fs.StringVar(&c.TLSClientCAFile, "tls-client-ca", c.TLSClientCAFile, "PEM CA bundle that client certificates must be signed by")
+	fs.BoolVar(&c.ProxyProtocol, "proxy-protocol", c.ProxyProtocol, "require a PROXY protocol header on every TCP connection")
+	fs.StringVar(&c.CaptureDir, "capture-dir", c.CaptureDir, "directory to record the traffic of every TCP connection in, empty to disable")
+}
+
+// Validate returns an error if any of the settings are invalid.
+func (c *Config) Validate() error {
+	if c.Port < 0 || c.Port > 65535 {
+		return fmt.Errorf("invalid port: %d", c.Port)
+	}
+
+	switch c.Network {
+	case "", "dual", "ipv4", "ipv6":
+	default:
+		return fmt.Errorf("invalid network: %q", c.Network)
+	}
+
+	if c.ShutdownTimeout < 0 {
+		return fmt.Errorf("invalid shutdown timeout: %v", c.ShutdownTimeout)
+	}
+
+	if c.MaxConnections < 0 {
+		return fmt.Errorf("invalid max connections: %d", c.MaxConnections)
+	}
+
+	switch c.ConnectionPolicy {
+	case "", RejectPolicy, QueuePolicy:
+	default:
+		return fmt.Errorf("invalid connection policy: %q", c.ConnectionPolicy)
+	}
+
+	if c.IdleTimeout < 0 || c.WriteTimeout < 0 || c.MaxLifetime < 0 {
+		return fmt.Errorf("invalid timeout: timeouts must not be negative")
+	}
+
+	if c.UDPWorkers < 0 {
+		return fmt.Errorf("invalid UDP workers: %d", c.UDPWorkers)
+	}
+
+	switch c.LogFormat {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("invalid log format: %q", c.LogFormat)
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("invalid TLS configuration: both a certificate and key are required")
+	}
+
+	if c.TLSSelfSigned && c.TLSCertFile != "" {
+		return fmt.Errorf("invalid TLS configuration: a certificate can't be given when self-signing")
+	}
+
+	if c.TLSClientCAFile != "" && !c.TLSEnabled() {
+		return fmt.Errorf("invalid TLS configuration: a client CA requires TLS to be enabled")
+	}
+
+	if c.CaptureDir != "" {
+		if info, err := os.Stat(c.CaptureDir); err != nil || !info.IsDir() {
+			return fmt.Errorf("invalid capture directory: %q is not a directory", c.CaptureDir)
+		}
+	}
+
+	return nil
+}
+
+// Address returns the host and port to listen on.
+func (c *Config) Address() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// TCPNetwork returns the name of the network to use when listening for TCP
+// connections.
+func (c *Config) TCPNetwork() string {
+	return "tcp" + c.networkSuffix()
+}
+
+// UDPNetwork returns the name of the network to use when listening for UDP
+// datagrams.
+func (c *Config) UDPNetwork() string {
+	return "udp" + c.networkSuffix()
+}
+
+func (c *Config) networkSuffix() string {
+	switch c.Network {
+	case "ipv4":
+		return "4"
+	case "ipv6":
+		return "6"
+	default:
+		return ""
+	}
+}
+
+// ParseConfig parses the server configuration from args using fs, starting
+// from DefaultConfig.  Any other flags that have already been defined on fs are
+// parsed as well.  Every flag can also be set with an environment variable, see
+// ApplyEnvironment.
+func ParseConfig(fs *flag.FlagSet, args []string) (Config, error) {
+	config := DefaultConfig()
+	config.RegisterFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return config, err
+	}
+
+	if err := ApplyEnvironment(fs); err != nil {
+		return config, err
+	}
+
+	return config, config.Validate()
+}
+
+// ApplyEnvironment sets every flag in fs that wasn't given on the command line
+// from its environment variable, if present.  The environment variable for a
+// flag is its name upper-cased with dashes replaced by underscores, prefixed
+// by EnvironmentPrefix, so -shutdown-timeout becomes
+// PROTOHACKERS_SHUTDOWN_TIMEOUT.  Flags on the command line take precedence.
+func ApplyEnvironment(fs *flag.FlagSet) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || given[f.Name] {
+			return
+		}
+
+		name := EnvironmentPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if value, ok := os.LookupEnv(name); ok {
+			if e := fs.Set(f.Name, value); e != nil {
+				err = fmt.Errorf("invalid value %q for %s: %w", value, name, e)
+			}
+		}
+	})
+
+	return err
+}
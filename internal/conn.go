@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// conn wraps a connection accepted by a Server so that the server's idle,
+// write and lifetime limits are applied to every read and write, no matter
+// which handler is using the connection.
+type conn struct {
+	net.Conn
+
+	id      uint64
+	logger  *slog.Logger
+	started time.Time
+	metrics *metrics
+
+	idleTimeout  time.Duration
+	writeTimeout time.Duration
+	expires      time.Time // The end of the connection's lifetime, if limited
+	capture      *capture  // Records the connection's traffic, if enabled
+
+	mutex       sync.Mutex
+	interrupted bool // Whether reads have been interrupted by a shutdown
+	failed      bool // Whether an error has been logged for the connection
+}
+
+func newConn(c net.Conn, config *Config, logger *slog.Logger, metrics *metrics) *conn {
+	id := nextConnID.Add(1)
+
+	wrapped := &conn{
+		Conn:         c,
+		id:           id,
+		logger:       logger.With("conn_id", id, "remote_addr", c.RemoteAddr().String()),
+		started:      time.Now(),
+		metrics:      metrics,
+		idleTimeout:  config.IdleTimeout,
+		writeTimeout: config.WriteTimeout,
+	}
+	if config.MaxLifetime > 0 {
+		wrapped.expires = wrapped.started.Add(config.MaxLifetime)
+	}
+
+	return wrapped
+}
+
+func (c *conn) Read(bs []byte) (int, error) {
+	if c.idleTimeout > 0 || !c.expires.IsZero() {
+		c.mutex.Lock()
+		if !c.interrupted {
+			_ = c.Conn.SetReadDeadline(c.deadline(c.idleTimeout))
+		}
+		c.mutex.Unlock()
+	}
+
+	n, err := c.Conn.Read(bs)
+	c.metrics.bytesReceived.Add(n)
+	if n > 0 {
+		c.record(CaptureIn, bs[:n], false)
+	}
+	if errors.Is(err, io.EOF) {
+		c.record(CaptureIn, nil, true)
+	}
+	c.check(err)
+	return n, err
+}
+
+func (c *conn) Write(bs []byte) (int, error) {
+	if c.writeTimeout > 0 || !c.expires.IsZero() {
+		_ = c.Conn.SetWriteDeadline(c.deadline(c.writeTimeout))
+	}
+
+	n, err := c.Conn.Write(bs)
+	c.metrics.bytesSent.Add(n)
+	if n > 0 {
+		c.record(CaptureOut, bs[:n], false)
+	}
+	c.check(err)
+	return n, err
+}
+
+// record writes a frame to the connection's capture file, if it has one.  If
+// the frame can't be written the error is logged and capturing stops.
+func (c *conn) record(direction string, data []byte, eof bool) {
+	if c.capture == nil {
+		return
+	}
+
+	if err := c.capture.record(direction, data, eof); err != nil {
+		c.logger.Warn("error capturing traffic", "error", err)
+		_ = c.capture.close()
+	}
+}
+
+// check logs the first unexpected error that happens on the connection.  The
+// end of the stream, the connection being closed and reads interrupted by a
+// shutdown are all expected.
+func (c *conn) check(err error) {
+	if err == nil || errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.interrupted || c.failed {
+		return
+	}
+
+	c.failed = true
+	c.logger.Warn("connection error", "error", err)
+}
+
+// interrupt causes any pending and future reads on the connection to fail.
+func (c *conn) interrupt() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.interrupted = true
+	_ = c.Conn.SetReadDeadline(time.Now())
+}
+
+// deadline returns the deadline for an operation that may take at most timeout
+// (if non-zero) and must finish before the end of the connection's lifetime.
+func (c *conn) deadline(timeout time.Duration) time.Time {
+	deadline := c.expires
+	if timeout > 0 {
+		if d := time.Now().Add(timeout); deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+		}
+	}
+
+	return deadline
+}
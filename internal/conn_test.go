@@ -0,0 +1,191 @@
+package internal_test
+
+import (
+	"bufio"
+	"errors"
+	"github.com/bbeck/protohackers/internal"
+	"github.com/bbeck/protohackers/internal/servertest"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMaxConnectionsReject(t *testing.T) {
+	addr := serve(t, echo, func(config *internal.Config) {
+		config.MaxConnections = 2
+		config.ConnectionPolicy = internal.RejectPolicy
+	})
+
+	alice := servertest.Dial(t, "tcp", addr)
+	alice.SendString("alice\n")
+	alice.ExpectString("alice\n")
+	bob := servertest.Dial(t, "tcp", addr)
+	bob.SendString("bob\n")
+	bob.ExpectString("bob\n")
+
+	// The server is full, so the next connection is closed straight away.
+	carol := servertest.Dial(t, "tcp", addr)
+	carol.ExpectClosed()
+
+	// Once a connection closes its slot is free again, the handler may take a
+	// moment to notice.
+	_ = alice.Close()
+	deadline := time.Now().Add(servertest.Timeout)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("error connecting: %v", err)
+		}
+		_, _ = io.WriteString(conn, "dave\n")
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		_ = conn.Close()
+
+		if line == "dave\n" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("connection was still rejected after another one closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	bob.SendString("bob\n")
+	bob.ExpectString("bob\n")
+}
+
+func TestMaxConnectionsQueue(t *testing.T) {
+	addr := serve(t, echo, func(config *internal.Config) {
+		config.MaxConnections = 1
+		config.ConnectionPolicy = internal.QueuePolicy
+	})
+
+	alice := servertest.Dial(t, "tcp", addr)
+	alice.SendString("alice\n")
+	alice.ExpectString("alice\n")
+
+	// The server is full, so the next connection waits without being handled.
+	bob := servertest.Dial(t, "tcp", addr)
+	bob.SendString("bob\n")
+	bob.ExpectNothing(100 * time.Millisecond)
+
+	// Until a connection closes and it takes its slot.
+	_ = alice.Close()
+	bob.ExpectString("bob\n")
+
+	carol := servertest.Dial(t, "tcp", addr)
+	carol.SendString("carol\n")
+	carol.ExpectNothing(100 * time.Millisecond)
+}
+
+// record is a handler that echoes everything it reads until a read or write
+// fails, and then sends the error to errs.
+func record(errs chan<- error) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		defer conn.Close()
+
+		_, err := io.Copy(conn, conn)
+		errs <- err
+	}
+}
+
+// expectError waits for the handler to report an error and checks that it's
+// want.
+func expectError(t *testing.T, errs <-chan error, want error) {
+	t.Helper()
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, want) {
+			t.Fatalf("handler error = %v, want %v", err, want)
+		}
+	case <-time.After(servertest.Timeout):
+		t.Fatal("timed out waiting for the handler to fail")
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	errs := make(chan error, 1)
+	addr := serve(t, record(errs), func(config *internal.Config) {
+		config.IdleTimeout = 200 * time.Millisecond
+	})
+
+	// A client that keeps sending data within the timeout stays connected, even
+	// though it's connected for longer than the timeout.
+	conn := servertest.Dial(t, "tcp", addr)
+	for i := 0; i < 5; i++ {
+		conn.SendString("hello\n")
+		conn.ExpectString("hello\n")
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// Once the client goes quiet the handler's read times out.
+	start := time.Now()
+	expectError(t, errs, os.ErrDeadlineExceeded)
+	conn.ExpectClosed()
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("connection closed %v after the client went quiet, want about 150ms", elapsed)
+	}
+}
+
+func TestWriteTimeout(t *testing.T) {
+	errs := make(chan error, 1)
+	addr := serve(t, func(conn net.Conn) {
+		defer conn.Close()
+
+		// Write until the client's buffers are full and a write can't finish.
+		bs := make([]byte, 64*1024)
+		for {
+			if _, err := conn.Write(bs); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}, func(config *internal.Config) {
+		config.WriteTimeout = 100 * time.Millisecond
+	})
+
+	// The client never reads.
+	servertest.Dial(t, "tcp", addr)
+	expectError(t, errs, os.ErrDeadlineExceeded)
+}
+
+func TestMaxLifetime(t *testing.T) {
+	errs := make(chan error, 1)
+	addr := serve(t, record(errs), func(config *internal.Config) {
+		config.MaxLifetime = 300 * time.Millisecond
+	})
+
+	// Even a client that's never idle is disconnected at the end of the
+	// connection's lifetime.
+	start := time.Now()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("error connecting: %v", err)
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	var exchanges int
+	for time.Since(start) < servertest.Timeout {
+		_ = conn.SetDeadline(time.Now().Add(servertest.Timeout))
+		if _, err := io.WriteString(conn, "hello\n"); err != nil {
+			break
+		}
+		if _, err := reader.ReadString('\n'); err != nil {
+			break
+		}
+		exchanges++
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	expectError(t, errs, os.ErrDeadlineExceeded)
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("connection lasted %v, want about 300ms", elapsed)
+	}
+	if exchanges < 5 {
+		t.Errorf("only %d lines were echoed before the connection closed", exchanges)
+	}
+}
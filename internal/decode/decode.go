@@ -0,0 +1,167 @@
+// Package decode turns the bytes of the binary and packet based protocols
+// back into readable messages, flagging anything that a server would reject
+// such as bad checksums, truncated messages and unknown message types.
+package decode
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Message is a single message decoded from a stream or datagram.
+type Message struct {
+	// Offset is the position of the message's first byte in the stream.
+	Offset int
+
+	// Raw is the bytes of the message.
+	Raw []byte
+
+	// Text describes the message and its fields, for example
+	// Plate{plate: "UN1X", timestamp: 1000}.
+	Text string
+
+	// Issue explains what's wrong with the message, if anything.
+	Issue string
+}
+
+// Protocol decodes the messages of one of the problems.
+type Protocol struct {
+	// ID is the number of the problem that uses the protocol.
+	ID int
+
+	// Name is a short name for the protocol.
+	Name string
+
+	// Datagram is true if each packet of the protocol is a message of its own,
+	// rather than messages being sent over a stream.
+	Datagram bool
+
+	// Decode decodes every message in bs, a stream or a single datagram, which
+	// was sent by the client if fromClient is true and by the server otherwise.
+	Decode func(bs []byte, fromClient bool) []Message
+}
+
+// Protocols are the protocols that can be decoded, in problem order.
+var Protocols = []*Protocol{
+	{ID: 2, Name: "means", Decode: decodeMeans},
+	{ID: 6, Name: "speed", Decode: decodeSpeed},
+	{ID: 7, Name: "lrcp", Datagram: true, Decode: decodeLRCP},
+	{ID: 11, Name: "pestcontrol", Decode: decodePestControl},
+}
+
+// Lookup returns the protocol with the given problem ID or name, or nil if
+// there isn't one.
+func Lookup(name string) *Protocol {
+	for _, protocol := range Protocols {
+		if name == protocol.Name || name == fmt.Sprint(protocol.ID) || name == fmt.Sprintf("%02d", protocol.ID) {
+			return protocol
+		}
+	}
+	return nil
+}
+
+// Names returns the names of the protocols, for use in usage messages.
+func Names() []string {
+	var names []string
+	for _, protocol := range Protocols {
+		names = append(names, fmt.Sprintf("%02d (%s)", protocol.ID, protocol.Name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// reader reads the big-endian fields of a message, remembering if it ran out
+// of bytes so that fields can be read without checking each one.
+type reader struct {
+	bs    []byte
+	pos   int
+	short bool // Whether a read ran past the end of bs
+
+	// name is the name of the message being read, used to describe it if it's
+	// truncated.
+	name string
+}
+
+func (r *reader) take(n int) []byte {
+	if r.short || len(r.bs)-r.pos < n {
+		r.short = true
+		return make([]byte, n)
+	}
+
+	bs := r.bs[r.pos : r.pos+n]
+	r.pos += n
+	return bs
+}
+
+func (r *reader) u8() uint8 {
+	return r.take(1)[0]
+}
+
+func (r *reader) u16() uint16 {
+	return binary.BigEndian.Uint16(r.take(2))
+}
+
+func (r *reader) u32() uint32 {
+	return binary.BigEndian.Uint32(r.take(4))
+}
+
+func (r *reader) i32() int32 {
+	return int32(r.u32())
+}
+
+// rest skips the remainder of the stream, for when there's no way of finding
+// the start of the next message.
+func (r *reader) rest() {
+	r.pos = len(r.bs)
+}
+
+// decodeStream decodes every message in bs by calling next to read each one
+// in turn.  next returns the message's description and any issue with it.  A
+// message that runs past the end of the stream is reported as truncated.
+func decodeStream(bs []byte, next func(r *reader) (text string, issue string)) []Message {
+	var messages []Message
+
+	r := &reader{bs: bs}
+	for r.pos < len(bs) {
+		start := r.pos
+		r.name = ""
+
+		text, issue := next(r)
+		if r.short {
+			r.rest()
+			text = r.name
+			issue = fmt.Sprintf("truncated after %d bytes", len(bs)-start)
+		}
+
+		messages = append(messages, Message{
+			Offset: start,
+			Raw:    bs[start:r.pos],
+			Text:   text,
+			Issue:  issue,
+		})
+	}
+
+	return messages
+}
+
+// fields formats a message as its name followed by its fields, which are
+// given as alternating names and values.
+func fields(name string, kvs ...any) string {
+	if len(kvs) == 0 {
+		return name
+	}
+
+	var sb strings.Builder
+	sb.WriteString(name)
+	sb.WriteString("{")
+	for i := 0; i+1 < len(kvs); i += 2 {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(&sb, "%s: %v", kvs[i], kvs[i+1])
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
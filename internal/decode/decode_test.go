@@ -0,0 +1,179 @@
+package decode_test
+
+import (
+	"encoding/hex"
+	"github.com/bbeck/protohackers/internal/decode"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name       string
+		protocol   string
+		fromClient bool
+		hex        string
+		want       []string // Text, followed by " ! " and the issue if there is one
+	}{
+		{
+			name:       "means insert and query",
+			protocol:   "02",
+			fromClient: true,
+			hex:        "49 00003039 00000065  51 000003e8 000186a0",
+			want:       []string{"Insert{timestamp: 12345, price: 101}", "Query{mintime: 1000, maxtime: 100000}"},
+		},
+		{
+			name:       "means attach, aggregates and delete",
+			protocol:   "02",
+			fromClient: true,
+			hex:        "41 6161706c 00000000  4d 00000001 00000002  56 00000001 00000002  44 00000003 00000000",
+			want:       []string{`Attach{series: "aapl"}`, "Median{mintime: 1, maxtime: 2}", "StdDev{mintime: 1, maxtime: 2}", "Delete{timestamp: 3}"},
+		},
+		{
+			name:       "means unknown type",
+			protocol:   "02",
+			fromClient: true,
+			hex:        "58 00000000 00000000  49 ffffffff 00000001",
+			want:       []string{"Unknown{type: 0x58, a: 0, b: 0} ! unknown message type 0x58", "Insert{timestamp: -1, price: 1}"},
+		},
+		{
+			name:     "means response",
+			protocol: "means",
+			hex:      "00000065 ffffff",
+			want:     []string{"Answer{value: 101}", "Answer ! truncated after 3 bytes"},
+		},
+		{
+			name:       "speed camera",
+			protocol:   "06",
+			fromClient: true,
+			hex:        "80 0042 0064 003c  20 04 554e3158 000003e8  40 0000000a",
+			want: []string{
+				"IAmCamera{road: 66, mile: 100, limit: 60}",
+				`Plate{plate: "UN1X", timestamp: 1000}`,
+				"WantHeartbeat{interval: 10}",
+			},
+		},
+		{
+			name:       "speed dispatcher",
+			protocol:   "06",
+			fromClient: true,
+			hex:        "81 03 0042 0170 1388",
+			want:       []string{"IAmDispatcher{roads: [66, 368, 5000]}"},
+		},
+		{
+			name:     "speed ticket",
+			protocol: "06",
+			hex:      "21 04 554e3158 0042 0064 0001e240 006e 0001e3a8 2710  41",
+			want: []string{
+				`Ticket{plate: "UN1X", road: 66, mile1: 100, timestamp1: 123456, mile2: 110, timestamp2: 123816, speed: 100.00}`,
+				"Heartbeat",
+			},
+		},
+		{
+			name:     "speed message from the wrong side",
+			protocol: "06",
+			hex:      "40 0000000a",
+			want:     []string{"WantHeartbeat{interval: 10} ! WantHeartbeat messages aren't sent by the server"},
+		},
+		{
+			name:       "speed unknown type",
+			protocol:   "06",
+			fromClient: true,
+			hex:        "99 0102 40 0000000a",
+			want:       []string{"Unknown{type: 0x99} ! unknown message type 0x99, the rest of the stream can't be decoded"},
+		},
+		{
+			name:       "speed truncated",
+			protocol:   "06",
+			fromClient: true,
+			hex:        "20 04 554e",
+			want:       []string{"Plate ! truncated after 4 bytes"},
+		},
+		{
+			name:       "pest control hello",
+			protocol:   "11",
+			fromClient: true,
+			hex:        "50 00000019 0000000b 70657374636f6e74726f6c 00000001 ce",
+			want:       []string{`Hello{protocol: "pestcontrol", version: 1}`},
+		},
+		{
+			name:       "pest control site visit",
+			protocol:   "11",
+			fromClient: true,
+			hex:        "58 00000024 00003039 00000002 00000003 646f67 00000001 00000003 726174 00000005 8c",
+			want:       []string{`SiteVisit{site: 12345, populations: [{species: "dog", count: 1}, {species: "rat", count: 5}]}`},
+		},
+		{
+			name:     "pest control target populations",
+			protocol: "11",
+			hex:      "54 0000002c 00003039 00000002 00000003 646f67 00000001 00000003 00000003 726174 00000000 0000000a 80",
+			want:     []string{`TargetPopulations{site: 12345, populations: [{species: "dog", min: 1, max: 3}, {species: "rat", min: 0, max: 10}]}`},
+		},
+		{
+			name:     "pest control checksum failure",
+			protocol: "11",
+			hex:      "55 0000000e 00000003 646f67 a0 c1  52 00000006 a8",
+			want: []string{
+				`CreatePolicy{species: "dog", action: conserve} ! checksum failure, the bytes sum to 0x01`,
+				"OK",
+			},
+		},
+		{
+			name:     "pest control unknown type",
+			protocol: "11",
+			hex:      "60 00000006 9a  52 00000006 a8",
+			want:     []string{"Unknown{type: 0x60} ! unknown message type 0x60", "OK"},
+		},
+		{
+			name:     "pest control unused payload",
+			protocol: "11",
+			hex:      "57 0000000b 0000007b 00 23",
+			want:     []string{"PolicyResult{policy: 123} ! 1 unused bytes at the end of the payload"},
+		},
+		{
+			name:     "pest control truncated",
+			protocol: "11",
+			hex:      "57 0000000a 0000",
+			want:     []string{"PolicyResult ! truncated after 7 bytes"},
+		},
+		{
+			name:     "lrcp data",
+			protocol: "07",
+			hex:      hex.EncodeToString([]byte(`/data/123/0/hello\/world\\/`)),
+			want:     []string{`Data{session: 123, pos: 0, data: "hello/world\\"}`},
+		},
+		{
+			name:     "lrcp unknown type",
+			protocol: "lrcp",
+			hex:      hex.EncodeToString([]byte(`/bogus/1/`)),
+			want:     []string{`"/bogus/1/" ! unknown message type`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			protocol := decode.Lookup(test.protocol)
+			if protocol == nil {
+				t.Fatalf("unknown protocol: %s", test.protocol)
+			}
+
+			bs, err := hex.DecodeString(strings.Join(strings.Fields(test.hex), ""))
+			if err != nil {
+				t.Fatalf("invalid hex: %v", err)
+			}
+
+			var got []string
+			for _, message := range protocol.Decode(bs, test.fromClient) {
+				if message.Issue != "" {
+					got = append(got, message.Text+" ! "+message.Issue)
+				} else {
+					got = append(got, message.Text)
+				}
+			}
+
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("unexpected messages\ngot:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package decode
+
+import (
+	"github.com/bbeck/protohackers/internal/problem07"
+	"strconv"
+)
+
+// decodeLRCP decodes a single LRCP packet (problem 07).  Data is shown with
+// its escaping removed.
+func decodeLRCP(bs []byte, _ bool) []Message {
+	message := Message{Raw: bs}
+
+	packet, err := problem07.ParsePacket(bs)
+	switch {
+	case err != nil:
+		message.Text = strconv.Quote(string(bs))
+		message.Issue = err.Error()
+	case packet.IsConnect:
+		message.Text = fields("Connect", "session", packet.Session)
+	case packet.IsData:
+		message.Text = fields("Data", "session", packet.Session, "pos", packet.Pos, "data", strconv.Quote(string(packet.Data)))
+	case packet.IsAck:
+		message.Text = fields("Ack", "session", packet.Session, "length", packet.Length)
+	case packet.IsClose:
+		message.Text = fields("Close", "session", packet.Session)
+	}
+
+	if len(bs) >= 1000 {
+		message.Issue = "packets must be smaller than 1000 bytes"
+	}
+
+	return []Message{message}
+}
@@ -0,0 +1,61 @@
+package decode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// meansQueries are the names of the messages that query a range of timestamps,
+// by type.
+var meansQueries = map[byte]string{
+	'Q': "Query",
+	'L': "Min",
+	'H': "Max",
+	'M': "Median",
+	'C': "Count",
+	'S': "Sum",
+	'V': "StdDev",
+}
+
+// decodeMeans decodes the Means to an End protocol (problem 02).  Clients send
+// 9 byte messages, a type followed by two signed 32-bit integers, and the
+// server replies to each query with a single signed 32-bit integer.  Which
+// query an answer is for can't be told from the server's side alone.
+func decodeMeans(bs []byte, fromClient bool) []Message {
+	if !fromClient {
+		return decodeStream(bs, func(r *reader) (string, string) {
+			r.name = "Answer"
+			return fields("Answer", "value", r.i32()), ""
+		})
+	}
+
+	return decodeStream(bs, func(r *reader) (string, string) {
+		kind := r.u8()
+		a, b := r.i32(), r.i32()
+
+		if query, ok := meansQueries[kind]; ok {
+			r.name = query
+			return fields(query, "mintime", a, "maxtime", b), ""
+		}
+
+		switch kind {
+		case 'I':
+			r.name = "Insert"
+			return fields("Insert", "timestamp", a, "price", b), ""
+		case 'D':
+			r.name = "Delete"
+			return fields("Delete", "timestamp", a), ""
+		case 'A':
+			r.name = "Attach"
+			name := binary.BigEndian.AppendUint32(nil, uint32(a))
+			name = binary.BigEndian.AppendUint32(name, uint32(b))
+			return fields("Attach", "series", fmt.Sprintf("%q", bytes.TrimRight(name, "\x00"))), ""
+		default:
+			// Every message is the same length, so decoding can carry on with the
+			// next one.
+			r.name = "Unknown"
+			return fields("Unknown", "type", fmt.Sprintf("0x%02x", kind), "a", a, "b", b), fmt.Sprintf("unknown message type 0x%02x", kind)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package decode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxPestControlLength is the longest message that's decoded, anything longer
+// is assumed to be garbage rather than a real message.
+const maxPestControlLength = 1 << 20
+
+// decodePestControl decodes the Pest Control protocol (problem 11).  Every
+// message starts with its type and total length and ends with a checksum that
+// makes the sum of its bytes zero.
+func decodePestControl(bs []byte, _ bool) []Message {
+	return decodeStream(bs, func(r *reader) (string, string) {
+		start := r.pos
+		kind := r.u8()
+		length := r.u32()
+		if r.short {
+			r.name = pestControlName(kind)
+			return "", ""
+		}
+		if length < 6 || length > maxPestControlLength {
+			r.rest()
+			return fmt.Sprintf("%s{length: %d}", pestControlName(kind), length), fmt.Sprintf("invalid length %d, the rest of the stream can't be decoded", length)
+		}
+
+		// The payload is decoded on its own so that a malformed payload doesn't
+		// affect the messages after it.
+		r.name = pestControlName(kind)
+		body := r.take(int(length) - 6)
+		r.u8() // checksum
+		if r.short {
+			return "", ""
+		}
+
+		var issues []string
+		var sum uint8
+		for _, b := range r.bs[start:r.pos] {
+			sum += b
+		}
+		if sum != 0 {
+			issues = append(issues, fmt.Sprintf("checksum failure, the bytes sum to 0x%02x", sum))
+		}
+
+		payload := &reader{bs: body}
+		text, issue := pestControlPayload(kind, payload)
+		if issue != "" {
+			issues = append(issues, issue)
+		}
+		if payload.short {
+			issues = append(issues, "payload is shorter than its fields")
+		} else if n := len(body) - payload.pos; n > 0 {
+			issues = append(issues, fmt.Sprintf("%d unused bytes at the end of the payload", n))
+		}
+
+		return text, strings.Join(issues, "; ")
+	})
+}
+
+var pestControlNames = map[uint8]string{
+	0x50: "Hello",
+	0x51: "Error",
+	0x52: "OK",
+	0x53: "DialAuthority",
+	0x54: "TargetPopulations",
+	0x55: "CreatePolicy",
+	0x56: "DeletePolicy",
+	0x57: "PolicyResult",
+	0x58: "SiteVisit",
+}
+
+func pestControlName(kind uint8) string {
+	if name, ok := pestControlNames[kind]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown{type: 0x%02x}", kind)
+}
+
+// pestControlPayload decodes the fields of a message of the given type.
+func pestControlPayload(kind uint8, r *reader) (string, string) {
+	switch kind {
+	case 0x50:
+		protocol, version := pestControlString(r), r.u32()
+		text := fields("Hello", "protocol", strconv.Quote(protocol), "version", version)
+		if protocol != "pestcontrol" || version != 1 {
+			return text, "unsupported protocol or version"
+		}
+		return text, ""
+
+	case 0x51:
+		return fields("Error", "message", strconv.Quote(pestControlString(r))), ""
+
+	case 0x52:
+		return "OK", ""
+
+	case 0x53:
+		return fields("DialAuthority", "site", r.u32()), ""
+
+	case 0x54:
+		site := r.u32()
+		populations := pestControlArray(r, func() string {
+			return fields("", "species", strconv.Quote(pestControlString(r)), "min", r.u32(), "max", r.u32())
+		})
+		return fields("TargetPopulations", "site", site, "populations", populations), ""
+
+	case 0x55:
+		species, action := pestControlString(r), r.u8()
+		switch action {
+		case 0x90:
+			return fields("CreatePolicy", "species", strconv.Quote(species), "action", "cull"), ""
+		case 0xa0:
+			return fields("CreatePolicy", "species", strconv.Quote(species), "action", "conserve"), ""
+		default:
+			return fields("CreatePolicy", "species", strconv.Quote(species), "action", fmt.Sprintf("0x%02x", action)), fmt.Sprintf("unknown action 0x%02x", action)
+		}
+
+	case 0x56:
+		return fields("DeletePolicy", "policy", r.u32()), ""
+
+	case 0x57:
+		return fields("PolicyResult", "policy", r.u32()), ""
+
+	case 0x58:
+		site := r.u32()
+		populations := pestControlArray(r, func() string {
+			return fields("", "species", strconv.Quote(pestControlString(r)), "count", r.u32())
+		})
+		return fields("SiteVisit", "site", site, "populations", populations), ""
+
+	default:
+		r.rest()
+		return pestControlName(kind), fmt.Sprintf("unknown message type 0x%02x", kind)
+	}
+}
+
+// pestControlString reads a string prefixed by its length as a 32-bit integer.
+func pestControlString(r *reader) string {
+	n := r.u32()
+	if int64(n) > int64(len(r.bs)-r.pos) {
+		r.short = true
+		return ""
+	}
+	return string(r.take(int(n)))
+}
+
+// pestControlArray reads an array prefixed by its length as a 32-bit integer,
+// using element to read each element.
+func pestControlArray(r *reader, element func() string) string {
+	n := r.u32()
+
+	var elements []string
+	for i := uint32(0); i < n && !r.short; i++ {
+		elements = append(elements, element())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
@@ -0,0 +1,83 @@
+package decode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// decodeSpeed decodes the Speed Daemon protocol (problem 06).  Messages don't
+// have a length, so after a message of an unknown type the rest of the stream
+// can't be decoded.
+func decodeSpeed(bs []byte, fromClient bool) []Message {
+	return decodeStream(bs, func(r *reader) (string, string) {
+		kind := r.u8()
+
+		var text string
+		var client bool // Whether the message is sent by clients
+		switch kind {
+		case 0x10:
+			r.name = "Error"
+			text = fields("Error", "msg", strconv.Quote(speedString(r)))
+
+		case 0x20:
+			r.name, client = "Plate", true
+			text = fields("Plate", "plate", strconv.Quote(speedString(r)), "timestamp", r.u32())
+
+		case 0x21:
+			r.name = "Ticket"
+			plate := speedString(r)
+			road, mile1, timestamp1, mile2, timestamp2, speed := r.u16(), r.u16(), r.u32(), r.u16(), r.u32(), r.u16()
+			text = fields("Ticket",
+				"plate", strconv.Quote(plate),
+				"road", road,
+				"mile1", mile1,
+				"timestamp1", timestamp1,
+				"mile2", mile2,
+				"timestamp2", timestamp2,
+				"speed", fmt.Sprintf("%d.%02d", speed/100, speed%100),
+			)
+
+		case 0x40:
+			r.name, client = "WantHeartbeat", true
+			text = fields("WantHeartbeat", "interval", r.u32())
+
+		case 0x41:
+			r.name = "Heartbeat"
+			text = "Heartbeat"
+
+		case 0x80:
+			r.name, client = "IAmCamera", true
+			text = fields("IAmCamera", "road", r.u16(), "mile", r.u16(), "limit", r.u16())
+
+		case 0x81:
+			r.name, client = "IAmDispatcher", true
+			roads := make([]string, r.u8())
+			for i := range roads {
+				roads[i] = strconv.Itoa(int(r.u16()))
+			}
+			text = fields("IAmDispatcher", "roads", "["+strings.Join(roads, ", ")+"]")
+
+		default:
+			r.rest()
+			return fmt.Sprintf("Unknown{type: 0x%02x}", kind), fmt.Sprintf("unknown message type 0x%02x, the rest of the stream can't be decoded", kind)
+		}
+
+		if client != fromClient {
+			return text, fmt.Sprintf("%s messages aren't sent by the %s", r.name, sender(fromClient))
+		}
+		return text, ""
+	})
+}
+
+// speedString reads a string prefixed by its length as a single byte.
+func speedString(r *reader) string {
+	return string(r.take(int(r.u8())))
+}
+
+func sender(fromClient bool) string {
+	if fromClient {
+		return "client"
+	}
+	return "server"
+}
@@ -0,0 +1,199 @@
+// Package faults relays traffic between clients and a server while injecting
+// network faults, so that protocols that are meant to survive loss,
+// duplication and reordering can be exercised locally.
+package faults
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/bbeck/protohackers/internal"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var FaultsInjected = internal.NewCounterVec("protohackers_faults_injected_total", "Number of faults injected into relayed packets or segments.", "fault")
+
+// DefaultReorderTimeout is how long a packet that's being reordered is held for
+// waiting for the packet that will overtake it, if no delay is configured.
+const DefaultReorderTimeout = 100 * time.Millisecond
+
+// Profile describes the faults to inject.  Each fault is applied to a packet,
+// or to the data returned by a single read of a TCP stream, independently with
+// its probability.  The zero value injects no faults.
+type Profile struct {
+	// Seed seeds the random choices.  The same seed produces the same faults for
+	// the same sequence of packets in each flow.
+	Seed int64
+
+	Drop      float64 // Probability of discarding a packet
+	Duplicate float64 // Probability of sending a packet twice
+	Delay     float64 // Probability of holding a packet for up to MaxDelay
+	Reorder   float64 // Probability of letting the next packet overtake this one
+	Truncate  float64 // Probability of discarding the end of a packet
+
+	// MaxDelay is the longest a delayed packet is held for, the delay is chosen
+	// uniformly at random.
+	MaxDelay time.Duration
+}
+
+// RegisterFlags registers a command line flag for each field of the profile.
+func (p *Profile) RegisterFlags(fs *flag.FlagSet) {
+	fs.Int64Var(&p.Seed, "seed", p.Seed, "seed for the random choice of faults")
+	fs.Float64Var(&p.Drop, "drop", p.Drop, "probability of dropping a packet")
+	fs.Float64Var(&p.Duplicate, "duplicate", p.Duplicate, "probability of duplicating a packet")
+	fs.Float64Var(&p.Delay, "delay", p.Delay, "probability of delaying a packet")
+	fs.Float64Var(&p.Reorder, "reorder", p.Reorder, "probability of letting the next packet overtake a packet")
+	fs.Float64Var(&p.Truncate, "truncate", p.Truncate, "probability of truncating a packet")
+	fs.DurationVar(&p.MaxDelay, "max-delay", p.MaxDelay, "longest time a delayed packet is held for")
+}
+
+// Validate checks that the profile's probabilities are between 0 and 1.
+func (p *Profile) Validate() error {
+	var errs []error
+	for name, probability := range map[string]float64{
+		"drop":      p.Drop,
+		"duplicate": p.Duplicate,
+		"delay":     p.Delay,
+		"reorder":   p.Reorder,
+		"truncate":  p.Truncate,
+	} {
+		if probability < 0 || probability > 1 {
+			errs = append(errs, fmt.Errorf("invalid %s probability: %v", name, probability))
+		}
+	}
+	if p.Delay > 0 && p.MaxDelay <= 0 {
+		errs = append(errs, errors.New("max delay must be positive when packets are delayed"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Injector applies a profile's faults to a sequence of packets in one
+// direction of a flow before sending them on.  It's safe for concurrent use.
+type Injector struct {
+	profile Profile
+	logger  *slog.Logger
+	send    func([]byte) error
+
+	// Ordered is true if a delayed packet must hold up the packets after it, as
+	// is the case for a stream.  Otherwise later packets may overtake it.
+	ordered bool
+
+	mutex  sync.Mutex
+	random *rand.Rand
+	held   []byte      // A packet waiting to be overtaken
+	timer  *time.Timer // Sends the held packet if nothing overtakes it in time
+}
+
+// NewInjector returns an injector that sends packets with send after applying
+// the profile's faults to them, using seed for the random choices.
+func NewInjector(profile Profile, seed int64, ordered bool, logger *slog.Logger, send func([]byte) error) *Injector {
+	return &Injector{
+		profile: profile,
+		logger:  logger,
+		send:    send,
+		ordered: ordered,
+		random:  rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Inject applies faults to bs and sends whatever is left of it.  The packet is
+// copied, so bs may be reused once Inject returns.
+func (i *Injector) Inject(bs []byte) error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	// Every choice is made for every packet, so that the choices for a packet
+	// don't depend on the faults injected into the ones before it.
+	p := i.profile
+	drop := i.random.Float64() < p.Drop
+	truncate := i.random.Float64() < p.Truncate
+	duplicate := i.random.Float64() < p.Duplicate
+	delay := i.random.Float64() < p.Delay
+	reorder := i.random.Float64() < p.Reorder
+	length := i.random.Intn(max(len(bs), 1))
+	var wait time.Duration
+	if p.MaxDelay > 0 {
+		wait = time.Duration(i.random.Int63n(int64(p.MaxDelay))) + 1
+	}
+
+	if drop {
+		i.injected("drop", len(bs))
+		return nil
+	}
+
+	bs = append([]byte(nil), bs...)
+	if truncate && len(bs) > 0 {
+		i.injected("truncate", len(bs))
+		bs = bs[:length]
+	}
+
+	copies := 1
+	if duplicate {
+		i.injected("duplicate", len(bs))
+		copies = 2
+	}
+
+	if reorder && i.held == nil {
+		i.injected("reorder", len(bs))
+		i.held = bs
+		timeout := p.MaxDelay
+		if timeout <= 0 {
+			timeout = DefaultReorderTimeout
+		}
+		i.timer = time.AfterFunc(timeout, func() { _ = i.Flush() })
+		if copies == 2 {
+			// The duplicate is the one that gets overtaken.
+			return i.send(bs)
+		}
+		return nil
+	}
+
+	if delay && wait > 0 {
+		i.injected("delay", len(bs))
+		if i.ordered {
+			time.Sleep(wait)
+		} else {
+			time.AfterFunc(wait, func() {
+				for n := 0; n < copies; n++ {
+					_ = i.send(bs)
+				}
+			})
+			return i.flush()
+		}
+	}
+
+	for n := 0; n < copies; n++ {
+		if err := i.send(bs); err != nil {
+			return err
+		}
+	}
+	return i.flush()
+}
+
+// Flush sends the packet being held for reordering, if there is one.
+func (i *Injector) Flush() error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return i.flush()
+}
+
+func (i *Injector) flush() error {
+	if i.held == nil {
+		return nil
+	}
+
+	bs := i.held
+	i.held = nil
+	i.timer.Stop()
+	return i.send(bs)
+}
+
+func (i *Injector) injected(fault string, size int) {
+	FaultsInjected.With(fault).Inc()
+	i.logger.Debug("fault injected", "fault", fault, "bytes", size)
+}
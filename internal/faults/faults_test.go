@@ -0,0 +1,130 @@
+package faults_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/bbeck/protohackers/internal"
+	"github.com/bbeck/protohackers/internal/faults"
+	"github.com/bbeck/protohackers/internal/problem00"
+	"github.com/bbeck/protohackers/internal/servertest"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// inject sends packets through an injector and returns what comes out of it
+// once any held packets have been flushed.
+func inject(t *testing.T, profile faults.Profile, seed int64, packets ...string) []string {
+	t.Helper()
+
+	var sent []string
+	injector := faults.NewInjector(profile, seed, true, discard, func(bs []byte) error {
+		sent = append(sent, string(bs))
+		return nil
+	})
+	for _, packet := range packets {
+		if err := injector.Inject([]byte(packet)); err != nil {
+			t.Fatalf("error injecting: %v", err)
+		}
+	}
+	if err := injector.Flush(); err != nil {
+		t.Fatalf("error flushing: %v", err)
+	}
+
+	return sent
+}
+
+func TestNoFaults(t *testing.T) {
+	got := inject(t, faults.Profile{}, 1, "a", "b", "c")
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile faults.Profile
+		want    []string
+	}{
+		{name: "drop", profile: faults.Profile{Drop: 1}, want: nil},
+		{name: "duplicate", profile: faults.Profile{Duplicate: 1}, want: []string{"a", "a", "b", "b", "c", "c"}},
+		{name: "delay", profile: faults.Profile{Delay: 1, MaxDelay: time.Millisecond}, want: []string{"a", "b", "c"}},
+		{name: "reorder", profile: faults.Profile{Reorder: 1}, want: []string{"b", "a", "c"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := inject(t, test.profile, 1, "a", "b", "c")
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	got := inject(t, faults.Profile{Truncate: 1}, 1, "hello", "world")
+	if len(got) != 2 || !bytes.HasPrefix([]byte("hello"), []byte(got[0])) || !bytes.HasPrefix([]byte("world"), []byte(got[1])) {
+		t.Fatalf("unexpected packets: %q", got)
+	}
+	if len(got[0]) == 5 || len(got[1]) == 5 {
+		t.Fatalf("packets weren't truncated: %q", got)
+	}
+}
+
+func TestReproducible(t *testing.T) {
+	profile := faults.Profile{Drop: 0.2, Duplicate: 0.2, Reorder: 0.2, Truncate: 0.2}
+
+	var packets []string
+	for i := 0; i < 100; i++ {
+		packets = append(packets, fmt.Sprintf("packet %d", i))
+	}
+
+	first := inject(t, profile, 42, packets...)
+	if reflect.DeepEqual(first, packets) {
+		t.Fatal("no faults were injected")
+	}
+	if second := inject(t, profile, 42, packets...); !reflect.DeepEqual(first, second) {
+		t.Fatalf("the same seed injected different faults:\n%q\n%q", first, second)
+	}
+	if other := inject(t, profile, 43, packets...); reflect.DeepEqual(first, other) {
+		t.Fatal("different seeds injected the same faults")
+	}
+}
+
+func TestInvalidProfile(t *testing.T) {
+	for _, profile := range []faults.Profile{
+		{Drop: -0.1},
+		{Duplicate: 1.5},
+		{Delay: 0.5},
+	} {
+		if err := profile.Validate(); err == nil {
+			t.Errorf("profile %+v is valid", profile)
+		}
+	}
+}
+
+func TestTCPProxy(t *testing.T) {
+	proxy := &faults.Proxy{
+		Upstream: servertest.Start(t, problem00.Problem),
+		Profile:  faults.Profile{Delay: 1, MaxDelay: 10 * time.Millisecond},
+	}
+	addr := servertest.Start(t, internal.Problem{Name: "Fault Proxy", Run: proxy.ServeTCP})
+	conn := servertest.Dial(t, "tcp", addr)
+
+	// Delays hold up the rest of the stream, so the echo arrives intact.
+	conn.SendString("hello, ")
+	conn.SendString("world")
+	conn.ExpectString("hello, world")
+
+	// A half close is passed on, so the echo server closes the connection.
+	if err := conn.Conn.(interface{ CloseWrite() error }).CloseWrite(); err != nil {
+		t.Fatalf("error closing: %v", err)
+	}
+	conn.ExpectClosed()
+}
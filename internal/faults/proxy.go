@@ -0,0 +1,204 @@
+package faults
+
+import (
+	"context"
+	"errors"
+	"github.com/bbeck/protohackers/internal"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// FlowTimeout is how long a UDP flow is kept open after the last datagram from
+// its client.
+var FlowTimeout = 2 * time.Minute
+
+// Proxy relays traffic from the clients of a server to an upstream server,
+// injecting faults into the traffic in both directions.
+//
+// Every flow, a TCP connection or the datagrams from a single UDP address, gets
+// its own source of randomness for each direction, seeded from the profile's
+// seed in the order the flows start.  A single client therefore sees the same
+// faults each time it sends the same traffic through a proxy with the same
+// profile.
+type Proxy struct {
+	// Upstream is the address of the server to relay traffic to.
+	Upstream string
+
+	// Profile describes the faults to inject.
+	Profile Profile
+
+	mutex  sync.Mutex
+	random *rand.Rand
+}
+
+// seeds returns the seeds for the client to upstream and upstream to client
+// directions of a new flow.
+func (p *Proxy) seeds() (int64, int64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.random == nil {
+		p.random = rand.New(rand.NewSource(p.Profile.Seed))
+	}
+	return p.random.Int63(), p.random.Int63()
+}
+
+// ServeTCP relays TCP connections accepted by server to the upstream server
+// until ctx is cancelled.  Faults are injected into the data returned by each
+// read from either side, with delays holding up the rest of the stream so
+// that only reordering, duplication, truncation and drops corrupt it.
+func (p *Proxy) ServeTCP(ctx context.Context, server *internal.Server) error {
+	return server.ServeTCP(ctx, func(conn net.Conn) {
+		logger := internal.Logger(conn)
+
+		upstream, err := net.DialTimeout("tcp", p.Upstream, 10*time.Second)
+		if err != nil {
+			logger.Error("error connecting to upstream", "error", err)
+			return
+		}
+		defer upstream.Close()
+
+		toUpstream, toClient := p.seeds()
+		up := NewInjector(p.Profile, toUpstream, true, logger.With("direction", "upstream"), write(upstream))
+		down := NewInjector(p.Profile, toClient, true, logger.With("direction", "client"), write(conn))
+
+		// Pass on a half close from the client, the connection is finished once the
+		// upstream server has closed its side.
+		go func() {
+			if err := relay(conn, up); err != nil {
+				logger.Debug("error relaying to upstream", "error", err)
+				_ = upstream.Close()
+				return
+			}
+			if tcp, ok := upstream.(*net.TCPConn); ok {
+				_ = tcp.CloseWrite()
+			}
+		}()
+
+		if err := relay(upstream, down); err != nil {
+			logger.Debug("error relaying to client", "error", err)
+		}
+	})
+}
+
+// relay reads from r and injects what it reads until r is exhausted.
+func relay(r io.Reader, injector *Injector) error {
+	bs := make([]byte, 4096)
+	for {
+		n, err := r.Read(bs)
+		if n > 0 {
+			if err := injector.Inject(bs[:n]); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return injector.Flush()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func write(w io.Writer) func([]byte) error {
+	return func(bs []byte) error {
+		_, err := w.Write(bs)
+		return err
+	}
+}
+
+// ServeUDP relays datagrams received by server to the upstream server until
+// ctx is cancelled.  Each client address gets its own socket to the upstream
+// server so that the upstream server sees each client as a separate peer, and
+// replies to that socket are relayed back to the client.  Delayed datagrams may
+// be overtaken by the ones sent after them.
+func (p *Proxy) ServeUDP(ctx context.Context, server *internal.Server) error {
+	// The faults for each flow only depend on its datagrams if they're injected
+	// in the order they're received.
+	server.OrderBySource = true
+
+	var mutex sync.Mutex
+	flows := make(map[string]*udpFlow)
+
+	server.RegisterOnShutdown(func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		for _, flow := range flows {
+			_ = flow.upstream.Close()
+		}
+	})
+
+	return server.ServeUDP(ctx, func(addr net.Addr, bs []byte, reply func([]byte) error) {
+		logger := server.Log().With("remote_addr", addr.String())
+
+		mutex.Lock()
+		flow, found := flows[addr.String()]
+		if !found {
+			upstream, err := net.Dial("udp", p.Upstream)
+			if err != nil {
+				mutex.Unlock()
+				logger.Error("error connecting to upstream", "error", err)
+				return
+			}
+
+			toUpstream, toClient := p.seeds()
+			flow = &udpFlow{
+				upstream: upstream,
+				up:       NewInjector(p.Profile, toUpstream, false, logger.With("direction", "upstream"), write(upstream)),
+				down:     NewInjector(p.Profile, toClient, false, logger.With("direction", "client"), reply),
+			}
+			flows[addr.String()] = flow
+
+			go func() {
+				flow.run(logger)
+
+				mutex.Lock()
+				defer mutex.Unlock()
+				if flows[addr.String()] == flow {
+					delete(flows, addr.String())
+				}
+			}()
+		}
+		mutex.Unlock()
+
+		_ = flow.upstream.SetReadDeadline(time.Now().Add(FlowTimeout))
+		if err := flow.up.Inject(bs); err != nil {
+			logger.Debug("error relaying to upstream", "error", err)
+		}
+	})
+}
+
+// udpFlow is the datagrams exchanged between a single client address and the
+// upstream server.
+type udpFlow struct {
+	upstream net.Conn
+	up       *Injector
+	down     *Injector
+}
+
+// run relays the upstream server's replies back to the client until the flow
+// times out or the proxy shuts down.
+func (f *udpFlow) run(logger *slog.Logger) {
+	defer f.upstream.Close()
+
+	bs := make([]byte, internal.MaxDatagramSize)
+	for {
+		n, err := f.upstream.Read(bs)
+		if err != nil {
+			var ne net.Error
+			if !errors.As(err, &ne) || !ne.Timeout() {
+				logger.Debug("error reading from upstream", "error", err)
+			}
+			return
+		}
+
+		if err := f.down.Inject(bs[:n]); err != nil {
+			logger.Debug("error relaying to client", "error", err)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package internal
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"sync/atomic"
+)
+
+// NewLogger returns a logger that writes records at or above the configured
+// level to w in the configured format.
+func (c *Config) NewLogger(w io.Writer) *slog.Logger {
+	options := &slog.HandlerOptions{Level: c.LogLevel}
+	if c.LogFormat == "json" {
+		return slog.New(slog.NewJSONHandler(w, options))
+	}
+	return slog.New(slog.NewTextHandler(w, options))
+}
+
+// Logger returns the logger for a connection that was accepted by a Server.
+// Every record written to it includes the connection's ID and remote address.
+// For any other connection the default logger is returned.
+//
+// Protocol events, such as a ticket being issued or a job being assigned,
+// should be logged at debug level so that they're only written when verbose
+// logging is enabled.
+func Logger(c net.Conn) *slog.Logger {
+	if wrapped, ok := c.(*conn); ok {
+		return wrapped.logger
+	}
+	return slog.Default()
+}
+
+// nextConnID is the ID of the most recent connection accepted by any Server.
+var nextConnID atomic.Uint64
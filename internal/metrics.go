@@ -0,0 +1,269 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Registry holds a set of metrics that can be exposed in the Prometheus text
+// format.  The zero value for Registry is an empty registry ready to use.
+type Registry struct {
+	mutex    sync.Mutex
+	families map[string]*family
+}
+
+// DefaultRegistry is the registry that the NewCounter, NewGauge and related
+// functions register metrics with and that ServeMetrics exposes.
+var DefaultRegistry = new(Registry)
+
+// family is a named metric along with each of its series, one per distinct
+// set of label values.
+type family struct {
+	name   string
+	help   string
+	kind   string // Either "counter" or "gauge"
+	labels []string
+
+	mutex  sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labels []string
+	value  atomic.Int64
+}
+
+// register adds a new metric family to the registry.  It panics if a metric
+// with the same name has already been registered, since that's always a
+// programming error.
+func (r *Registry) register(name, help, kind string, labels []string) *family {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.families == nil {
+		r.families = make(map[string]*family)
+	}
+	if _, found := r.families[name]; found {
+		panic(fmt.Sprintf("metric %s registered twice", name))
+	}
+
+	f := &family{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		series: make(map[string]*series),
+	}
+	r.families[name] = f
+	return f
+}
+
+// with returns the series for the given label values, creating it if it
+// doesn't exist yet.
+func (f *family) with(values []string) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metric %s has %d labels, got %d values", f.name, len(f.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	s := f.series[key]
+	if s == nil {
+		s = &series{labels: append([]string(nil), values...)}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Write writes every metric in the registry to w in the Prometheus text
+// format.  Metrics are sorted by name, and series by their label values.
+func (r *Registry) Write(w io.Writer) error {
+	r.mutex.Lock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mutex.Unlock()
+
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].name < families[j].name
+	})
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.name, f.help)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.kind)
+
+		f.mutex.Lock()
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			s := f.series[key]
+			fmt.Fprintf(bw, "%s%s %d\n", f.name, formatLabels(f.labels, s.labels), s.value.Load())
+		}
+		f.mutex.Unlock()
+	}
+
+	return bw.Flush()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, `%s="%s"`, names[i], labelEscaper.Replace(values[i]))
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// ServeHTTP writes the registry's metrics in response to any request.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = r.Write(w)
+}
+
+// ServeMetrics serves the metrics in DefaultRegistry over HTTP at /metrics on
+// addr until ctx is cancelled.  If addr is empty it returns immediately.
+func ServeMetrics(ctx context.Context, addr string) error {
+	if addr == "" {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("error listening for metrics requests: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", DefaultRegistry)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	stop := context.AfterFunc(ctx, func() { _ = server.Close() })
+	defer stop()
+
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// =============================================================================
+
+// Counter is a metric whose value only ever increases.
+type Counter atomic.Int64
+
+// NewCounter registers a new counter with DefaultRegistry.
+func NewCounter(name, help string) *Counter {
+	return (*Counter)(&DefaultRegistry.register(name, help, "counter", nil).with(nil).value)
+}
+
+// Inc increments the counter by one.
+func (c *Counter) Inc() {
+	(*atomic.Int64)(c).Add(1)
+}
+
+// Add increases the counter by n, which must not be negative.
+func (c *Counter) Add(n int) {
+	(*atomic.Int64)(c).Add(int64(n))
+}
+
+// Value returns the current value of the counter.
+func (c *Counter) Value() int64 {
+	return (*atomic.Int64)(c).Load()
+}
+
+// CounterVec is a set of counters that share a name but are distinguished by
+// the values of their labels.
+type CounterVec struct {
+	family *family
+}
+
+// NewCounterVec registers a new set of counters with DefaultRegistry.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{family: DefaultRegistry.register(name, help, "counter", labels)}
+}
+
+// With returns the counter with the given label values, which must be given
+// in the same order as the labels were when the set was created.
+func (v *CounterVec) With(values ...string) *Counter {
+	return (*Counter)(&v.family.with(values).value)
+}
+
+// =============================================================================
+
+// Gauge is a metric whose value can go up and down.
+type Gauge atomic.Int64
+
+// NewGauge registers a new gauge with DefaultRegistry.
+func NewGauge(name, help string) *Gauge {
+	return (*Gauge)(&DefaultRegistry.register(name, help, "gauge", nil).with(nil).value)
+}
+
+// Inc increments the gauge by one.
+func (g *Gauge) Inc() {
+	(*atomic.Int64)(g).Add(1)
+}
+
+// Dec decrements the gauge by one.
+func (g *Gauge) Dec() {
+	(*atomic.Int64)(g).Add(-1)
+}
+
+// Add changes the gauge by n.
+func (g *Gauge) Add(n int) {
+	(*atomic.Int64)(g).Add(int64(n))
+}
+
+// Set sets the gauge to n.
+func (g *Gauge) Set(n int) {
+	(*atomic.Int64)(g).Store(int64(n))
+}
+
+// Value returns the current value of the gauge.
+func (g *Gauge) Value() int64 {
+	return (*atomic.Int64)(g).Load()
+}
+
+// GaugeVec is a set of gauges that share a name but are distinguished by the
+// values of their labels.
+type GaugeVec struct {
+	family *family
+}
+
+// NewGaugeVec registers a new set of gauges with DefaultRegistry.
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{family: DefaultRegistry.register(name, help, "gauge", labels)}
+}
+
+// With returns the gauge with the given label values, which must be given in
+// the same order as the labels were when the set was created.
+func (v *GaugeVec) With(values ...string) *Gauge {
+	return (*Gauge)(&v.family.with(values).value)
+}
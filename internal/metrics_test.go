@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	// The registry is a fresh one, rather than DefaultRegistry, so that only the
+	// test's metrics are written and their values start from zero every run.
+	var r Registry
+	counter := (*Counter)(&r.register("test_events_total", "Number of events.", "counter", nil).with(nil).value)
+	counters := &CounterVec{family: r.register("test_requests_total", "Number of requests by method and path.", "counter", []string{"method", "path"})}
+	gauge := (*Gauge)(&r.register("test_open", "Number of open things.", "gauge", nil).with(nil).value)
+	gauges := &GaugeVec{family: r.register("test_queued", "Number of things queued by queue.", "gauge", []string{"queue"})}
+
+	counter.Add(3)
+	counter.Inc()
+	counters.With("GET", "/b").Inc()
+	counters.With("GET", "/a").Add(2)
+	counters.With("PUT", `/"quoted"\path`+"\n").Inc()
+	gauge.Inc()
+	gauge.Inc()
+	gauge.Dec()
+	gauges.With("q2").Set(-4)
+	gauges.With("q1").Set(7)
+
+	want := strings.Join([]string{
+		"# HELP test_events_total Number of events.",
+		"# TYPE test_events_total counter",
+		"test_events_total 4",
+		"# HELP test_open Number of open things.",
+		"# TYPE test_open gauge",
+		"test_open 1",
+		"# HELP test_queued Number of things queued by queue.",
+		"# TYPE test_queued gauge",
+		`test_queued{queue="q1"} 7`,
+		`test_queued{queue="q2"} -4`,
+		"# HELP test_requests_total Number of requests by method and path.",
+		"# TYPE test_requests_total counter",
+		`test_requests_total{method="GET",path="/a"} 2`,
+		`test_requests_total{method="GET",path="/b"} 1`,
+		`test_requests_total{method="PUT",path="/\"quoted\"\\path\n"} 1`,
+	}, "\n") + "\n"
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatalf("error writing metrics: %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected metrics\nwant:\n%s\n got:\n%s", want, got)
+	}
+
+	// The same text is served over HTTP.
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := recorder.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type: %q", ct)
+	}
+	if got := recorder.Body.String(); got != want {
+		t.Errorf("unexpected metrics served\nwant:\n%s\n got:\n%s", want, got)
+	}
+}
+
+func TestRegistryRegisteredTwice(t *testing.T) {
+	var r Registry
+	r.register("test_events_total", "Number of events.", "counter", nil)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a metric twice didn't panic")
+		}
+	}()
+	r.register("test_events_total", "Number of events.", "counter", nil)
+}
@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// Problem is the solution to one of the protohackers problems.
+type Problem struct {
+	// ID is the number of the problem.
+	ID int
+
+	// Name is the title of the problem.
+	Name string
+
+	// Run runs the problem's server until ctx is cancelled.
+	Run func(ctx context.Context, server *Server) error
+}
+
+// Slug returns a short name for the problem that is suitable for use in
+// metrics and logs.
+func (p Problem) Slug() string {
+	return fmt.Sprintf("problem-%02d", p.ID)
+}
+
+// Main is the entry point of a binary that runs a single problem's server.
+// The server is configured from the command line and environment, and runs
+// until the process receives SIGINT or SIGTERM.
+func Main(problem Problem) {
+	ctx, stop := SignalContext()
+	defer stop()
+
+	config, err := ParseConfig(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf("error parsing configuration: %v", err)
+	}
+	slog.SetDefault(config.NewLogger(os.Stderr))
+
+	if err := Serve(ctx, config, problem); err != nil {
+		log.Fatalf("error running server: %v", err)
+	}
+}
+
+// Serve runs the servers for each of the problems concurrently until ctx is
+// cancelled, along with the metrics endpoint if one is configured.  When more
+// than one problem is served each listens on the configured port plus its ID
+// so that they don't collide, unless the port is 0 in which case every server
+// uses an ephemeral port.  If any server fails the others are stopped.
+func Serve(ctx context.Context, config Config, problems ...Problem) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		if err := ServeMetrics(ctx, config.MetricsAddress); err != nil {
+			slog.Error("error serving metrics", "error", err)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(problems))
+	for i, problem := range problems {
+		server := &Server{
+			Config: config,
+			Name:   problem.Slug(),
+			Logger: slog.Default().With("problem", problem.Slug()),
+		}
+		if len(problems) > 1 && config.Port != 0 {
+			server.Port = config.Port + problem.ID
+		}
+
+		wg.Add(1)
+		go func(i int, problem Problem) {
+			defer wg.Done()
+
+			if err := problem.Run(ctx, server); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", problem.Slug(), err)
+				cancel()
+			}
+		}(i, problem)
+	}
+
+	wg.Wait()
+	return errors.Join(errs...)
+}
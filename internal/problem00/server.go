@@ -0,0 +1,19 @@
+package problem00
+
+import (
+	"context"
+	"github.com/bbeck/protohackers/internal"
+	"io"
+	"net"
+)
+
+// Problem is the Smoke Test problem.
+var Problem = internal.Problem{ID: 0, Name: "Smoke Test", Run: Run}
+
+// Run runs the Smoke Test server until ctx is cancelled.
+func Run(ctx context.Context, server *internal.Server) error {
+	return server.ServeTCP(ctx, func(conn net.Conn) {
+		defer conn.Close()
+		io.Copy(conn, conn)
+	})
+}
@@ -0,0 +1,59 @@
+package problem00_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/bbeck/protohackers/internal/problem00"
+	"github.com/bbeck/protohackers/internal/servertest"
+	"testing"
+)
+
+func TestEcho(t *testing.T) {
+	addr := servertest.Start(t, problem00.Problem)
+	conn := servertest.Dial(t, "tcp", addr)
+
+	conn.SendString("hello, world\n")
+	conn.ExpectString("hello, world\n")
+
+	// Binary data, including bytes that aren't valid UTF-8, comes back intact.
+	data := make([]byte, 256*1024)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	conn.Send(data)
+	conn.Expect(data)
+}
+
+func TestEchoConcurrentClients(t *testing.T) {
+	addr := servertest.Start(t, problem00.Problem)
+
+	// Every client sends before any of them reads, so the server has to be
+	// handling all of them at once.
+	var conns []*servertest.Conn
+	var messages [][]byte
+	for i := 0; i < 5; i++ {
+		conn := servertest.Dial(t, "tcp", addr)
+		message := bytes.Repeat([]byte(fmt.Sprintf("client %d\n", i)), 100)
+		conn.Send(message)
+
+		conns = append(conns, conn)
+		messages = append(messages, message)
+	}
+
+	for i, conn := range conns {
+		conn.Expect(messages[i])
+	}
+}
+
+func TestHalfClose(t *testing.T) {
+	addr := servertest.Start(t, problem00.Problem)
+	conn := servertest.Dial(t, "tcp", addr)
+
+	conn.SendString("goodbye")
+	if err := conn.Conn.(interface{ CloseWrite() error }).CloseWrite(); err != nil {
+		t.Fatalf("error closing write side: %v", err)
+	}
+
+	conn.ExpectString("goodbye")
+	conn.ExpectClosed()
+}
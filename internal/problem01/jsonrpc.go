@@ -0,0 +1,195 @@
+package problem01
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	ParseError     = -32700
+	InvalidRequest = -32600
+	MethodNotFound = -32601
+	InvalidParams  = -32602
+	InternalError  = -32603
+)
+
+// RPCRequest is a JSON-RPC 2.0 request.  Params is either an array of numbers,
+// which is the number or numbers a method takes, or an object with the same
+// fields as a Request.  A request without an ID is a notification, which isn't
+// answered.
+type RPCRequest struct {
+	JSONRPC *string         `json:"jsonrpc"`
+	Method  *string         `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// RPCResponse is a JSON-RPC 2.0 response, which has either a result or an
+// error.  The result is the same response a Request for the method gets.
+type RPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  any             `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// IsRPC reports whether a line is a JSON-RPC 2.0 request or batch of requests,
+// rather than a Request.
+func IsRPC(bs []byte) bool {
+	bs = bytes.TrimLeft(bs, " \t\r")
+	if bytes.HasPrefix(bs, []byte("[")) {
+		return true
+	}
+
+	var request RPCRequest
+	return json.Unmarshal(bs, &request) == nil && request.JSONRPC != nil
+}
+
+// HandleRPC returns the response to a line containing a JSON-RPC 2.0 request
+// or batch of requests.  Unlike Handle, errors are reported in the response
+// rather than ending the connection, and a request that's cancelled by ctx is
+// answered with an internal error.  If there's nothing to respond with, as is
+// the case for notifications, nil is returned.
+func HandleRPC(ctx context.Context, logger *slog.Logger, bs []byte) []byte {
+	var answer any
+
+	trimmed := bytes.TrimLeft(bs, " \t\r")
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(trimmed, &batch); err != nil {
+			answer = rpcError(nil, ParseError, "Parse error")
+		} else if len(batch) == 0 {
+			answer = rpcError(nil, InvalidRequest, "Invalid Request")
+		} else {
+			var responses []RPCResponse
+			for _, request := range batch {
+				if response := handleRPC(ctx, request); response != nil {
+					responses = append(responses, *response)
+				}
+			}
+			if responses != nil {
+				answer = responses
+			}
+		}
+	} else if response := handleRPC(ctx, bs); response != nil {
+		answer = response
+	}
+
+	if answer == nil {
+		logger.Debug("notification handled", "request", string(bs))
+		return nil
+	}
+
+	response, err := json.Marshal(answer)
+	if err != nil {
+		logger.Error("error encoding response", "error", err)
+		response, _ = json.Marshal(rpcError(nil, InternalError, "Internal error"))
+	}
+	logger.Debug("request handled", "request", string(bs), "response", string(response))
+	return append(response, '\n')
+}
+
+// handleRPC answers a single JSON-RPC request, returning nil for a
+// notification.
+func handleRPC(ctx context.Context, bs []byte) *RPCResponse {
+	var request RPCRequest
+	if err := json.Unmarshal(bs, &request); err != nil {
+		if json.Valid(bs) {
+			return rpcError(nil, InvalidRequest, "Invalid Request")
+		}
+		return rpcError(nil, ParseError, "Parse error")
+	}
+
+	if !validID(request.ID) {
+		return rpcError(nil, InvalidRequest, "Invalid Request")
+	}
+	if request.JSONRPC == nil || *request.JSONRPC != "2.0" || request.Method == nil {
+		return rpcError(request.ID, InvalidRequest, "Invalid Request")
+	}
+
+	method, found := Methods[*request.Method]
+	if !found {
+		return reply(request.ID, rpcError(request.ID, MethodNotFound, "Method not found"))
+	}
+
+	r, ok := params(request, method)
+	if !ok {
+		return rpcError(request.ID, InvalidRequest, "Invalid Request")
+	}
+	if IsMalformed(r) {
+		return reply(request.ID, rpcError(request.ID, InvalidParams, "Invalid params"))
+	}
+
+	result, err := Answer(ctx, r)
+	if err != nil {
+		return reply(request.ID, rpcError(request.ID, InternalError, "Internal error"))
+	}
+	return reply(request.ID, &RPCResponse{JSONRPC: "2.0", Result: result, ID: request.ID})
+}
+
+// params converts the params of a JSON-RPC request into a Request for the
+// method.  Params that aren't an array or object make the request invalid,
+// while params of the wrong type leave the Request without any numbers so
+// that it's malformed.
+func params(request RPCRequest, method Method) (Request, bool) {
+	r := Request{Method: request.Method}
+
+	switch p := bytes.TrimLeft(request.Params, " \t\r\n"); {
+	case len(p) == 0:
+		return r, true
+
+	case p[0] == '[':
+		var numbers []Number
+		if json.Unmarshal(p, &numbers) == nil {
+			if len(numbers) == 1 && method.Batch {
+				r.Number = &numbers[0]
+			} else {
+				r.Numbers = numbers
+			}
+		}
+		return r, true
+
+	case p[0] == '{':
+		var named Request
+		if json.Unmarshal(p, &named) == nil {
+			r.Number, r.Numbers = named.Number, named.Numbers
+		}
+		return r, true
+
+	default:
+		return r, false
+	}
+}
+
+// reply returns a response to a request unless the request is a notification.
+func reply(id json.RawMessage, response *RPCResponse) *RPCResponse {
+	if id == nil {
+		return nil
+	}
+	return response
+}
+
+func rpcError(id json.RawMessage, code int, message string) *RPCResponse {
+	return &RPCResponse{JSONRPC: "2.0", Error: &RPCError{Code: code, Message: message}, ID: id}
+}
+
+// validID reports whether an ID is absent, a string, a number or null.
+func validID(id json.RawMessage) bool {
+	if id == nil {
+		return true
+	}
+	switch id[0] {
+	case '"', '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', 'n':
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,288 @@
+package problem01
+
+import (
+	"context"
+	"math/big"
+	"slices"
+)
+
+// Method answers the requests for one of the methods that the server supports.
+type Method struct {
+	// IsMalformed reports whether a request is missing the fields that the
+	// method needs or they have the wrong values.
+	IsMalformed func(r Request) bool
+
+	// Handle returns the response to a well-formed request, which is encoded as
+	// JSON.  Methods that can take a while stop early with ctx's error when ctx
+	// is cancelled.
+	Handle func(ctx context.Context, r Request) (any, error)
+
+	// Batch is true if the method takes a single number, and so also accepts a
+	// batch of numbers in place of it.  A batch is answered with the response
+	// to each of the numbers in turn.
+	Batch bool
+}
+
+// Methods are the methods that the server supports, by name.
+var Methods = map[string]Method{
+	"isPrime": {
+		IsMalformed: func(r Request) bool {
+			return r.Number == nil
+		},
+		Handle: func(ctx context.Context, r Request) (any, error) {
+			// Numbers that aren't integers can't be prime, and integers larger than
+			// MaxBits are answered as not prime rather than spending minutes testing
+			// them.
+			n, ok := r.Number.Int()
+			return PrimeResponse{Method: "isPrime", Prime: ok && IsPrime(n)}, nil
+		},
+		Batch: true,
+	},
+
+	"factorize": {
+		IsMalformed: func(r Request) bool {
+			n, ok := integer(r.Number)
+			return !ok || n.Sign() <= 0 || !n.IsUint64()
+		},
+		Handle: func(ctx context.Context, r Request) (any, error) {
+			n, _ := r.Number.Int()
+			factors, err := Factorize(ctx, n.Uint64())
+			if err != nil {
+				return nil, err
+			}
+			return FactorizeResponse{Method: "factorize", Factors: factors}, nil
+		},
+		Batch: true,
+	},
+
+	"nextPrime": {
+		IsMalformed: func(r Request) bool {
+			_, ok := integer(r.Number)
+			return !ok
+		},
+		Handle: func(ctx context.Context, r Request) (any, error) {
+			n, _ := r.Number.Int()
+			prime, err := NextPrime(ctx, n)
+			if err != nil {
+				return nil, err
+			}
+			return NextPrimeResponse{Method: "nextPrime", Prime: prime}, nil
+		},
+		Batch: true,
+	},
+
+	"isPerfectSquare": {
+		IsMalformed: func(r Request) bool {
+			return r.Number == nil
+		},
+		Handle: func(ctx context.Context, r Request) (any, error) {
+			// Like isPrime, numbers that aren't integers or are larger than MaxBits
+			// aren't squares.
+			n, ok := r.Number.Int()
+			return PerfectSquareResponse{Method: "isPerfectSquare", Square: ok && IsPerfectSquare(n)}, nil
+		},
+		Batch: true,
+	},
+
+	"gcd": {
+		IsMalformed: func(r Request) bool {
+			if len(r.Numbers) < 2 {
+				return true
+			}
+			for _, number := range r.Numbers {
+				if _, ok := number.Int(); !ok {
+					return true
+				}
+			}
+			return false
+		},
+		Handle: func(ctx context.Context, r Request) (any, error) {
+			gcd := new(big.Int)
+			for _, number := range r.Numbers {
+				n, _ := number.Int()
+				gcd.GCD(nil, nil, gcd, n)
+			}
+			return GCDResponse{Method: "gcd", GCD: gcd}, nil
+		},
+	},
+}
+
+// IsMalformedBatch reports whether any of the numbers in a batch request is
+// malformed on its own.
+func IsMalformedBatch(r Request) bool {
+	for i := range r.Numbers {
+		if Methods[*r.Method].IsMalformed(Request{Method: r.Method, Number: &r.Numbers[i]}) {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleBatch answers each of the numbers in a well-formed batch request.
+func HandleBatch(ctx context.Context, r Request) (BatchResponse, error) {
+	method := Methods[*r.Method]
+
+	response := BatchResponse{Method: *r.Method, Results: []any{}}
+	for i := range r.Numbers {
+		result, err := method.Handle(ctx, Request{Method: r.Method, Number: &r.Numbers[i]})
+		if err != nil {
+			return response, err
+		}
+		response.Results = append(response.Results, result)
+	}
+	return response, nil
+}
+
+// integer returns the value of a number that's required to be an integer.
+// Integers larger than MaxBits are rejected along with fractions, since the
+// methods that need an integer compute with all of its digits.
+func integer(number *Number) (*big.Int, bool) {
+	if number == nil {
+		return nil, false
+	}
+	return number.Int()
+}
+
+type BatchResponse struct {
+	Method  string `json:"method"`
+	Results []any  `json:"results"`
+}
+
+type PrimeResponse struct {
+	Method string `json:"method"`
+	Prime  bool   `json:"prime"`
+}
+
+type FactorizeResponse struct {
+	Method  string   `json:"method"`
+	Factors []uint64 `json:"factors"`
+}
+
+type NextPrimeResponse struct {
+	Method string   `json:"method"`
+	Prime  *big.Int `json:"prime"`
+}
+
+type PerfectSquareResponse struct {
+	Method string `json:"method"`
+	Square bool   `json:"square"`
+}
+
+type GCDResponse struct {
+	Method string   `json:"method"`
+	GCD    *big.Int `json:"gcd"`
+}
+
+// Factorize returns the prime factors of n in ascending order, with repeated
+// factors repeated.  1 has no prime factors.  It stops early with ctx's error
+// if ctx is cancelled.
+func Factorize(ctx context.Context, n uint64) ([]uint64, error) {
+	factors := []uint64{}
+
+	// Trial division quickly removes the small factors, which leaves Pollard's
+	// rho only the large ones to find.
+	for _, p := range witnesses {
+		for n%p == 0 {
+			factors = append(factors, p)
+			n /= p
+		}
+	}
+
+	var split func(n uint64) error
+	split = func(n uint64) error {
+		if n == 1 {
+			return nil
+		}
+		if isPrime64(n) {
+			factors = append(factors, n)
+			return nil
+		}
+
+		d, err := rho(ctx, n)
+		if err != nil {
+			return err
+		}
+		if err := split(d); err != nil {
+			return err
+		}
+		return split(n / d)
+	}
+	if err := split(n); err != nil {
+		return nil, err
+	}
+
+	slices.Sort(factors)
+	return factors, nil
+}
+
+// rho finds a non-trivial factor of n, which must be composite and have no
+// factors in witnesses, using Pollard's rho algorithm.
+func rho(ctx context.Context, n uint64) (uint64, error) {
+	for c := uint64(1); ; c++ {
+		f := func(x uint64) uint64 {
+			x = mulMod(x, x, n) + c
+			if x < c || x >= n {
+				x -= n
+			}
+			return x
+		}
+
+		x, y, d := uint64(2), uint64(2), uint64(1)
+		for i := 0; d == 1; i++ {
+			if i%1024 == 0 && ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+
+			x = f(x)
+			y = f(f(y))
+			if x > y {
+				d = gcd(x-y, n)
+			} else {
+				d = gcd(y-x, n)
+			}
+		}
+
+		// A cycle that found n itself is retried with a different polynomial.
+		if d != n {
+			return d, nil
+		}
+	}
+}
+
+func gcd(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// NextPrime returns the smallest prime greater than n.  It stops early with
+// ctx's error if ctx is cancelled.
+func NextPrime(ctx context.Context, n *big.Int) (*big.Int, error) {
+	if n.Cmp(big.NewInt(2)) < 0 {
+		return big.NewInt(2), nil
+	}
+
+	// Every prime after 2 is odd.
+	next := new(big.Int).Add(n, big.NewInt(1))
+	if next.Bit(0) == 0 && next.Cmp(big.NewInt(2)) > 0 {
+		next.Add(next, big.NewInt(1))
+	}
+	for !IsPrime(next) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		next.Add(next, big.NewInt(2))
+	}
+	return next, nil
+}
+
+// IsPerfectSquare reports whether n is the square of an integer.
+func IsPerfectSquare(n *big.Int) bool {
+	if n.Sign() < 0 {
+		return false
+	}
+
+	root := new(big.Int).Sqrt(n)
+	return root.Mul(root, root).Cmp(n) == 0
+}
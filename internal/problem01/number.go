@@ -0,0 +1,95 @@
+package problem01
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// MaxBits is the size of the largest integer the methods compute with.  A prime
+// this size takes well under a second to test, while one as large as a request
+// can hold would keep the server busy for hours.
+const MaxBits = 4_096
+
+// Number is a JSON number kept exactly as it was written, so that integers of
+// any size are decoded without losing precision.
+type Number string
+
+// UnmarshalJSON accepts any JSON number, but not strings that look like one.
+func (n *Number) UnmarshalJSON(bs []byte) error {
+	if len(bs) == 0 || (bs[0] != '-' && (bs[0] < '0' || bs[0] > '9')) {
+		return errors.New("not a number")
+	}
+
+	*n = Number(bs)
+	return nil
+}
+
+// Int returns the number's value if it's an integer, which includes numbers
+// written with a fraction or exponent such as 7.0 or 1e3.  It returns false if
+// the number isn't an integer or needs more than MaxBits bits, both of which
+// are decided from how the number is written without expanding it.
+func (n Number) Int() (*big.Int, bool) {
+	negative, digits, exponent := n.decimal()
+
+	// The digits have no trailing zeros, so a negative exponent always leaves a
+	// fraction.
+	if exponent < 0 {
+		return nil, false
+	}
+
+	// Every decimal digit adds more than 3 bits, so this only rules out numbers
+	// that are certainly too large before they're expanded.
+	if int64(len(digits))+exponent > MaxBits/3 {
+		return nil, false
+	}
+
+	i, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return nil, false
+	}
+	i.Mul(i, new(big.Int).Exp(big.NewInt(10), big.NewInt(exponent), nil))
+	if i.BitLen() > MaxBits {
+		return nil, false
+	}
+
+	if negative {
+		i.Neg(i)
+	}
+	return i, true
+}
+
+// decimal returns the number as digits multiplied by 10 to the power of
+// exponent, where digits has no leading or trailing zeros, or is "0" for
+// zero.  Exponents too large for an int64 are clamped, so that they're still
+// recognized as being far out of range.
+func (n Number) decimal() (bool, string, int64) {
+	const limit = 1 << 62
+
+	s := string(n)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	var exponent int64
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.ParseInt(s[i+1:], 10, 64)
+		if err != nil || e > limit || e < -limit {
+			e = limit
+			if strings.HasPrefix(s[i+1:], "-") {
+				e = -limit
+			}
+		}
+		exponent, s = e, s[:i]
+	}
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	digits := strings.TrimLeft(whole+fraction, "0")
+	trimmed := strings.TrimRight(digits, "0")
+	exponent += int64(len(digits)-len(trimmed)) - int64(len(fraction))
+
+	if trimmed == "" {
+		return false, "0", 0
+	}
+	return negative, trimmed, exponent
+}
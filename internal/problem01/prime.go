@@ -0,0 +1,87 @@
+package problem01
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+// ProbablyPrimeRounds is the number of Miller-Rabin rounds used to test
+// integers that don't fit in 64 bits.
+const ProbablyPrimeRounds = 20
+
+// witnesses are the Miller-Rabin bases that correctly classify every integer
+// below 2^64.
+var witnesses = []uint64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37}
+
+// IsPrime reports whether n is prime.  Integers that fit in 64 bits are tested
+// deterministically, larger ones with a probabilistic test that has a
+// negligible chance of calling a composite number prime.
+func IsPrime(n *big.Int) bool {
+	if n.Sign() <= 0 {
+		return false
+	}
+
+	if n.IsUint64() {
+		return isPrime64(n.Uint64())
+	}
+
+	return n.ProbablyPrime(ProbablyPrimeRounds)
+}
+
+// isPrime64 is a deterministic Miller-Rabin test.
+func isPrime64(n uint64) bool {
+	if n < 2 {
+		return false
+	}
+	for _, p := range witnesses {
+		if n%p == 0 {
+			return n == p
+		}
+	}
+
+	// Write n-1 as d*2^s with d odd.
+	d := n - 1
+	s := bits.TrailingZeros64(d)
+	d >>= s
+
+	for _, a := range witnesses {
+		x := powMod(a, d, n)
+		if x == 1 || x == n-1 {
+			continue
+		}
+
+		composite := true
+		for i := 1; i < s; i++ {
+			x = mulMod(x, x, n)
+			if x == n-1 {
+				composite = false
+				break
+			}
+		}
+		if composite {
+			return false
+		}
+	}
+
+	return true
+}
+
+// mulMod returns a*b mod m without overflowing, a and b must be less than m.
+func mulMod(a, b, m uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	_, rem := bits.Div64(hi, lo, m)
+	return rem
+}
+
+// powMod returns a^e mod m.
+func powMod(a, e, m uint64) uint64 {
+	result := uint64(1)
+	a %= m
+	for ; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			result = mulMod(result, a, m)
+		}
+		a = mulMod(a, a, m)
+	}
+	return result
+}
@@ -0,0 +1,187 @@
+package problem01
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/bbeck/protohackers/internal"
+	"log/slog"
+	"net"
+	"runtime"
+)
+
+type Request struct {
+	Method  *string  `json:"method"`
+	Number  *Number  `json:"number"`
+	Numbers []Number `json:"numbers"`
+}
+
+const Prime = `{"method":"isPrime","prime":true}` + "\n"
+const NotPrime = `{"method":"isPrime","prime":false}` + "\n"
+const Malformed = `{"method":"malformed"}` + "\n"
+
+// Problem is the Prime Time problem.
+var Problem = internal.Problem{ID: 1, Name: "Prime Time", Run: Run}
+
+var (
+	// Concurrency is the number of requests from a single connection that are
+	// decoded and answered at the same time.
+	Concurrency = runtime.GOMAXPROCS(0)
+
+	// MaxPending is the number of requests from a single connection that may be
+	// waiting for their responses to be written before reading stops.
+	MaxPending = 1024
+)
+
+// Run runs the Prime Time server until ctx is cancelled.
+//
+// Requests on a connection are answered concurrently, but their responses are
+// written in the order the requests were received.  Responses are buffered and
+// written together whenever there are no more answered requests waiting.
+//
+// A connection whose first request is a JSON-RPC 2.0 request or batch speaks
+// JSON-RPC for the rest of its life, see HandleRPC.
+//
+// Requests that are still being answered are cancelled once the connection
+// stops being read from or written to, or the server shuts down.
+func Run(ctx context.Context, server *internal.Server) error {
+	return server.ServeTCP(ctx, func(conn net.Conn) {
+		defer conn.Close()
+		logger := internal.Logger(conn)
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		// Each request's response is delivered on its own channel, and the
+		// channels are queued in the order the requests were received.
+		queue := make(chan chan []byte, MaxPending)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			defer cancel()
+			defer conn.Close()
+
+			w := bufio.NewWriter(conn)
+			defer w.Flush()
+
+			for result := range queue {
+				response := <-result
+				if _, err := w.Write(response); err != nil {
+					return
+				}
+				if bytes.Equal(response, []byte(Malformed)) {
+					return
+				}
+				if len(queue) == 0 {
+					if err := w.Flush(); err != nil {
+						return
+					}
+				}
+			}
+		}()
+
+		enqueue := func(result chan []byte) bool {
+			select {
+			case queue <- result:
+				return true
+			case <-done:
+				return false
+			}
+		}
+
+		workers := make(chan struct{}, max(Concurrency, 1))
+		rpc := false
+		r := bufio.NewReaderSize(conn, 1024*1024)
+		for first := true; ; first = false {
+			line, _, err := r.ReadLine()
+			if err != nil {
+				// JSON-RPC clients don't expect a response to the end of the stream.
+				if !rpc {
+					result := make(chan []byte, 1)
+					result <- []byte(Malformed)
+					enqueue(result)
+				}
+				break
+			}
+
+			// The reader reuses its buffer for the next line.
+			bs := bytes.Clone(line)
+			if first && IsRPC(bs) {
+				logger.Debug("speaking JSON-RPC")
+				rpc = true
+			}
+
+			result := make(chan []byte, 1)
+			if !enqueue(result) {
+				break
+			}
+
+			workers <- struct{}{}
+			go func() {
+				defer func() { <-workers }()
+				if rpc {
+					result <- HandleRPC(ctx, logger, bs)
+				} else {
+					result <- Handle(ctx, logger, bs)
+				}
+			}()
+		}
+
+		cancel()
+		close(queue)
+		<-done
+	})
+}
+
+// Handle returns the response to a single request, which is Malformed if the
+// request isn't valid or ctx is cancelled before it's answered.
+func Handle(ctx context.Context, logger *slog.Logger, bs []byte) []byte {
+	var request Request
+	if err := json.Unmarshal(bs, &request); err != nil || IsMalformed(request) {
+		logger.Debug("malformed request", "request", string(bs))
+		return []byte(Malformed)
+	}
+
+	answer, err := Answer(ctx, request)
+	if err != nil {
+		logger.Debug("request cancelled", "request", string(bs), "error", err)
+		return []byte(Malformed)
+	}
+
+	response, err := json.Marshal(answer)
+	if err != nil {
+		logger.Error("error encoding response", "error", err)
+		return []byte(Malformed)
+	}
+	logger.Debug("request handled", "request", string(bs), "response", string(response))
+	return append(response, '\n')
+}
+
+// Answer returns the answer to a well-formed request, either for the request's
+// number or for each number in a batch.  It returns ctx's error if ctx is
+// cancelled before the answer is found.
+func Answer(ctx context.Context, request Request) (any, error) {
+	if request.Number == nil && request.Numbers != nil && Methods[*request.Method].Batch {
+		return HandleBatch(ctx, request)
+	}
+	return Methods[*request.Method].Handle(ctx, request)
+}
+
+// IsMalformed reports whether a request is for a method that the server doesn't
+// support, or is missing the fields that its method needs.
+func IsMalformed(r Request) bool {
+	if r.Method == nil {
+		return true
+	}
+
+	method, found := Methods[*r.Method]
+	if !found {
+		return true
+	}
+
+	if r.Number == nil && r.Numbers != nil && method.Batch {
+		return IsMalformedBatch(r)
+	}
+	return method.IsMalformed(r)
+}
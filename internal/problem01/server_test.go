@@ -0,0 +1,377 @@
+package problem01_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/bbeck/protohackers/internal/problem01"
+	"github.com/bbeck/protohackers/internal/servertest"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsPrime(t *testing.T) {
+	addr := servertest.Start(t, problem01.Problem)
+	conn := servertest.Dial(t, "tcp", addr)
+
+	tests := []struct {
+		request  string
+		response string
+	}{
+		{`{"method":"isPrime","number":7}`, problem01.Prime},
+		{`{"method":"isPrime","number":2}`, problem01.Prime},
+		{`{"method":"isPrime","number":1}`, problem01.NotPrime},
+		{`{"method":"isPrime","number":0}`, problem01.NotPrime},
+		{`{"method":"isPrime","number":-7}`, problem01.NotPrime},
+		{`{"method":"isPrime","number":91}`, problem01.NotPrime},
+		{`{"method":"isPrime","number":7919}`, problem01.Prime},
+		{`{"method":"isPrime","number":7.0}`, problem01.Prime},
+		{`{"method":"isPrime","number":7.5}`, problem01.NotPrime},
+		{`{"number":13,"method":"isPrime","extra":[1,2,3]}`, problem01.Prime},
+		{`{"method":"isPrime","number":1.3e1}`, problem01.Prime},
+		{`{"method":"isPrime","number":1e3}`, problem01.NotPrime},
+		{`{"method":"isPrime","number":1e-3}`, problem01.NotPrime},
+		{`{"method":"isPrime","number":-0}`, problem01.NotPrime},
+	}
+
+	for _, test := range tests {
+		conn.SendString(test.request + "\n")
+		conn.ExpectString(test.response)
+	}
+}
+
+func TestLargeNumbers(t *testing.T) {
+	addr := servertest.Start(t, problem01.Problem)
+	conn := servertest.Dial(t, "tcp", addr)
+
+	tests := []struct {
+		number   string
+		response string
+	}{
+		// Prime, but rounds to an even number as a float64.
+		{"9007199254740997", problem01.Prime},
+		{"9007199254740993", problem01.NotPrime},
+		{"2305843009213693951", problem01.Prime},
+		// A strong pseudoprime to every base up to 23.
+		{"3825123056546413051", problem01.NotPrime},
+		{"18446744073709551557", problem01.Prime},
+		{"18446744073709551615", problem01.NotPrime},
+		{"18446744073709551629", problem01.Prime},
+		{"618970019642690137449562111", problem01.Prime},
+		{"618970019642690137449562113", problem01.NotPrime},
+		{"618970019642690137449562111.0", problem01.Prime},
+		{"618970019642690137449562111.5", problem01.NotPrime},
+		{"170141183460469231731687303715884105727", problem01.Prime},
+		{mersenne(1279), problem01.Prime},
+		{"7." + strings.Repeat("0", 2000), problem01.Prime},
+		{"1e154", problem01.NotPrime},
+		{"1e400", problem01.NotPrime},
+		{"1e-1000", problem01.NotPrime},
+		// Beyond MaxBits, so not tested at all.
+		{"1e1000000000", problem01.NotPrime},
+		{"1e-1000000000", problem01.NotPrime},
+		{"1e99999999999999999999", problem01.NotPrime},
+		{"1" + strings.Repeat("0", 1300) + "1", problem01.NotPrime},
+	}
+
+	for _, test := range tests {
+		conn.SendString(`{"method":"isPrime","number":` + test.number + "}\n")
+		conn.ExpectString(test.response)
+	}
+}
+
+// mersenne returns 2^n-1.
+func mersenne(n uint) string {
+	m := new(big.Int).Lsh(big.NewInt(1), n)
+	return m.Sub(m, big.NewInt(1)).String()
+}
+
+func TestIsPrimeMatchesTrialDivision(t *testing.T) {
+	for n := int64(-10); n < 10000; n++ {
+		prime := n > 1
+		for d := int64(2); d*d <= n; d++ {
+			if n%d == 0 {
+				prime = false
+				break
+			}
+		}
+
+		if got := problem01.IsPrime(big.NewInt(n)); got != prime {
+			t.Errorf("IsPrime(%d) = %t, want %t", n, got, prime)
+		}
+	}
+}
+
+func TestMethods(t *testing.T) {
+	addr := servertest.Start(t, problem01.Problem)
+	conn := servertest.Dial(t, "tcp", addr)
+
+	tests := []struct {
+		request  string
+		response string
+	}{
+		{`{"method":"factorize","number":1}`, `{"method":"factorize","factors":[]}`},
+		{`{"method":"factorize","number":360}`, `{"method":"factorize","factors":[2,2,2,3,3,5]}`},
+		{`{"method":"factorize","number":7919}`, `{"method":"factorize","factors":[7919]}`},
+		{`{"method":"factorize","number":600851475143}`, `{"method":"factorize","factors":[71,839,1471,6857]}`},
+		{`{"method":"factorize","number":18446744073709551615}`, `{"method":"factorize","factors":[3,5,17,257,641,65537,6700417]}`},
+		{`{"method":"factorize","number":4611686014132420609}`, `{"method":"factorize","factors":[2147483647,2147483647]}`},
+		{`{"method":"nextPrime","number":-5}`, `{"method":"nextPrime","prime":2}`},
+		{`{"method":"nextPrime","number":2}`, `{"method":"nextPrime","prime":3}`},
+		{`{"method":"nextPrime","number":13}`, `{"method":"nextPrime","prime":17}`},
+		{`{"method":"nextPrime","number":18446744073709551557}`, `{"method":"nextPrime","prime":18446744073709551629}`},
+		{`{"method":"isPerfectSquare","number":0}`, `{"method":"isPerfectSquare","square":true}`},
+		{`{"method":"isPerfectSquare","number":144}`, `{"method":"isPerfectSquare","square":true}`},
+		{`{"method":"isPerfectSquare","number":145}`, `{"method":"isPerfectSquare","square":false}`},
+		{`{"method":"isPerfectSquare","number":-4}`, `{"method":"isPerfectSquare","square":false}`},
+		{`{"method":"isPerfectSquare","number":2.25}`, `{"method":"isPerfectSquare","square":false}`},
+		{`{"method":"isPerfectSquare","number":1e40}`, `{"method":"isPerfectSquare","square":true}`},
+		{`{"method":"isPerfectSquare","number":1e200}`, `{"method":"isPerfectSquare","square":true}`},
+		{`{"method":"isPerfectSquare","number":1e-200}`, `{"method":"isPerfectSquare","square":false}`},
+		{`{"method":"gcd","numbers":[12,18]}`, `{"method":"gcd","gcd":6}`},
+		{`{"method":"gcd","numbers":[-12,18,27]}`, `{"method":"gcd","gcd":3}`},
+		{`{"method":"gcd","numbers":[0,0]}`, `{"method":"gcd","gcd":0}`},
+		{`{"method":"gcd","numbers":[36893488147419103232,55340232221128654848]}`, `{"method":"gcd","gcd":18446744073709551616}`},
+		{`{"method":"gcd","numbers":[12,1e200]}`, `{"method":"gcd","gcd":4}`},
+	}
+
+	for _, test := range tests {
+		conn.SendString(test.request + "\n")
+		conn.ExpectString(test.response + "\n")
+	}
+}
+
+func TestCancelledMethodsStopEarly(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Neither of these finds its answer on the first try.
+	n := new(big.Int).Exp(big.NewInt(10), big.NewInt(154), nil)
+	if _, err := problem01.NextPrime(ctx, n); err != context.Canceled {
+		t.Errorf("NextPrime() error = %v, want %v", err, context.Canceled)
+	}
+	if _, err := problem01.Factorize(ctx, 4611686014132420609); err != context.Canceled {
+		t.Errorf("Factorize() error = %v, want %v", err, context.Canceled)
+	}
+
+	if _, err := problem01.NextPrime(context.Background(), n); err != nil {
+		t.Errorf("NextPrime() error = %v, want nil", err)
+	}
+}
+
+func TestPipelinedRequests(t *testing.T) {
+	addr := servertest.Start(t, problem01.Problem)
+	conn := servertest.Dial(t, "tcp", addr)
+
+	conn.SendString(
+		`{"method":"isPrime","number":3}` + "\n" +
+			`{"method":"isPrime","number":4}` + "\n" +
+			`{"method":"isPrime","number":5}` + "\n",
+	)
+	conn.ExpectString(problem01.Prime + problem01.NotPrime + problem01.Prime)
+}
+
+func TestManyPipelinedRequests(t *testing.T) {
+	addr := servertest.Start(t, problem01.Problem)
+	conn := servertest.Dial(t, "tcp", addr)
+
+	// Far more requests than are answered concurrently, whose responses must
+	// still come back in order.
+	var requests, responses strings.Builder
+	for n := 0; n < 5000; n++ {
+		fmt.Fprintf(&requests, `{"method":"isPrime","number":%d}`+"\n", n)
+		if problem01.IsPrime(big.NewInt(int64(n))) {
+			responses.WriteString(problem01.Prime)
+		} else {
+			responses.WriteString(problem01.NotPrime)
+		}
+	}
+
+	// The responses have to be read while the requests are still being sent, so
+	// the requests are sent in the background.
+	sent := make(chan error, 1)
+	go func() {
+		_, err := io.WriteString(conn.Conn, requests.String())
+		sent <- err
+	}()
+
+	conn.ExpectString(responses.String())
+	if err := <-sent; err != nil {
+		t.Fatalf("error sending requests: %v", err)
+	}
+}
+
+func TestBatchRequests(t *testing.T) {
+	addr := servertest.Start(t, problem01.Problem)
+	conn := servertest.Dial(t, "tcp", addr)
+
+	tests := []struct {
+		request  string
+		response string
+	}{
+		{
+			`{"method":"isPrime","numbers":[1,2,3,4.5]}`,
+			`{"method":"isPrime","results":[{"method":"isPrime","prime":false},{"method":"isPrime","prime":true},{"method":"isPrime","prime":true},{"method":"isPrime","prime":false}]}`,
+		},
+		{
+			`{"method":"isPrime","numbers":[7,1e200]}`,
+			`{"method":"isPrime","results":[{"method":"isPrime","prime":true},{"method":"isPrime","prime":false}]}`,
+		},
+		{
+			`{"method":"factorize","numbers":[12,13]}`,
+			`{"method":"factorize","results":[{"method":"factorize","factors":[2,2,3]},{"method":"factorize","factors":[13]}]}`,
+		},
+		{
+			`{"method":"nextPrime","numbers":[]}`,
+			`{"method":"nextPrime","results":[]}`,
+		},
+		{
+			// A single number takes precedence over a batch.
+			`{"method":"isPrime","number":4,"numbers":[5]}`,
+			problem01.NotPrime,
+		},
+	}
+
+	for _, test := range tests {
+		conn.SendString(test.request + "\n")
+		conn.ExpectString(strings.TrimSuffix(test.response, "\n") + "\n")
+	}
+}
+
+func TestJSONRPC(t *testing.T) {
+	addr := servertest.Start(t, problem01.Problem)
+	conn := servertest.Dial(t, "tcp", addr)
+
+	tests := []struct {
+		request  string
+		response string
+	}{
+		{
+			`{"jsonrpc":"2.0","method":"isPrime","params":[7],"id":1}`,
+			`{"jsonrpc":"2.0","result":{"method":"isPrime","prime":true},"id":1}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"isPrime","params":{"number":8},"id":"a"}`,
+			`{"jsonrpc":"2.0","result":{"method":"isPrime","prime":false},"id":"a"}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"gcd","params":[12,18],"id":2}`,
+			`{"jsonrpc":"2.0","result":{"method":"gcd","gcd":6},"id":2}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"isPrime","params":[2,4],"id":3}`,
+			`{"jsonrpc":"2.0","result":{"method":"isPrime","results":[{"method":"isPrime","prime":true},{"method":"isPrime","prime":false}]},"id":3}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"isComposite","params":[7],"id":4}`,
+			`{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":4}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"isPrime","params":["7"],"id":5}`,
+			`{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params"},"id":5}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"isPrime","id":6}`,
+			`{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params"},"id":6}`,
+		},
+		{
+			`{"jsonrpc":"1.0","method":"isPrime","params":[7],"id":7}`,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":7}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"isPrime","params":7,"id":8}`,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":8}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"isPrime","params":[7],"id":{}}`,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":1,"id":9}`,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"isPrime","params":[7],"id":10`,
+			`{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`,
+		},
+		{
+			`[]`,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`,
+		},
+		{
+			`[{"jsonrpc":"2.0","method":"isPrime","params":[3],"id":11},1,{"jsonrpc":"2.0","method":"isPrime","params":[3]}]`,
+			`[{"jsonrpc":"2.0","result":{"method":"isPrime","prime":true},"id":11},{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}]`,
+		},
+	}
+
+	// Errors don't close the connection.
+	for _, test := range tests {
+		conn.SendString(test.request + "\n")
+		conn.ExpectString(test.response + "\n")
+	}
+
+	// Notifications aren't answered.
+	conn.SendString(`{"jsonrpc":"2.0","method":"isPrime","params":[7]}` + "\n")
+	conn.SendString(`[{"jsonrpc":"2.0","method":"nextPrime","params":[7]}]` + "\n")
+	conn.ExpectNothing(100 * time.Millisecond)
+}
+
+func TestJSONRPCIsDetectedPerConnection(t *testing.T) {
+	addr := servertest.Start(t, problem01.Problem)
+
+	rpc := servertest.Dial(t, "tcp", addr)
+	rpc.SendString(`{"jsonrpc":"2.0","method":"isPrime","params":[7],"id":1}` + "\n")
+	rpc.ExpectString(`{"jsonrpc":"2.0","result":{"method":"isPrime","prime":true},"id":1}` + "\n")
+
+	// The original protocol carries on working on other connections, but not
+	// once a connection is speaking JSON-RPC.
+	line := servertest.Dial(t, "tcp", addr)
+	line.SendString(`{"method":"isPrime","number":7}` + "\n")
+	line.ExpectString(problem01.Prime)
+
+	rpc.SendString(`{"method":"isPrime","number":7}` + "\n")
+	rpc.ExpectString(`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}` + "\n")
+}
+
+func TestMalformedRequests(t *testing.T) {
+	addr := servertest.Start(t, problem01.Problem)
+
+	requests := []string{
+		`{"method":"isPrime","number":7`,
+		`{"method":"isPrime"}`,
+		`{"number":7}`,
+		`{"method":"isComposite","number":7}`,
+		`{"method":"isPrime","number":"7"}`,
+		`{"method":"isPrime","number":null}`,
+		`{"method":"factorize","number":0}`,
+		`{"method":"factorize","number":7.5}`,
+		`{"method":"factorize","number":18446744073709551616}`,
+		`{"method":"nextPrime"}`,
+		`{"method":"nextPrime","number":1.5}`,
+		`{"method":"isPerfectSquare","numbers":[4,"9"]}`,
+		`{"method":"factorize","numbers":[12,0]}`,
+		`{"method":"isPrime","numbers":7}`,
+		`{"method":"gcd","numbers":[12]}`,
+		`{"method":"gcd","numbers":[12,1.5]}`,
+		`{"method":"gcd","numbers":[12,"18"]}`,
+		`{"method":"gcd","number":12}`,
+		`{"method":"nextPrime","number":1e3000}`,
+		`{"method":"gcd","numbers":[12,1e3000]}`,
+		`[]`,
+		`isPrime 7`,
+		``,
+	}
+
+	for _, request := range requests {
+		t.Run(request, func(t *testing.T) {
+			conn := servertest.Dial(t, "tcp", addr)
+
+			// A well-formed request before the malformed one is still answered.
+			conn.SendString(`{"method":"isPrime","number":7}` + "\n" + request + "\n")
+			conn.ExpectString(problem01.Prime + problem01.Malformed)
+			conn.ExpectClosed()
+		})
+	}
+}
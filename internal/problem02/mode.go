@@ -0,0 +1,97 @@
+package problem02
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// Mode decides how a server treats duplicate timestamps, how it computes means
+// and whether sessions can share series.  The zero value for Mode is the
+// default: every price is kept, sums are accumulated in 64 bits, means are
+// truncated towards zero and every session has a series of its own.
+type Mode struct {
+	// Duplicates decides what happens when a price is inserted for a timestamp
+	// that already has one, one of KeepAll, Reject or Overwrite.  If empty
+	// KeepAll is used.
+	Duplicates string
+
+	// Accumulation decides how prices are summed, either Int64 or BigInt.  If
+	// empty Int64 is used.
+	Accumulation string
+
+	// Rounding decides how a mean that isn't a whole number is rounded, either
+	// Truncate or HalfEven.  If empty Truncate is used.
+	Rounding string
+
+	// SeriesDir is a directory that shared series are persisted in, see
+	// Catalog.  If empty sessions can't attach to shared series.
+	SeriesDir string
+}
+
+const (
+	// KeepAll keeps every price inserted for a timestamp, and each of them
+	// counts towards the answers to queries.
+	KeepAll = "keep-all"
+
+	// Reject ignores a price inserted for a timestamp that already has one.
+	Reject = "reject"
+
+	// Overwrite replaces the price for a timestamp that already has one.
+	Overwrite = "overwrite"
+)
+
+const (
+	// Int64 sums prices in 64 bits, which can't overflow unless more than 2^32
+	// prices are summed.
+	Int64 = "int64"
+
+	// BigInt sums prices exactly, however many there are, at the cost of an
+	// allocation for every sum.
+	BigInt = "big"
+)
+
+const (
+	// Truncate rounds means towards zero.
+	Truncate = "truncate"
+
+	// HalfEven rounds means to the nearest integer, and halves to the nearest
+	// even integer.
+	HalfEven = "half-even"
+)
+
+// RegisterFlags registers a command line flag for each field of the mode.
+func (m *Mode) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&m.Duplicates, "duplicates", KeepAll, "what to do with a price for a timestamp that already has one: keep-all, reject or overwrite")
+	fs.StringVar(&m.Accumulation, "accumulation", Int64, "how prices are summed: int64 or big")
+	fs.StringVar(&m.Rounding, "rounding", Truncate, "how means are rounded: truncate or half-even")
+	fs.StringVar(&m.SeriesDir, "series-dir", "", "directory to persist series shared between sessions in")
+}
+
+// Validate checks that each field of the mode is one of the allowed values, and
+// that the series directory exists.
+func (m Mode) Validate() error {
+	var errs []error
+	switch m.Duplicates {
+	case "", KeepAll, Reject, Overwrite:
+	default:
+		errs = append(errs, fmt.Errorf("invalid duplicate policy: %q", m.Duplicates))
+	}
+	switch m.Accumulation {
+	case "", Int64, BigInt:
+	default:
+		errs = append(errs, fmt.Errorf("invalid accumulation: %q", m.Accumulation))
+	}
+	switch m.Rounding {
+	case "", Truncate, HalfEven:
+	default:
+		errs = append(errs, fmt.Errorf("invalid rounding: %q", m.Rounding))
+	}
+	if m.SeriesDir != "" {
+		if info, err := os.Stat(m.SeriesDir); err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Errorf("invalid series directory: %q is not a directory", m.SeriesDir))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,238 @@
+package problem02
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// SeriesExtension is the extension of the log files that series are persisted
+// in.
+const SeriesExtension = ".series"
+
+// SeriesName matches the names that series may have.  Names are at most 8
+// bytes so that they fit in an attach message.
+var SeriesName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,8}$`)
+
+// ErrSeriesClosed is returned when a series is changed after its catalog has
+// been closed.
+var ErrSeriesClosed = errors.New("series closed")
+
+// Series is a store of prices that's safe for concurrent use, so that it can
+// be shared by every session attached to it.  A series in a catalog appends
+// each change to a log file, which is replayed when the catalog is opened
+// again.
+//
+// The log is a sequence of 9 byte records with the same layout as the
+// messages that made the changes: an 'I' with the timestamp and price that
+// were inserted, or a 'D' with the timestamp that was deleted and 0.  Only
+// changes that had an effect are recorded, and replaying them follows the
+// catalog's mode.
+type Series struct {
+	Name string
+
+	mu     sync.Mutex
+	store  Store
+	log    *os.File
+	closed bool
+}
+
+// NewSeries returns a series that isn't persisted, such as the private series
+// of a session that isn't attached to a shared one.
+func NewSeries(mode Mode) *Series {
+	return &Series{store: Store{Mode: mode}}
+}
+
+// Insert adds a price to the series, following the mode's duplicate policy if
+// there's already a price for its timestamp.  It reports whether the price was
+// added.
+func (s *Series) Insert(p Price) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.store.Mode.Duplicates == Reject && s.store.Summary(p.Timestamp, p.Timestamp).Count > 0 {
+		return false, nil
+	}
+	if err := s.append('I', p.Timestamp, p.Price); err != nil {
+		return false, err
+	}
+	return s.store.Insert(p), nil
+}
+
+// Delete removes every price with timestamp t from the series, returning the
+// number of prices removed.
+func (s *Series) Delete(t int32) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.store.Summary(t, t).Count == 0 {
+		return 0, nil
+	}
+	if err := s.append('D', t, 0); err != nil {
+		return 0, err
+	}
+	return s.store.Delete(t), nil
+}
+
+// Summary returns the summary of the prices with a timestamp between min and
+// max inclusive.
+func (s *Series) Summary(min, max int32) Summary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store.Summary(min, max)
+}
+
+// Median returns the median of the prices with a timestamp between min and max
+// inclusive, see Store.Median.
+func (s *Series) Median(min, max int32) int32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store.Median(min, max)
+}
+
+// append writes a record of a change to the series' log, if it has one.
+func (s *Series) append(kind byte, a, b int32) error {
+	if s.closed {
+		return ErrSeriesClosed
+	}
+	if s.log == nil {
+		return nil
+	}
+
+	record := []byte{kind}
+	record = binary.BigEndian.AppendUint32(record, uint32(a))
+	record = binary.BigEndian.AppendUint32(record, uint32(b))
+	if _, err := s.log.Write(record); err != nil {
+		return fmt.Errorf("error writing to series %s: %w", s.Name, err)
+	}
+	return nil
+}
+
+// Catalog holds the shared series of a server, which are persisted in a
+// directory with a log file per series.
+type Catalog struct {
+	Dir  string
+	Mode Mode
+
+	mu     sync.Mutex
+	series map[string]*Series
+}
+
+// OpenCatalog opens the catalog of series in dir, replaying the log of every
+// series that's already there.  A log that ends with a partial record, as
+// happens when the server stops in the middle of writing one, has the partial
+// record removed.
+func OpenCatalog(dir string, mode Mode) (*Catalog, error) {
+	c := &Catalog{Dir: dir, Mode: mode, series: make(map[string]*Series)}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error opening series directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), SeriesExtension)
+		if !ok || entry.IsDir() || !SeriesName.MatchString(name) {
+			continue
+		}
+
+		if _, err := c.open(name); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Series returns the series with the given name, creating it if it doesn't
+// exist yet.
+func (c *Catalog) Series(name string) (*Series, error) {
+	if !SeriesName.MatchString(name) {
+		return nil, fmt.Errorf("invalid series name: %q", name)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s, ok := c.series[name]; ok {
+		return s, nil
+	}
+	return c.open(name)
+}
+
+// Close closes the log of every series.  Changes to a series once its catalog
+// is closed fail with ErrSeriesClosed.
+func (c *Catalog) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+	for _, s := range c.series {
+		s.mu.Lock()
+		if !s.closed {
+			s.closed = true
+			errs = append(errs, s.log.Sync(), s.log.Close())
+		}
+		s.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// open opens the log of the named series and replays it, c.mu must be held.
+func (c *Catalog) open(name string) (*Series, error) {
+	path := filepath.Join(c.Dir, name+SeriesExtension)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening series %s: %w", name, err)
+	}
+
+	s := &Series{Name: name, store: Store{Mode: c.Mode}}
+	size, err := s.replay(f)
+	if err == nil {
+		// Drop any partial record and append after the last complete one.
+		if err = f.Truncate(size); err == nil {
+			_, err = f.Seek(size, io.SeekStart)
+		}
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("error loading series %s: %w", name, err)
+	}
+
+	s.log = f
+	c.series[name] = s
+	return s, nil
+}
+
+// replay applies every complete record in a log to the series' store,
+// returning the size of the complete records.
+func (s *Series) replay(r io.Reader) (int64, error) {
+	var size int64
+	record := make([]byte, 9)
+	for {
+		if _, err := io.ReadFull(r, record); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return size, nil
+		} else if err != nil {
+			return size, err
+		}
+
+		a := int32(binary.BigEndian.Uint32(record[1:]))
+		b := int32(binary.BigEndian.Uint32(record[5:]))
+		switch record[0] {
+		case 'I':
+			s.store.Insert(Price{Timestamp: a, Price: b})
+		case 'D':
+			s.store.Delete(a)
+		default:
+			return size, fmt.Errorf("invalid record type 0x%02x at offset %d", record[0], size)
+		}
+		size += int64(len(record))
+	}
+}
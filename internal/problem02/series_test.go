@@ -0,0 +1,86 @@
+package problem02_test
+
+import (
+	"github.com/bbeck/protohackers/internal/problem02"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCatalogReplaysLogs(t *testing.T) {
+	dir := t.TempDir()
+
+	catalog, err := problem02.OpenCatalog(dir, problem02.Mode{Duplicates: problem02.Reject})
+	if err != nil {
+		t.Fatalf("OpenCatalog() = %v", err)
+	}
+
+	series, err := catalog.Series("goog")
+	if err != nil {
+		t.Fatalf("Series() = %v", err)
+	}
+	series.Insert(problem02.Price{Timestamp: 1, Price: 10})
+	series.Insert(problem02.Price{Timestamp: 1, Price: 99})
+	series.Insert(problem02.Price{Timestamp: 2, Price: 20})
+	series.Insert(problem02.Price{Timestamp: 3, Price: 30})
+	series.Delete(3)
+	series.Delete(4)
+	if err := catalog.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	// Only the changes that had an effect are recorded.
+	path := filepath.Join(dir, "goog"+problem02.SeriesExtension)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 4*9 {
+		t.Fatalf("log is %d bytes, want %d", info.Size(), 4*9)
+	}
+
+	// A partial record from an interrupted write is dropped.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{'I', 0, 0})
+	f.Close()
+
+	catalog, err = problem02.OpenCatalog(dir, problem02.Mode{Duplicates: problem02.Reject})
+	if err != nil {
+		t.Fatalf("OpenCatalog() = %v", err)
+	}
+
+	series, _ = catalog.Series("goog")
+	if s := series.Summary(0, 10); s.Count != 2 || s.Sum != 30 {
+		t.Errorf("Summary() = %+v, want 2 prices summing to 30", s)
+	}
+
+	series.Insert(problem02.Price{Timestamp: 5, Price: 50})
+	if info, err := os.Stat(path); err != nil {
+		t.Error(err)
+	} else if info.Size() != 5*9 {
+		t.Errorf("log is %d bytes after insert, want %d", info.Size(), 5*9)
+	}
+
+	if _, err := series.Insert(problem02.Price{Timestamp: 6, Price: 60}); err != nil {
+		t.Errorf("Insert() = %v", err)
+	}
+	catalog.Close()
+	if _, err := series.Insert(problem02.Price{Timestamp: 7, Price: 70}); err != problem02.ErrSeriesClosed {
+		t.Errorf("Insert() after Close() = %v, want %v", err, problem02.ErrSeriesClosed)
+	}
+}
+
+func TestCatalogRejectsCorruptLogs(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bad"+problem02.SeriesExtension)
+	if err := os.WriteFile(path, []byte{'X', 0, 0, 0, 1, 0, 0, 0, 2}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := problem02.OpenCatalog(dir, problem02.Mode{}); err == nil {
+		t.Errorf("OpenCatalog() = nil, want error")
+	}
+}
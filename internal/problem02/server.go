@@ -0,0 +1,189 @@
+package problem02
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"github.com/bbeck/protohackers/internal"
+	"math"
+	"math/big"
+	"net"
+)
+
+type Price struct {
+	Timestamp, Price int32
+}
+
+// Problem is the Means to an End problem.
+var Problem = internal.Problem{ID: 2, Name: "Means to an End", Run: Run}
+
+// Run runs the Means to an End server with the default mode until ctx is
+// cancelled.
+func Run(ctx context.Context, server *internal.Server) error {
+	return Mode{}.Run(ctx, server)
+}
+
+// Run runs the Means to an End server with the mode until ctx is cancelled.
+// Each session has its own series of prices, which follows the mode, unless it
+// attaches to a shared one.
+//
+// Besides inserting prices ('I') and querying their mean ('Q'), clients may
+// query the min ('L'), max ('H'), median ('M'), count ('C'), sum ('S') and
+// population standard deviation ('V') of the prices with a timestamp between
+// the two integers in the message, inclusive.  Every answer is a signed 32-bit
+// integer: an empty range, including one whose min is after its max, is
+// answered with 0, and a count or sum that doesn't fit is clamped.  A delete
+// ('D') removes every price at the first integer's timestamp and isn't
+// answered; the second integer is ignored.  A message that's cut short by the
+// client disconnecting is discarded.
+//
+// What happens when a timestamp is inserted more than once depends on the
+// mode's duplicate policy.  By default every price inserted for it is kept and
+// counts towards the answers.
+//
+// When the mode has a series directory a session may attach to a shared
+// series, which is persisted in the directory, by making its first message an
+// attach ('A').  The attach's 8 bytes are the series' name, padded with zero
+// bytes, and it isn't answered.  Every session attached to a series sees the
+// changes the others make.  An attach that isn't the first message, or that
+// names an invalid series, closes the connection.
+func (m Mode) Run(ctx context.Context, server *internal.Server) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+
+	var catalog *Catalog
+	if m.SeriesDir != "" {
+		var err error
+		if catalog, err = OpenCatalog(m.SeriesDir, m); err != nil {
+			return err
+		}
+		defer catalog.Close()
+	}
+
+	return server.ServeTCP(ctx, func(conn net.Conn) {
+		var err error
+		read := func(data ...any) {
+			for i := 0; err == nil && i < len(data); i++ {
+				err = binary.Read(conn, binary.BigEndian, data[i])
+			}
+		}
+
+		write := func(data ...any) {
+			for i := 0; err == nil && i < len(data); i++ {
+				err = binary.Write(conn, binary.BigEndian, data[i])
+			}
+		}
+
+		defer conn.Close()
+		logger := internal.Logger(conn)
+
+		series := NewSeries(m)
+		for first := true; ; first = false {
+			var kind byte
+			var a, b int32
+			read(&kind, &a, &b)
+			if err != nil {
+				return
+			}
+
+			if kind == 'A' && first && catalog != nil {
+				name := attachName(a, b)
+				shared, e := catalog.Series(name)
+				if e != nil {
+					logger.Debug("invalid series", "series", name, "error", e)
+					return
+				}
+				series = shared
+				logger.Debug("series attached", "series", name)
+				continue
+			}
+
+			switch kind {
+			case 'I':
+				inserted, e := series.Insert(Price{Timestamp: a, Price: b})
+				if e != nil {
+					logger.Error("error inserting price", "error", e)
+					return
+				}
+				if !inserted {
+					logger.Debug("duplicate price rejected", "timestamp", a, "price", b)
+					break
+				}
+				logger.Debug("price inserted", "timestamp", a, "price", b)
+
+			case 'Q':
+				summary := series.Summary(a, b)
+				mean := summary.Mean(m.Rounding)
+				logger.Debug("mean queried", "min_time", a, "max_time", b, "count", summary.Count, "mean", mean)
+				write(mean)
+
+			case 'L':
+				summary := series.Summary(a, b)
+				logger.Debug("min queried", "min_time", a, "max_time", b, "count", summary.Count, "min", summary.Min)
+				write(summary.Min)
+
+			case 'H':
+				summary := series.Summary(a, b)
+				logger.Debug("max queried", "min_time", a, "max_time", b, "count", summary.Count, "max", summary.Max)
+				write(summary.Max)
+
+			case 'M':
+				median := series.Median(a, b)
+				logger.Debug("median queried", "min_time", a, "max_time", b, "median", median)
+				write(median)
+
+			case 'C':
+				summary := series.Summary(a, b)
+				logger.Debug("count queried", "min_time", a, "max_time", b, "count", summary.Count)
+				write(clamp(int64(summary.Count)))
+
+			case 'S':
+				summary := series.Summary(a, b)
+				logger.Debug("sum queried", "min_time", a, "max_time", b, "count", summary.Count, "sum", summary.Sum)
+				if summary.BigSum != nil {
+					write(clampBig(summary.BigSum))
+				} else {
+					write(clamp(summary.Sum))
+				}
+
+			case 'V':
+				summary := series.Summary(a, b)
+				logger.Debug("standard deviation queried", "min_time", a, "max_time", b, "count", summary.Count, "stddev", summary.StdDev())
+				write(summary.StdDev())
+
+			case 'D':
+				n, e := series.Delete(a)
+				if e != nil {
+					logger.Error("error deleting prices", "error", e)
+					return
+				}
+				logger.Debug("prices deleted", "timestamp", a, "count", n)
+
+			default:
+				logger.Debug("unsupported message", "type", kind)
+				return
+			}
+		}
+	})
+}
+
+// clamp converts n to an int32, clamping it to the range of an int32.
+func clamp(n int64) int32 {
+	return int32(min(max(n, math.MinInt32), math.MaxInt32))
+}
+
+// clampBig converts n to an int32, clamping it to the range of an int32.
+func clampBig(n *big.Int) int32 {
+	if !n.IsInt64() {
+		return clamp(int64(n.Sign()) * math.MaxInt64)
+	}
+	return clamp(n.Int64())
+}
+
+// attachName returns the series name in an attach message's two integers.
+func attachName(a, b int32) string {
+	bs := binary.BigEndian.AppendUint32(nil, uint32(a))
+	bs = binary.BigEndian.AppendUint32(bs, uint32(b))
+	return string(bytes.TrimRight(bs, "\x00"))
+}
@@ -0,0 +1,322 @@
+package problem02_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/bbeck/protohackers/internal"
+	"github.com/bbeck/protohackers/internal/problem02"
+	"github.com/bbeck/protohackers/internal/servertest"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func message(kind byte, a, b int32) []byte {
+	bs := []byte{kind}
+	bs = binary.BigEndian.AppendUint32(bs, uint32(a))
+	bs = binary.BigEndian.AppendUint32(bs, uint32(b))
+	return bs
+}
+
+func mean(n int32) []byte {
+	return binary.BigEndian.AppendUint32(nil, uint32(n))
+}
+
+func TestExampleSession(t *testing.T) {
+	addr := servertest.Start(t, problem02.Problem)
+	conn := servertest.Dial(t, "tcp", addr)
+
+	conn.Send(message('I', 12345, 101))
+	conn.Send(message('I', 12346, 102))
+	conn.Send(message('I', 12347, 100))
+	conn.Send(message('I', 40960, 5))
+	conn.Send(message('Q', 12288, 16384))
+	conn.Expect(mean(101))
+}
+
+func TestQueryRanges(t *testing.T) {
+	addr := servertest.Start(t, problem02.Problem)
+	conn := servertest.Dial(t, "tcp", addr)
+
+	// Nothing has been inserted yet.
+	conn.Send(message('Q', 0, 100))
+	conn.Expect(mean(0))
+
+	conn.Send(message('I', 10, 10))
+	conn.Send(message('I', 20, -30))
+	conn.Send(message('I', 30, 50))
+	conn.Send(message('I', -5, 7))
+
+	tests := []struct {
+		name     string
+		min, max int32
+		mean     int32
+	}{
+		{"everything", -100, 100, 9},
+		{"bounds are inclusive", 10, 30, 10},
+		{"single price", 20, 20, -30},
+		{"negative timestamps", -10, 0, 7},
+		{"mean truncates", -10, 10, 8},
+		{"nothing in range", 31, 100, 0},
+		{"min after max", 30, 10, 0},
+		{"extreme bounds", -2147483648, 2147483647, 9},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn.Send(message('Q', test.min, test.max))
+			conn.Expect(mean(test.mean))
+		})
+	}
+}
+
+func TestAggregateQueries(t *testing.T) {
+	addr := servertest.Start(t, problem02.Problem)
+	conn := servertest.Dial(t, "tcp", addr)
+
+	conn.Send(message('I', 1, 2))
+	conn.Send(message('I', 2, 4))
+	conn.Send(message('I', 3, 4))
+	conn.Send(message('I', 4, 4))
+	conn.Send(message('I', 5, 5))
+	conn.Send(message('I', 6, 5))
+	conn.Send(message('I', 7, 7))
+	conn.Send(message('I', 8, 9))
+	conn.Send(message('I', 100, 2147483647))
+	conn.Send(message('I', 101, 2147483647))
+
+	tests := []struct {
+		name     string
+		kind     byte
+		min, max int32
+		answer   int32
+	}{
+		{"min", 'L', 1, 8, 2},
+		{"max", 'H', 1, 8, 9},
+		{"median of odd count", 'M', 1, 7, 4},
+		{"median of even count", 'M', 1, 8, 4},
+		{"median truncates", 'M', 7, 8, 8},
+		{"count", 'C', 1, 8, 8},
+		{"sum", 'S', 1, 8, 40},
+		{"sum is clamped", 'S', 100, 101, 2147483647},
+		{"stddev", 'V', 1, 8, 2},
+		{"stddev of one price", 'V', 5, 5, 0},
+		{"empty min", 'L', 9, 99, 0},
+		{"empty max", 'H', 9, 99, 0},
+		{"empty median", 'M', 9, 99, 0},
+		{"empty count", 'C', 9, 99, 0},
+		{"empty sum", 'S', 9, 99, 0},
+		{"empty stddev", 'V', 9, 99, 0},
+		{"min after max", 'C', 8, 1, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn.Send(message(test.kind, test.min, test.max))
+			conn.Expect(mean(test.answer))
+		})
+	}
+}
+
+func TestDuplicateTimestampsAndDelete(t *testing.T) {
+	addr := servertest.Start(t, problem02.Problem)
+	conn := servertest.Dial(t, "tcp", addr)
+
+	conn.Send(message('I', 1, 10))
+	conn.Send(message('I', 2, 20))
+	conn.Send(message('I', 2, 40))
+
+	// Every price at a timestamp is kept.
+	conn.Send(message('C', 2, 2))
+	conn.Expect(mean(2))
+	conn.Send(message('Q', 0, 10))
+	conn.Expect(mean(23))
+
+	// A delete removes all of them and isn't answered.
+	conn.Send(message('D', 2, 0))
+	conn.Send(message('D', 5, 0))
+	conn.Send(message('C', 0, 10))
+	conn.Expect(mean(1))
+	conn.Send(message('Q', 0, 10))
+	conn.Expect(mean(10))
+}
+
+func TestServerMode(t *testing.T) {
+	mode := problem02.Mode{Duplicates: problem02.Overwrite, Accumulation: problem02.BigInt, Rounding: problem02.HalfEven}
+	addr := servertest.Start(t, internal.Problem{Name: "Means to an End", Run: mode.Run})
+	conn := servertest.Dial(t, "tcp", addr)
+
+	conn.Send(message('I', 1, 1))
+	conn.Send(message('I', 2, 5))
+	conn.Send(message('I', 2, 2))
+
+	// The second price for timestamp 2 replaced the first, and 1.5 rounds to 2.
+	conn.Send(message('C', 0, 10))
+	conn.Expect(mean(2))
+	conn.Send(message('Q', 0, 10))
+	conn.Expect(mean(2))
+	conn.Send(message('S', 0, 10))
+	conn.Expect(mean(3))
+}
+
+func attach(name string) []byte {
+	bs := make([]byte, 9)
+	bs[0] = 'A'
+	copy(bs[1:], name)
+	return bs
+}
+
+func TestSharedSeries(t *testing.T) {
+	mode := problem02.Mode{SeriesDir: t.TempDir()}
+	addr := servertest.Start(t, internal.Problem{Name: "Means to an End", Run: mode.Run})
+	a := servertest.Dial(t, "tcp", addr)
+	b := servertest.Dial(t, "tcp", addr)
+	c := servertest.Dial(t, "tcp", addr)
+
+	a.Send(attach("aapl"))
+	b.Send(attach("aapl"))
+	a.Send(message('I', 1, 100))
+	b.Send(message('I', 2, 200))
+	c.Send(message('I', 3, 300))
+
+	a.Send(message('Q', 0, 10))
+	a.Expect(mean(150))
+	b.Send(message('C', 0, 10))
+	b.Expect(mean(2))
+
+	// A session that didn't attach has a series of its own.
+	c.Send(message('Q', 0, 10))
+	c.Expect(mean(300))
+}
+
+func TestSharedSeriesArePersisted(t *testing.T) {
+	mode := problem02.Mode{SeriesDir: t.TempDir()}
+
+	t.Run("before restart", func(t *testing.T) {
+		addr := servertest.Start(t, internal.Problem{Name: "Means to an End", Run: mode.Run})
+		conn := servertest.Dial(t, "tcp", addr)
+
+		conn.Send(attach("msft"))
+		conn.Send(message('I', 1, 10))
+		conn.Send(message('I', 2, 20))
+		conn.Send(message('I', 3, 30))
+		conn.Send(message('D', 2, 0))
+		conn.Send(message('C', 0, 10))
+		conn.Expect(mean(2))
+	})
+
+	t.Run("after restart", func(t *testing.T) {
+		addr := servertest.Start(t, internal.Problem{Name: "Means to an End", Run: mode.Run})
+		conn := servertest.Dial(t, "tcp", addr)
+
+		conn.Send(attach("msft"))
+		conn.Send(message('Q', 0, 10))
+		conn.Expect(mean(20))
+	})
+}
+
+func TestTruncatedMessages(t *testing.T) {
+	mode := problem02.Mode{SeriesDir: t.TempDir()}
+	path := filepath.Join(mode.SeriesDir, "aapl"+problem02.SeriesExtension)
+
+	var before []byte
+	t.Run("truncated", func(t *testing.T) {
+		addr := servertest.Start(t, internal.Problem{Name: "Means to an End", Run: mode.Run})
+
+		conn := servertest.Dial(t, "tcp", addr)
+		conn.Send(attach("aapl"))
+		conn.Send(message('I', 1, 100))
+		conn.Send(message('C', 0, 10))
+		conn.Expect(mean(1))
+
+		var err error
+		if before, err = os.ReadFile(path); err != nil {
+			t.Fatalf("error reading series: %v", err)
+		}
+
+		// Clients that go away part way through a message.
+		conn.Send(message('I', 2, 200)[:7])
+		_ = conn.Close()
+
+		conn = servertest.Dial(t, "tcp", addr)
+		conn.Send(attach("aapl"))
+		conn.Send(message('D', 1, 0)[:5])
+		_ = conn.Close()
+
+		conn = servertest.Dial(t, "tcp", addr)
+		conn.Send(attach("goog")[:5])
+		_ = conn.Close()
+	})
+
+	// The server has stopped, so it's finished with every message it received.
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("error reading series: %v", err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Errorf("series log changed by truncated messages\nbefore: %q\n after: %q", before, after)
+	}
+	if _, err := os.Stat(filepath.Join(mode.SeriesDir, "goog"+problem02.SeriesExtension)); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("truncated attach created a series: %v", err)
+	}
+
+	t.Run("after restart", func(t *testing.T) {
+		addr := servertest.Start(t, internal.Problem{Name: "Means to an End", Run: mode.Run})
+		conn := servertest.Dial(t, "tcp", addr)
+
+		conn.Send(attach("aapl"))
+		conn.Send(message('C', 0, 10))
+		conn.Expect(mean(1))
+		conn.Send(message('Q', 0, 10))
+		conn.Expect(mean(100))
+	})
+}
+
+func TestInvalidAttach(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     problem02.Mode
+		messages [][]byte
+	}{
+		{"without a series directory", problem02.Mode{}, [][]byte{attach("aapl")}},
+		{"after the first message", problem02.Mode{SeriesDir: t.TempDir()}, [][]byte{message('I', 1, 2), attach("aapl")}},
+		{"empty name", problem02.Mode{SeriesDir: t.TempDir()}, [][]byte{attach("")}},
+		{"invalid name", problem02.Mode{SeriesDir: t.TempDir()}, [][]byte{attach("../x")}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			addr := servertest.Start(t, internal.Problem{Name: "Means to an End", Run: test.mode.Run})
+			conn := servertest.Dial(t, "tcp", addr)
+
+			for _, m := range test.messages {
+				conn.Send(m)
+			}
+			conn.ExpectClosed()
+		})
+	}
+}
+
+func TestSessionsAreIndependent(t *testing.T) {
+	addr := servertest.Start(t, problem02.Problem)
+	a := servertest.Dial(t, "tcp", addr)
+	b := servertest.Dial(t, "tcp", addr)
+
+	a.Send(message('I', 1, 100))
+	b.Send(message('I', 1, 200))
+
+	a.Send(message('Q', 0, 10))
+	a.Expect(mean(100))
+	b.Send(message('Q', 0, 10))
+	b.Expect(mean(200))
+}
+
+func TestUnknownMessageType(t *testing.T) {
+	addr := servertest.Start(t, problem02.Problem)
+	conn := servertest.Dial(t, "tcp", addr)
+
+	conn.Send(message('X', 1, 2))
+	conn.ExpectClosed()
+}
@@ -0,0 +1,342 @@
+package problem02
+
+import (
+	"math"
+	"math/big"
+	"math/bits"
+	"math/rand"
+	"slices"
+)
+
+// Store holds a session's prices ordered by timestamp so that the prices in a
+// range of timestamps can be summarized in logarithmic time.  Its mode decides
+// what happens to prices with the same timestamp; when they're all kept they
+// are in the order they were inserted, and each of them counts towards a
+// summary.  The zero value is an empty store with the default mode.  The mode
+// must not change once prices have been inserted.
+//
+// The prices are kept in a treap, a binary search tree on timestamps that's
+// kept balanced by also keeping it a heap on random priorities.  Each node
+// holds the summary of the prices in its subtree.
+type Store struct {
+	Mode Mode
+	root *node
+}
+
+type node struct {
+	Price
+	priority    uint64
+	left, right *node
+	summary     Summary
+
+	// exact is set when the store accumulates with BigInt.
+	exact bool
+}
+
+// update recomputes the node's subtree summary from its children.
+func (n *node) update() {
+	n.summary = Summary{}
+	if n.left != nil {
+		n.summary.merge(n.left.summary)
+	}
+	n.summary.add(n.Price.Price, n.exact)
+	if n.right != nil {
+		n.summary.merge(n.right.summary)
+	}
+}
+
+// Summary describes the prices in a range of timestamps.
+type Summary struct {
+	Count    int
+	Sum      int64
+	Min, Max int32
+
+	// BigSum is the exact sum of the prices when the store accumulates with
+	// BigInt, in which case Sum may have overflowed.  Otherwise it's nil.
+	BigSum *big.Int
+
+	// squares is the sum of the squares of the prices, which needs 128 bits.
+	squares [2]uint64
+}
+
+func (s *Summary) add(price int32, exact bool) {
+	square := int64(price) * int64(price)
+	o := Summary{Count: 1, Sum: int64(price), Min: price, Max: price, squares: [2]uint64{0, uint64(square)}}
+	if exact {
+		o.BigSum = big.NewInt(int64(price))
+	}
+	s.merge(o)
+}
+
+func (s *Summary) merge(o Summary) {
+	if o.Count == 0 {
+		return
+	}
+	if s.Count == 0 {
+		*s = o
+		return
+	}
+
+	s.Count += o.Count
+	s.Sum += o.Sum
+	s.Min = min(s.Min, o.Min)
+	s.Max = max(s.Max, o.Max)
+	if s.BigSum != nil && o.BigSum != nil {
+		// Summaries share their sums, so a new one is needed.
+		s.BigSum = new(big.Int).Add(s.BigSum, o.BigSum)
+	}
+
+	var carry uint64
+	s.squares[1], carry = bits.Add64(s.squares[1], o.squares[1], 0)
+	s.squares[0], _ = bits.Add64(s.squares[0], o.squares[0], carry)
+}
+
+// Mean returns the mean of the prices, rounded with the rounding mode, or 0 if
+// there aren't any.
+func (s Summary) Mean(rounding string) int32 {
+	if s.Count == 0 {
+		return 0
+	}
+
+	if s.BigSum != nil {
+		n := big.NewInt(int64(s.Count))
+		q, r := new(big.Int).QuoRem(s.BigSum, n, new(big.Int))
+		if rounding == HalfEven {
+			// Compare twice the remainder's magnitude with the divisor.
+			c := r.Abs(r).Lsh(r, 1).Cmp(n)
+			if c > 0 || (c == 0 && q.Bit(0) == 1) {
+				q.Add(q, big.NewInt(int64(s.BigSum.Sign())))
+			}
+		}
+		return int32(q.Int64())
+	}
+
+	n := int64(s.Count)
+	q, r := s.Sum/n, s.Sum%n
+	if rounding == HalfEven {
+		twice := 2 * r
+		if twice < 0 {
+			twice = -twice
+		}
+		if twice > n || (twice == n && q%2 != 0) {
+			if s.Sum < 0 {
+				q--
+			} else {
+				q++
+			}
+		}
+	}
+	return int32(q)
+}
+
+// StdDev returns the population standard deviation of the prices, truncated
+// towards zero, or 0 if there aren't any.
+func (s Summary) StdDev() int32 {
+	if s.Count == 0 {
+		return 0
+	}
+
+	// The variance is (n*squares - sum^2) / n^2, so the standard deviation is
+	// sqrt(n*squares - sum^2) / n.  Truncating the square root first doesn't
+	// change the truncated result.
+	squares := new(big.Int).SetUint64(s.squares[0])
+	squares.Lsh(squares, 64).Or(squares, new(big.Int).SetUint64(s.squares[1]))
+
+	n := big.NewInt(int64(s.Count))
+	sum := big.NewInt(s.Sum)
+	if s.BigSum != nil {
+		sum.Set(s.BigSum)
+	}
+
+	v := new(big.Int).Mul(n, squares)
+	v.Sub(v, sum.Mul(sum, sum))
+	v.Sqrt(v)
+	return int32(v.Quo(v, n).Int64())
+}
+
+// Insert adds a price to the store, following the store's duplicate policy if
+// there's already a price for its timestamp.  It reports whether the price was
+// added.
+func (s *Store) Insert(p Price) bool {
+	switch s.Mode.Duplicates {
+	case Reject:
+		if s.Summary(p.Timestamp, p.Timestamp).Count > 0 {
+			return false
+		}
+	case Overwrite:
+		s.Delete(p.Timestamp)
+	}
+
+	n := &node{Price: p, priority: rand.Uint64(), exact: s.Mode.Accumulation == BigInt}
+	n.update()
+	s.root = insert(s.root, n)
+	return true
+}
+
+// Delete removes every price with timestamp t from the store, returning the
+// number of prices removed.
+func (s *Store) Delete(t int32) int {
+	left, right := split(s.root, t)
+
+	var at *node
+	if t == math.MinInt32 {
+		left, at = nil, left
+	} else {
+		left, at = split(left, t-1)
+	}
+
+	s.root = merge(left, right)
+	if at == nil {
+		return 0
+	}
+	return at.summary.Count
+}
+
+// Len returns the number of prices in the store.
+func (s *Store) Len() int {
+	if s.root == nil {
+		return 0
+	}
+	return s.root.summary.Count
+}
+
+// Summary returns the summary of the prices with a timestamp between min and
+// max inclusive.  The range is empty if min is after max.
+func (s *Store) Summary(min, max int32) Summary {
+	var summary Summary
+	summarize(s.root, min, max, false, false, &summary)
+	return summary
+}
+
+// Prices returns the prices with a timestamp between min and max inclusive, in
+// timestamp order.  Unlike a summary this takes time proportional to the
+// number of prices in the range.
+func (s *Store) Prices(min, max int32) []Price {
+	var prices []Price
+	var walk func(n *node)
+	walk = func(n *node) {
+		if n == nil {
+			return
+		}
+		if n.Timestamp >= min {
+			walk(n.left)
+		}
+		if min <= n.Timestamp && n.Timestamp <= max {
+			prices = append(prices, n.Price)
+		}
+		if n.Timestamp <= max {
+			walk(n.right)
+		}
+	}
+	walk(s.root)
+	return prices
+}
+
+// Mean returns the mean of the prices with a timestamp between min and max
+// inclusive, rounded with the store's rounding mode, or 0 if there aren't any.
+func (s *Store) Mean(min, max int32) int32 {
+	return s.Summary(min, max).Mean(s.Mode.Rounding)
+}
+
+// Median returns the median of the prices with a timestamp between min and max
+// inclusive, or 0 if there aren't any.  When there's an even number of prices
+// the median is the mean of the middle two, truncated towards zero.
+func (s *Store) Median(min, max int32) int32 {
+	var values []int32
+	for _, p := range s.Prices(min, max) {
+		values = append(values, p.Price)
+	}
+	if len(values) == 0 {
+		return 0
+	}
+
+	slices.Sort(values)
+	mid := len(values) / 2
+	if len(values)%2 == 1 {
+		return values[mid]
+	}
+	return int32((int64(values[mid-1]) + int64(values[mid])) / 2)
+}
+
+// insert adds the node n to the tree rooted at root after any prices with the
+// same timestamp, returning the new root.
+func insert(root, n *node) *node {
+	if root == nil {
+		return n
+	}
+
+	if n.priority > root.priority {
+		n.left, n.right = split(root, n.Timestamp)
+		n.update()
+		return n
+	}
+
+	if n.Timestamp < root.Timestamp {
+		root.left = insert(root.left, n)
+	} else {
+		root.right = insert(root.right, n)
+	}
+	root.update()
+	return root
+}
+
+// split divides the tree rooted at root into the prices with a timestamp at or
+// before t and those after it.
+func split(root *node, t int32) (*node, *node) {
+	if root == nil {
+		return nil, nil
+	}
+
+	if root.Timestamp <= t {
+		left, right := split(root.right, t)
+		root.right = left
+		root.update()
+		return root, right
+	}
+
+	left, right := split(root.left, t)
+	root.left = right
+	root.update()
+	return left, root
+}
+
+// merge joins two trees where every timestamp in left is at or before every
+// timestamp in right, returning the new root.
+func merge(left, right *node) *node {
+	if left == nil {
+		return right
+	}
+	if right == nil {
+		return left
+	}
+
+	if left.priority > right.priority {
+		left.right = merge(left.right, right)
+		left.update()
+		return left
+	}
+
+	right.left = merge(left, right.left)
+	right.update()
+	return right
+}
+
+// summarize adds the prices in the tree rooted at n with a timestamp between
+// min and max inclusive to summary.  aboveMin and belowMax are set when every
+// timestamp in the tree is already known to be within that bound, so once both
+// are set the subtree's own summary can be used.
+func summarize(n *node, min, max int32, aboveMin, belowMax bool, summary *Summary) {
+	switch {
+	case n == nil:
+	case aboveMin && belowMax:
+		summary.merge(n.summary)
+	case n.Timestamp < min:
+		summarize(n.right, min, max, aboveMin, belowMax, summary)
+	case n.Timestamp > max:
+		summarize(n.left, min, max, aboveMin, belowMax, summary)
+	default:
+		summarize(n.left, min, max, aboveMin, true, summary)
+		summary.add(n.Price.Price, n.exact)
+		summarize(n.right, min, max, true, belowMax, summary)
+	}
+}
@@ -0,0 +1,247 @@
+package problem02_test
+
+import (
+	"github.com/bbeck/protohackers/internal/problem02"
+	"math"
+	"math/big"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestStoreMatchesLinearScan(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+
+	var store problem02.Store
+	var prices []problem02.Price
+	for i := 0; i < 2000; i++ {
+		// A narrow range of timestamps gives plenty of duplicates.
+		if r.Intn(10) == 0 {
+			ts := r.Int31n(500) - 250
+			var kept []problem02.Price
+			for _, p := range prices {
+				if p.Timestamp != ts {
+					kept = append(kept, p)
+				}
+			}
+			if n := store.Delete(ts); n != len(prices)-len(kept) {
+				t.Fatalf("Delete(%d) = %d, want %d", ts, n, len(prices)-len(kept))
+			}
+			prices = kept
+		} else {
+			p := problem02.Price{Timestamp: r.Int31n(500) - 250, Price: r.Int31() - math.MaxInt32/2}
+			store.Insert(p)
+			prices = append(prices, p)
+		}
+
+		if store.Len() != len(prices) {
+			t.Fatalf("Len() = %d, want %d", store.Len(), len(prices))
+		}
+
+		lo, hi := r.Int31n(600)-300, r.Int31n(600)-300
+		var values []int32
+		for _, p := range prices {
+			if lo <= p.Timestamp && p.Timestamp <= hi {
+				values = append(values, p.Price)
+			}
+		}
+
+		want := linearSummary(values)
+		summary := store.Summary(lo, hi)
+		if summary.Count != want.Count || summary.Sum != want.Sum || summary.Min != want.Min || summary.Max != want.Max {
+			t.Fatalf("Summary(%d, %d) = %+v, want %+v", lo, hi, summary, want)
+		}
+		if m := store.Mean(lo, hi); m != want.Mean(problem02.Truncate) {
+			t.Fatalf("Mean(%d, %d) = %d, want %d", lo, hi, m, want.Mean(problem02.Truncate))
+		}
+		if m := store.Median(lo, hi); m != linearMedian(values) {
+			t.Fatalf("Median(%d, %d) = %d, want %d", lo, hi, m, linearMedian(values))
+		}
+		if d := summary.StdDev(); d != linearStdDev(values) {
+			t.Fatalf("StdDev(%d, %d) = %d, want %d", lo, hi, d, linearStdDev(values))
+		}
+	}
+}
+
+func linearSummary(values []int32) problem02.Summary {
+	var s problem02.Summary
+	for _, v := range values {
+		if s.Count == 0 || v < s.Min {
+			s.Min = v
+		}
+		if s.Count == 0 || v > s.Max {
+			s.Max = v
+		}
+		s.Count++
+		s.Sum += int64(v)
+	}
+	return s
+}
+
+func linearMedian(values []int32) int32 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	if len(sorted)%2 == 1 {
+		return sorted[len(sorted)/2]
+	}
+	return int32((int64(sorted[len(sorted)/2-1]) + int64(sorted[len(sorted)/2])) / 2)
+}
+
+func linearStdDev(values []int32) int32 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	// The variance is computed exactly from each price's deviation from the mean.
+	n := big.NewRat(int64(len(values)), 1)
+	mean := new(big.Rat)
+	for _, v := range values {
+		mean.Add(mean, big.NewRat(int64(v), 1))
+	}
+	mean.Quo(mean, n)
+
+	variance := new(big.Rat)
+	for _, v := range values {
+		d := new(big.Rat).Sub(big.NewRat(int64(v), 1), mean)
+		variance.Add(variance, d.Mul(d, d))
+	}
+	variance.Quo(variance, n)
+
+	// The largest integer whose square is at most the variance.
+	root := new(big.Int).Quo(variance.Num(), variance.Denom())
+	root.Sqrt(root)
+	return int32(root.Int64())
+}
+
+func TestStoreExtremes(t *testing.T) {
+	var store problem02.Store
+	store.Insert(problem02.Price{Timestamp: math.MinInt32, Price: math.MaxInt32})
+	store.Insert(problem02.Price{Timestamp: math.MaxInt32, Price: math.MaxInt32})
+	store.Insert(problem02.Price{Timestamp: 0, Price: math.MaxInt32})
+
+	// The sum overflows an int32 but the mean doesn't.
+	if m := store.Mean(math.MinInt32, math.MaxInt32); m != math.MaxInt32 {
+		t.Errorf("Mean() = %d, want %d", m, math.MaxInt32)
+	}
+	if m := store.Mean(math.MaxInt32, math.MinInt32); m != 0 {
+		t.Errorf("Mean() with min after max = %d, want 0", m)
+	}
+
+	if n := store.Delete(math.MinInt32); n != 1 {
+		t.Errorf("Delete(MinInt32) = %d, want 1", n)
+	}
+	if n := store.Delete(math.MinInt32); n != 0 {
+		t.Errorf("second Delete(MinInt32) = %d, want 0", n)
+	}
+	if n := store.Len(); n != 2 {
+		t.Errorf("Len() = %d, want 2", n)
+	}
+}
+
+func TestStoreDuplicatePolicies(t *testing.T) {
+	tests := []struct {
+		duplicates string
+		inserted   bool
+		count      int
+		mean       int32
+	}{
+		{"", true, 2, 15},
+		{problem02.KeepAll, true, 2, 15},
+		{problem02.Reject, false, 1, 10},
+		{problem02.Overwrite, true, 1, 20},
+	}
+
+	for _, test := range tests {
+		t.Run(test.duplicates, func(t *testing.T) {
+			store := problem02.Store{Mode: problem02.Mode{Duplicates: test.duplicates}}
+			store.Insert(problem02.Price{Timestamp: 1, Price: 10})
+			store.Insert(problem02.Price{Timestamp: 2, Price: 99})
+
+			if inserted := store.Insert(problem02.Price{Timestamp: 1, Price: 20}); inserted != test.inserted {
+				t.Errorf("Insert() = %v, want %v", inserted, test.inserted)
+			}
+			if count := store.Summary(1, 1).Count; count != test.count {
+				t.Errorf("count = %d, want %d", count, test.count)
+			}
+			if mean := store.Mean(1, 1); mean != test.mean {
+				t.Errorf("Mean() = %d, want %d", mean, test.mean)
+			}
+		})
+	}
+}
+
+func TestStoreRounding(t *testing.T) {
+	tests := []struct {
+		prices   []int32
+		truncate int32
+		halfEven int32
+	}{
+		{[]int32{1, 2}, 1, 2},
+		{[]int32{2, 3}, 2, 2},
+		{[]int32{-1, -2}, -1, -2},
+		{[]int32{-2, -3}, -2, -2},
+		{[]int32{1, 1, 2}, 1, 1},
+		{[]int32{1, 2, 2}, 1, 2},
+		{[]int32{-1, -2, -2}, -1, -2},
+		{[]int32{math.MaxInt32, math.MaxInt32 - 1}, math.MaxInt32 - 1, math.MaxInt32 - 1},
+	}
+
+	for _, accumulation := range []string{problem02.Int64, problem02.BigInt} {
+		for _, test := range tests {
+			for rounding, want := range map[string]int32{problem02.Truncate: test.truncate, problem02.HalfEven: test.halfEven} {
+				store := problem02.Store{Mode: problem02.Mode{Accumulation: accumulation, Rounding: rounding}}
+				for i, price := range test.prices {
+					store.Insert(problem02.Price{Timestamp: int32(i), Price: price})
+				}
+
+				if mean := store.Mean(math.MinInt32, math.MaxInt32); mean != want {
+					t.Errorf("%s %s mean of %v = %d, want %d", accumulation, rounding, test.prices, mean, want)
+				}
+			}
+		}
+	}
+}
+
+func TestStoreBigIntAccumulation(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+
+	store := problem02.Store{Mode: problem02.Mode{Accumulation: problem02.BigInt}}
+	for i := 0; i < 500; i++ {
+		store.Insert(problem02.Price{Timestamp: r.Int31n(100), Price: r.Int31() - math.MaxInt32/2})
+
+		lo, hi := r.Int31n(100), r.Int31n(100)
+		summary := store.Summary(lo, hi)
+		if summary.Count == 0 {
+			continue
+		}
+		if summary.BigSum == nil || summary.BigSum.Int64() != summary.Sum {
+			t.Fatalf("Summary(%d, %d) BigSum = %v, want %d", lo, hi, summary.BigSum, summary.Sum)
+		}
+	}
+}
+
+func TestModeValidate(t *testing.T) {
+	valid := []problem02.Mode{
+		{},
+		{Duplicates: problem02.Reject, Accumulation: problem02.BigInt, Rounding: problem02.HalfEven},
+	}
+	for _, mode := range valid {
+		if err := mode.Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v, want nil", mode, err)
+		}
+	}
+
+	invalid := []problem02.Mode{
+		{Duplicates: "ignore"},
+		{Accumulation: "float"},
+		{Rounding: "up"},
+	}
+	for _, mode := range invalid {
+		if err := mode.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", mode)
+		}
+	}
+}
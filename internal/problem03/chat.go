@@ -0,0 +1,258 @@
+package problem03
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultRoom is the room users are in when they first join.  It exists even
+// when it's empty, unlike every other room.
+const DefaultRoom = "general"
+
+// Command is a line from a user that starts with a slash, such as
+// "/msg bob hello".  Args is the rest of the line after the command's name.
+type Command struct {
+	Name string
+	Args string
+}
+
+// ParseLine splits a line from a user into either a command or a message.  A
+// line that starts with two slashes is a message that starts with one.
+func ParseLine(line string) (*Command, string) {
+	if strings.HasPrefix(line, "//") {
+		return nil, line[1:]
+	}
+	if !strings.HasPrefix(line, "/") {
+		return nil, line
+	}
+
+	name, args, _ := strings.Cut(line[1:], " ")
+	return &Command{Name: name, Args: strings.TrimSpace(args)}, ""
+}
+
+// User is a user connected to a chat.
+type User struct {
+	Name string
+	conn net.Conn
+	room *Room
+}
+
+// Chat is a set of named rooms along with the users connected to it, who are
+// each in at most one room at a time.  Users are identified by their names,
+// which are unique.  The zero value for Chat is an empty chat.
+//
+// The chat's mutex only guards which users exist and which room each is in.
+// Lines are sent while holding just the lock of the room they're sent in, so a
+// slow client only holds up the members of its own room.
+type Chat struct {
+	mutex sync.Mutex
+	rooms map[string]*Room
+	users map[string]*User
+}
+
+// Login connects a user to the chat and puts them in DefaultRoom.  It returns
+// nil if the name is already in use.
+func (c *Chat) Login(name string, conn net.Conn) *User {
+	c.mutex.Lock()
+	if c.users == nil {
+		c.users = make(map[string]*User)
+	}
+	if _, found := c.users[name]; found {
+		c.mutex.Unlock()
+		return nil
+	}
+
+	user := &User{Name: name, conn: conn}
+	c.users[name] = user
+	room := c.enter(user, DefaultRoom)
+	c.mutex.Unlock()
+
+	room.Join(name, conn)
+	return user
+}
+
+// Logout removes a user from their room and disconnects them from the chat.
+func (c *Chat) Logout(user *User) {
+	c.mutex.Lock()
+	room := c.leave(user)
+	delete(c.users, user.Name)
+	c.mutex.Unlock()
+
+	if room != nil {
+		room.Part(user.Name)
+	}
+}
+
+// Send sends a message from a user to the other members of their room.
+func (c *Chat) Send(user *User, message string) {
+	c.mutex.Lock()
+	room := user.room
+	c.mutex.Unlock()
+
+	if room == nil {
+		reply(user.conn, "* You are not in a room")
+		return
+	}
+	room.Send(user.Name, message)
+}
+
+// Run runs a command from a user, replying to the user with a line starting
+// with "* " if the command fails or has something to report.
+func (c *Chat) Run(user *User, command Command) {
+	switch command.Name {
+	case "join":
+		name := command.Args
+		if !IsValidName(name) {
+			reply(user.conn, "* Invalid room name: %s", name)
+			return
+		}
+
+		c.mutex.Lock()
+		if user.room != nil && user.room.Name == name {
+			c.mutex.Unlock()
+			reply(user.conn, "* You are already in %s", name)
+			return
+		}
+		from := c.leave(user)
+		to := c.enter(user, name)
+		c.mutex.Unlock()
+
+		if from != nil {
+			from.Part(user.Name)
+		}
+		to.Join(user.Name, user.conn)
+
+	case "part":
+		c.mutex.Lock()
+		room := c.leave(user)
+		c.mutex.Unlock()
+
+		if room == nil {
+			reply(user.conn, "* You are not in a room")
+			return
+		}
+		room.Part(user.Name)
+		reply(user.conn, "* You left %s", room.Name)
+
+	case "rooms":
+		c.mutex.Lock()
+		var rooms []string
+		for name, room := range c.rooms {
+			rooms = append(rooms, fmt.Sprintf("%s (%d)", name, c.size(room)))
+		}
+		c.mutex.Unlock()
+
+		sort.Strings(rooms)
+		reply(user.conn, "* Rooms: %s", strings.Join(rooms, ", "))
+
+	case "who":
+		c.mutex.Lock()
+		room := user.room
+		c.mutex.Unlock()
+
+		if room == nil {
+			reply(user.conn, "* You are not in a room")
+			return
+		}
+		room.List(user.Name, user.conn)
+
+	case "msg":
+		name, message, _ := strings.Cut(command.Args, " ")
+		if name == "" || message == "" {
+			reply(user.conn, "* Usage: /msg NAME MESSAGE")
+			return
+		}
+
+		c.mutex.Lock()
+		target, found := c.users[name]
+		c.mutex.Unlock()
+
+		if !found {
+			reply(user.conn, "* No such user: %s", name)
+			return
+		}
+		reply(target.conn, "[%s -> %s] %s", user.Name, name, message)
+
+	case "nick":
+		name := command.Args
+		if !IsValidName(name) {
+			reply(user.conn, "* Invalid name: %s", name)
+			return
+		}
+
+		c.mutex.Lock()
+		if _, found := c.users[name]; found {
+			c.mutex.Unlock()
+			reply(user.conn, "* Name taken: %s", name)
+			return
+		}
+		previous := user.Name
+		delete(c.users, previous)
+		c.users[name] = user
+		user.Name = name
+		room := user.room
+		c.mutex.Unlock()
+
+		if room != nil {
+			room.Rename(previous, name)
+		}
+		reply(user.conn, "* You are now known as %s", name)
+
+	default:
+		reply(user.conn, "* Unknown command: /%s", command.Name)
+	}
+}
+
+// enter puts a user who isn't in a room into the named room, creating it if
+// necessary, and returns the room.  The caller joins the room once c.mutex is
+// released.  c.mutex must be held.
+func (c *Chat) enter(user *User, name string) *Room {
+	if c.rooms == nil {
+		c.rooms = map[string]*Room{DefaultRoom: {Name: DefaultRoom}}
+	}
+
+	room, found := c.rooms[name]
+	if !found {
+		room = &Room{Name: name}
+		c.rooms[name] = room
+	}
+
+	user.room = room
+	return room
+}
+
+// leave takes a user out of their room, removing the room if it's now empty,
+// and returns the room or nil if the user wasn't in one.  The caller parts the
+// room once c.mutex is released.  c.mutex must be held.
+func (c *Chat) leave(user *User) *Room {
+	room := user.room
+	if room == nil {
+		return nil
+	}
+
+	user.room = nil
+	if room.Name != DefaultRoom && c.size(room) == 0 {
+		delete(c.rooms, room.Name)
+	}
+	return room
+}
+
+// size returns the number of users in a room.  c.mutex must be held.
+func (c *Chat) size(room *Room) int {
+	var n int
+	for _, user := range c.users {
+		if user.room == room {
+			n++
+		}
+	}
+	return n
+}
+
+// reply sends a line to a user.
+func reply(conn net.Conn, format string, args ...any) {
+	io.WriteString(conn, fmt.Sprintf(format, args...)+"\n")
+}
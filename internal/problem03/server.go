@@ -0,0 +1,150 @@
+package problem03
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/bbeck/protohackers/internal"
+	"io"
+	"net"
+	"sync"
+)
+
+// Problem is the Budget Chat problem.
+var Problem = internal.Problem{ID: 3, Name: "Budget Chat", Run: Run}
+
+// Run runs the Budget Chat server until ctx is cancelled.
+//
+// Users start in DefaultRoom, and lines from them that start with a slash are
+// commands rather than messages, see Chat.Run.  A user whose name is already
+// in use is disconnected.
+func Run(ctx context.Context, server *internal.Server) error {
+	var chat Chat
+
+	return server.ServeTCP(ctx, func(conn net.Conn) {
+		defer conn.Close()
+		logger := internal.Logger(conn)
+
+		scanner := bufio.NewScanner(conn)
+
+		// Read name
+		io.WriteString(conn, "Name:\n")
+		if !scanner.Scan() {
+			return
+		}
+		name := scanner.Text()
+		if !IsValidName(name) {
+			logger.Debug("invalid name", "name", name)
+			return
+		}
+
+		// Join the chat
+		user := chat.Login(name, conn)
+		if user == nil {
+			logger.Debug("name in use", "name", name)
+			return
+		}
+		logger.Debug("user joined", "name", name)
+		defer func() {
+			chat.Logout(user)
+			logger.Debug("user left", "name", user.Name)
+		}()
+
+		// Now that the user is connected keep sending their messages until
+		// they disconnect
+		for scanner.Scan() {
+			command, message := ParseLine(scanner.Text())
+			if command != nil {
+				chat.Run(user, *command)
+				logger.Debug("command run", "name", user.Name, "command", command.Name)
+				continue
+			}
+
+			chat.Send(user, message)
+			logger.Debug("message sent", "name", user.Name)
+		}
+	})
+}
+
+func IsValidName(name string) bool {
+	for _, r := range name {
+		isValid := ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9')
+		if !isValid {
+			return false
+		}
+	}
+	return len(name) > 0
+}
+
+type Room struct {
+	sync.Mutex
+	Name    string
+	Members map[string]net.Conn
+}
+
+func (r *Room) Join(name string, conn net.Conn) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.send(name, fmt.Sprintf("* %s joined\n", name))
+	r.list(name, conn)
+
+	if r.Members == nil {
+		r.Members = make(map[string]net.Conn)
+	}
+	r.Members[name] = conn
+}
+
+func (r *Room) Part(name string) {
+	r.Lock()
+	defer r.Unlock()
+
+	delete(r.Members, name)
+	r.send(name, fmt.Sprintf("* %s left\n", name))
+}
+
+func (r *Room) Send(name, message string) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.send(name, fmt.Sprintf("[%s] %s\n", name, message))
+}
+
+// Rename changes the name of a member, telling the other members.
+func (r *Room) Rename(name, newName string) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.Members[newName] = r.Members[name]
+	delete(r.Members, name)
+	r.send(newName, fmt.Sprintf("* %s is now known as %s\n", name, newName))
+}
+
+// List sends the members other than the named one to conn, in the same format
+// as Join.
+func (r *Room) List(name string, conn net.Conn) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.list(name, conn)
+}
+
+func (r *Room) list(name string, conn net.Conn) {
+	members := make(map[string]net.Conn)
+	for m, c := range r.Members {
+		if m != name {
+			members[m] = c
+		}
+	}
+	io.WriteString(conn, fmt.Sprintf("* members: %v\n", members))
+}
+
+func (r *Room) send(name, msg string) {
+	for m, conn := range r.Members {
+		if m == name {
+			continue
+		}
+
+		io.WriteString(conn, msg)
+	}
+}
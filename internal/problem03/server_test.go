@@ -0,0 +1,259 @@
+package problem03_test
+
+import (
+	"github.com/bbeck/protohackers/internal/problem03"
+	"github.com/bbeck/protohackers/internal/servertest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func join(t *testing.T, addr, name string, members ...string) *servertest.Conn {
+	t.Helper()
+
+	conn := servertest.Dial(t, "tcp", addr)
+	conn.ExpectString("Name:\n")
+	conn.SendString(name + "\n")
+	conn.ExpectLine(memberList(members...))
+	return conn
+}
+
+// memberList matches the line listing the other members of a room that a user
+// receives when they join it or run /who, which includes each member's
+// connection.
+func memberList(names ...string) *regexp.Regexp {
+	var members []string
+	for _, name := range names {
+		members = append(members, name+":0x[0-9a-f]+")
+	}
+	return regexp.MustCompile(`^\* members: map\[` + strings.Join(members, " ") + `\]$`)
+}
+
+func TestJoinAndPart(t *testing.T) {
+	addr := servertest.Start(t, problem03.Problem)
+
+	bob := join(t, addr, "bob")
+	alice := join(t, addr, "alice", "bob")
+	bob.ExpectString("* alice joined\n")
+
+	carol := join(t, addr, "carol", "alice", "bob")
+	alice.ExpectString("* carol joined\n")
+	bob.ExpectString("* carol joined\n")
+
+	_ = alice.Close()
+	bob.ExpectString("* alice left\n")
+	carol.ExpectString("* alice left\n")
+
+	dave := join(t, addr, "dave", "bob", "carol")
+	bob.ExpectString("* dave joined\n")
+	carol.ExpectString("* dave joined\n")
+	dave.ExpectNothing(50 * time.Millisecond)
+}
+
+func TestMessages(t *testing.T) {
+	addr := servertest.Start(t, problem03.Problem)
+
+	alice := join(t, addr, "alice")
+	bob := join(t, addr, "bob", "alice")
+	alice.ExpectString("* bob joined\n")
+
+	alice.SendString("Hi bob!\n")
+	bob.ExpectString("[alice] Hi bob!\n")
+
+	bob.SendString("Hello alice\nHow are you?\n")
+	alice.ExpectString("[bob] Hello alice\n[bob] How are you?\n")
+
+	// Nobody receives their own messages.
+	alice.ExpectNothing(50 * time.Millisecond)
+	bob.ExpectNothing(50 * time.Millisecond)
+}
+
+func TestInvalidNames(t *testing.T) {
+	addr := servertest.Start(t, problem03.Problem)
+	alice := join(t, addr, "alice")
+
+	for _, name := range []string{"", "bob smith", "bob!", "böb"} {
+		conn := servertest.Dial(t, "tcp", addr)
+		conn.ExpectString("Name:\n")
+		conn.SendString(name + "\n")
+		conn.ExpectClosed()
+	}
+
+	// A client that disconnects before choosing a name never joined.
+	conn := servertest.Dial(t, "tcp", addr)
+	conn.ExpectString("Name:\n")
+	_ = conn.Close()
+
+	alice.ExpectNothing(50 * time.Millisecond)
+}
+
+func TestDuplicateNames(t *testing.T) {
+	addr := servertest.Start(t, problem03.Problem)
+	alice := join(t, addr, "alice")
+
+	conn := servertest.Dial(t, "tcp", addr)
+	conn.ExpectString("Name:\n")
+	conn.SendString("alice\n")
+	conn.ExpectClosed()
+	alice.ExpectNothing(50 * time.Millisecond)
+}
+
+func TestRooms(t *testing.T) {
+	addr := servertest.Start(t, problem03.Problem)
+	alice := join(t, addr, "alice")
+	bob := join(t, addr, "bob", "alice")
+	alice.ExpectString("* bob joined\n")
+	carol := join(t, addr, "carol", "alice", "bob")
+	alice.ExpectString("* carol joined\n")
+	bob.ExpectString("* carol joined\n")
+
+	// Joining a room leaves the current one.
+	alice.SendString("/join games\n")
+	bob.ExpectString("* alice left\n")
+	carol.ExpectString("* alice left\n")
+	alice.ExpectLine(memberList())
+
+	bob.SendString("/join games\n")
+	carol.ExpectString("* bob left\n")
+	bob.ExpectLine(memberList("alice"))
+	alice.ExpectString("* bob joined\n")
+
+	// Messages only reach the room they're sent in.
+	alice.SendString("anyone for chess?\n")
+	bob.ExpectString("[alice] anyone for chess?\n")
+	carol.ExpectNothing(50 * time.Millisecond)
+
+	carol.SendString("/rooms\n")
+	carol.ExpectString("* Rooms: games (2), general (1)\n")
+	bob.SendString("/who\n")
+	bob.ExpectLine(memberList("alice"))
+	bob.SendString("/join games\n")
+	bob.ExpectString("* You are already in games\n")
+	bob.SendString("/join no way\n")
+	bob.ExpectString("* Invalid room name: no way\n")
+
+	// Leaving a room puts a user in no room at all, and empty rooms go away.
+	alice.SendString("/part\n")
+	bob.ExpectString("* alice left\n")
+	alice.ExpectString("* You left games\n")
+	alice.SendString("hello?\n")
+	alice.ExpectString("* You are not in a room\n")
+	alice.SendString("/who\n")
+	alice.ExpectString("* You are not in a room\n")
+
+	bob.SendString("/part\n")
+	bob.ExpectString("* You left games\n")
+	bob.SendString("/rooms\n")
+	bob.ExpectString("* Rooms: general (1)\n")
+}
+
+func TestPrivateMessages(t *testing.T) {
+	addr := servertest.Start(t, problem03.Problem)
+	alice := join(t, addr, "alice")
+	bob := join(t, addr, "bob", "alice")
+	alice.ExpectString("* bob joined\n")
+	carol := join(t, addr, "carol", "alice", "bob")
+	alice.ExpectString("* carol joined\n")
+	bob.ExpectString("* carol joined\n")
+
+	// Private messages reach users in other rooms, and nobody else.
+	carol.SendString("/join quiet\n")
+	carol.ExpectLine(memberList())
+	alice.ExpectString("* carol left\n")
+	bob.ExpectString("* carol left\n")
+
+	alice.SendString("/msg carol psst, over here\n")
+	carol.ExpectString("[alice -> carol] psst, over here\n")
+	bob.ExpectNothing(50 * time.Millisecond)
+
+	alice.SendString("/msg dave hi\n")
+	alice.ExpectString("* No such user: dave\n")
+	alice.SendString("/msg carol\n")
+	alice.ExpectString("* Usage: /msg NAME MESSAGE\n")
+}
+
+func TestNick(t *testing.T) {
+	addr := servertest.Start(t, problem03.Problem)
+	alice := join(t, addr, "alice")
+	bob := join(t, addr, "bob", "alice")
+	alice.ExpectString("* bob joined\n")
+
+	alice.SendString("/nick bob\n")
+	alice.ExpectString("* Name taken: bob\n")
+	alice.SendString("/nick al ice\n")
+	alice.ExpectString("* Invalid name: al ice\n")
+
+	alice.SendString("/nick al\n")
+	alice.ExpectString("* You are now known as al\n")
+	bob.ExpectString("* alice is now known as al\n")
+
+	alice.SendString("hi\n")
+	bob.ExpectString("[al] hi\n")
+	bob.SendString("/msg al hey\n")
+	alice.ExpectString("[bob -> al] hey\n")
+
+	// The old name is free again.
+	conn := join(t, addr, "alice", "al", "bob")
+	_ = conn.Close()
+	alice.ExpectString("* alice joined\n* alice left\n")
+	bob.ExpectString("* alice joined\n* alice left\n")
+
+	_ = alice.Close()
+	bob.ExpectString("* al left\n")
+}
+
+func TestCommandsAndSlashes(t *testing.T) {
+	addr := servertest.Start(t, problem03.Problem)
+	alice := join(t, addr, "alice")
+	bob := join(t, addr, "bob", "alice")
+	alice.ExpectString("* bob joined\n")
+
+	alice.SendString("/dance\n")
+	alice.ExpectString("* Unknown command: /dance\n")
+
+	// A doubled slash sends a message that starts with a slash.
+	alice.SendString("//dance\n")
+	bob.ExpectString("[alice] /dance\n")
+	alice.ExpectNothing(50 * time.Millisecond)
+}
+
+func TestSlowClientOnlyStallsItsRoom(t *testing.T) {
+	addr := servertest.Start(t, problem03.Problem)
+	alice := join(t, addr, "alice")
+	bob := join(t, addr, "bob", "alice")
+	alice.ExpectString("* bob joined\n")
+	carol := join(t, addr, "carol", "alice", "bob")
+	alice.ExpectString("* carol joined\n")
+	bob.ExpectString("* carol joined\n")
+
+	carol.SendString("/join slow\n")
+	carol.ExpectLine(memberList())
+	alice.ExpectString("* carol left\n")
+	bob.ExpectString("* carol left\n")
+
+	// Carol stops reading, so once the connection's buffers fill up every line
+	// sent to her blocks.
+	dave := join(t, addr, "dave", "alice", "bob")
+	alice.ExpectString("* dave joined\n")
+	bob.ExpectString("* dave joined\n")
+	dave.SendString("/join slow\n")
+	dave.ExpectLine(memberList("carol"))
+	alice.ExpectString("* dave left\n")
+	bob.ExpectString("* dave left\n")
+
+	go func() {
+		line := []byte(strings.Repeat("x", 1023) + "\n")
+		for i := 0; i < 64*1024; i++ {
+			if _, err := dave.Write(line); err != nil {
+				return
+			}
+		}
+	}()
+	time.Sleep(500 * time.Millisecond)
+
+	alice.SendString("still here?\n")
+	bob.ExpectString("[alice] still here?\n")
+	bob.SendString("/rooms\n")
+	bob.ExpectString("* Rooms: general (2), slow (2)\n")
+}
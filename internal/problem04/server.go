@@ -0,0 +1,42 @@
+package problem04
+
+import (
+	"context"
+	"fmt"
+	"github.com/bbeck/protohackers/internal"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Problem is the Unusual Database Program problem.
+var Problem = internal.Problem{ID: 4, Name: "Unusual Database Program", Run: Run}
+
+// Run runs the Unusual Database Program server until ctx is cancelled.
+func Run(ctx context.Context, server *internal.Server) error {
+	// Datagrams are handled concurrently, so access to the database must be
+	// synchronized.
+	var mutex sync.Mutex
+	db := map[string]string{
+		"version": "alpha",
+	}
+
+	return server.ServeUDP(ctx, func(addr net.Addr, bs []byte, send func([]byte) error) {
+		s := string(bs)
+		logger := server.Log().With("remote_addr", addr.String())
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if key, value, found := strings.Cut(s, "="); found {
+			if key != "version" {
+				db[key] = value
+				logger.Debug("value inserted", "key", key, "value", value)
+			}
+			return
+		}
+
+		logger.Debug("value retrieved", "key", s, "value", db[s])
+		_ = send([]byte(fmt.Sprintf("%s=%s", s, db[s])))
+	})
+}
@@ -0,0 +1,52 @@
+package problem04_test
+
+import (
+	"github.com/bbeck/protohackers/internal"
+	"github.com/bbeck/protohackers/internal/problem04"
+	"github.com/bbeck/protohackers/internal/servertest"
+	"testing"
+	"time"
+)
+
+// serial makes the server handle datagrams one at a time, otherwise a retrieve
+// may be handled before the insert that was sent just before it.
+func serial(config *internal.Config) {
+	config.UDPWorkers = 1
+}
+
+func TestInsertAndRetrieve(t *testing.T) {
+	addr := servertest.Start(t, problem04.Problem, serial)
+	conn := servertest.Dial(t, "udp", addr)
+
+	tests := []struct {
+		name     string
+		requests []string
+		response string
+	}{
+		{"missing key", []string{"missing"}, "missing="},
+		{"insert", []string{"foo=bar", "foo"}, "foo=bar"},
+		{"overwrite", []string{"foo=baz", "foo"}, "foo=baz"},
+		{"value with equals", []string{"foo==bar=baz=", "foo"}, "foo==bar=baz="},
+		{"empty key", []string{"=empty", ""}, "=empty"},
+		{"empty value", []string{"blank=", "blank"}, "blank="},
+		{"version", []string{"version"}, "version=alpha"},
+		{"version is read-only", []string{"version=beta", "version"}, "version=alpha"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, request := range test.requests {
+				conn.SendString(request)
+			}
+			conn.ExpectDatagram(test.response)
+		})
+	}
+}
+
+func TestInsertHasNoResponse(t *testing.T) {
+	addr := servertest.Start(t, problem04.Problem, serial)
+	conn := servertest.Dial(t, "udp", addr)
+
+	conn.SendString("key=value")
+	conn.ExpectNothing(50 * time.Millisecond)
+}
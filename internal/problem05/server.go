@@ -0,0 +1,79 @@
+package problem05
+
+import (
+	"bufio"
+	"context"
+	"github.com/bbeck/protohackers/internal"
+	"io"
+	"log/slog"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// UpstreamAddress is the address of the chat server that clients are proxied
+// to.
+var UpstreamAddress = "chat.protohackers.com:16963"
+
+const TonyAddress = "7YWHMfk9JZe0LM0g1ZauHuiSxhI"
+
+// Problem is the Mob in the Middle problem.
+var Problem = internal.Problem{ID: 5, Name: "Mob in the Middle", Run: Run}
+
+// Run runs the Mob in the Middle server until ctx is cancelled.
+func Run(ctx context.Context, server *internal.Server) error {
+	return server.ServeTCP(ctx, func(conn net.Conn) {
+		defer conn.Close()
+		logger := internal.Logger(conn)
+
+		upstream, err := net.Dial("tcp", UpstreamAddress)
+		if err != nil {
+			logger.Error("error dialing upstream", "addr", UpstreamAddress, "error", err)
+			return
+		}
+		defer upstream.Close()
+		logger.Debug("upstream connected", "addr", UpstreamAddress)
+
+		toConn, toUpstream := make(chan string, 10), make(chan string, 10)
+		go Transform(bufio.NewReader(conn), toUpstream, logger.With("direction", "to_upstream"))
+		go Transform(bufio.NewReader(upstream), toConn, logger.With("direction", "to_client"))
+
+		for {
+			select {
+			case msg, ok := <-toConn:
+				if !ok {
+					return
+				}
+				io.WriteString(conn, msg)
+
+			case msg, ok := <-toUpstream:
+				if !ok {
+					return
+				}
+				io.WriteString(upstream, msg)
+			}
+		}
+	})
+}
+
+var Regex = regexp.MustCompile(`^7[0-9a-zA-Z]{25,34}$`)
+
+func Transform(in *bufio.Reader, ch chan string, logger *slog.Logger) {
+	defer close(ch)
+
+	for {
+		msg, err := in.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		for _, word := range strings.Fields(msg) {
+			if Regex.MatchString(word) {
+				msg = strings.ReplaceAll(msg, word, TonyAddress)
+				logger.Debug("address rewritten", "address", word)
+			}
+		}
+
+		ch <- msg
+	}
+}
@@ -0,0 +1,114 @@
+package problem05_test
+
+import (
+	"github.com/bbeck/protohackers/internal/problem03"
+	"github.com/bbeck/protohackers/internal/problem05"
+	"github.com/bbeck/protohackers/internal/servertest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// start runs the proxy in front of an in-process Budget Chat server, and
+// returns the addresses of the proxy and the chat server.
+func start(t *testing.T) (string, string) {
+	upstream := servertest.Start(t, problem03.Problem)
+
+	previous := problem05.UpstreamAddress
+	problem05.UpstreamAddress = upstream
+	t.Cleanup(func() { problem05.UpstreamAddress = previous })
+
+	return servertest.Start(t, problem05.Problem), upstream
+}
+
+func join(t *testing.T, addr, name string, members ...string) *servertest.Conn {
+	t.Helper()
+
+	conn := servertest.Dial(t, "tcp", addr)
+	conn.ExpectString("Name:\n")
+	conn.SendString(name + "\n")
+
+	// The chat lists each member along with their connection.
+	var pattern []string
+	for _, member := range members {
+		pattern = append(pattern, member+":0x[0-9a-f]+")
+	}
+	conn.ExpectLine(regexp.MustCompile(`^\* members: map\[` + strings.Join(pattern, " ") + `\]$`))
+	return conn
+}
+
+func TestRewritesAddresses(t *testing.T) {
+	proxy, upstream := start(t)
+
+	bob := join(t, upstream, "bob")
+	alice := join(t, proxy, "alice", "bob")
+	bob.ExpectString("* alice joined\n")
+
+	tests := []struct {
+		name, message, rewritten string
+	}{
+		{
+			"whole message",
+			"7F1u3wSD5RbOHQmupo9nx4TnhQ",
+			"7YWHMfk9JZe0LM0g1ZauHuiSxhI",
+		},
+		{
+			"start of message",
+			"7iKDZEwPZSqIvDnHvVN2r0hUWXD5rHX send here",
+			"7YWHMfk9JZe0LM0g1ZauHuiSxhI send here",
+		},
+		{
+			"end of message",
+			"Please pay 7LOrwbDlS8NujgjddyogWgIM93MV5N2VR",
+			"Please pay 7YWHMfk9JZe0LM0g1ZauHuiSxhI",
+		},
+		{
+			"several addresses",
+			"7adNeSwJkMakpEcln9HEtthSRtxdmEHOT8T or 7F1u3wSD5RbOHQmupo9nx4TnhQ",
+			"7YWHMfk9JZe0LM0g1ZauHuiSxhI or 7YWHMfk9JZe0LM0g1ZauHuiSxhI",
+		},
+		{
+			"too short",
+			"7F1u3wSD5RbOHQmupo9nx4Tnh",
+			"7F1u3wSD5RbOHQmupo9nx4Tnh",
+		},
+		{
+			"too long",
+			"7F1u3wSD5RbOHQmupo9nx4TnhQ7F1u3wSD5RbO",
+			"7F1u3wSD5RbOHQmupo9nx4TnhQ7F1u3wSD5RbO",
+		},
+		{
+			"not starting with 7",
+			"8F1u3wSD5RbOHQmupo9nx4TnhQ",
+			"8F1u3wSD5RbOHQmupo9nx4TnhQ",
+		},
+		{
+			"part of a word",
+			"This is a product ID, not a Boguscoin: 7F1u3wSD5RbOHQmupo9nx4TnhQ-1234",
+			"This is a product ID, not a Boguscoin: 7F1u3wSD5RbOHQmupo9nx4TnhQ-1234",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Messages from the client to the server are rewritten...
+			alice.SendString(test.message + "\n")
+			bob.ExpectString("[alice] " + test.rewritten + "\n")
+
+			// ...and so are messages from the server to the client.
+			bob.SendString(test.message + "\n")
+			alice.ExpectString("[bob] " + test.rewritten + "\n")
+		})
+	}
+}
+
+func TestDisconnectsFromUpstream(t *testing.T) {
+	proxy, upstream := start(t)
+
+	bob := join(t, upstream, "bob")
+	alice := join(t, proxy, "alice", "bob")
+	bob.ExpectString("* alice joined\n")
+
+	_ = alice.Close()
+	bob.ExpectString("* alice left\n")
+}
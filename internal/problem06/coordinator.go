@@ -1,12 +1,20 @@
-package main
+package problem06
 
 import (
+	"github.com/bbeck/protohackers/internal"
+	"log/slog"
 	"math"
 	"sync"
 )
 
+var (
+	TicketsIssued = internal.NewCounter("protohackers_speed_tickets_issued_total", "Number of speeding tickets issued.")
+	TicketsQueued = internal.NewGauge("protohackers_speed_tickets_queued", "Number of tickets waiting for a dispatcher for their road.")
+)
+
 type Coordinator struct {
 	sync.Mutex
+	Logger             *slog.Logger
 	Clients            map[int]*Client
 	Observations       map[string][]Observation
 	Limits             map[Road]uint16
@@ -26,8 +34,10 @@ func (c *Coordinator) AddClient(client *Client) {
 	if client.IsDispatcher {
 		for _, road := range client.Roads {
 			for _, ticket := range c.TicketsToSendLater[road] {
+				c.Logger.Debug("ticket delivered", "plate", ticket.Plate, "road", ticket.Road, "dispatcher", client.ID)
 				client.WriteTicket(ticket)
 			}
+			TicketsQueued.Add(-len(c.TicketsToSendLater[road]))
 			delete(c.TicketsToSendLater, road)
 		}
 	}
@@ -89,6 +99,7 @@ func (c *Coordinator) SendTicket(t Ticket) {
 	contains1 := Contains(c.SentTickets[t.Plate], day1)
 	contains2 := Contains(c.SentTickets[t.Plate], day2)
 	if contains1 || contains2 {
+		c.Logger.Debug("ticket suppressed", "plate", t.Plate, "road", t.Road, "day1", day1, "day2", day2)
 		return
 	}
 
@@ -108,12 +119,18 @@ func (c *Coordinator) SendTicket(t Ticket) {
 		}
 	}
 
+	c.Logger.Debug("ticket issued", "plate", t.Plate, "road", t.Road, "speed", t.Speed)
+	TicketsIssued.Inc()
+
 	if dispatcher == nil {
+		c.Logger.Debug("ticket queued", "plate", t.Plate, "road", t.Road)
 		c.TicketsToSendLater[t.Road] = append(c.TicketsToSendLater[t.Road], t)
+		TicketsQueued.Inc()
 		return
 	}
 
 	// Send this ticket now
+	c.Logger.Debug("ticket delivered", "plate", t.Plate, "road", t.Road, "dispatcher", dispatcher.ID)
 	dispatcher.WriteTicket(t)
 }
 
@@ -1,4 +1,4 @@
-package main
+package problem06
 
 type Integer interface {
 	~int | ~int8 | ~int16 | ~int32 | ~int64 |
@@ -0,0 +1,253 @@
+package problem06
+
+import (
+	"context"
+	"encoding/binary"
+	"github.com/bbeck/protohackers/internal"
+	"net"
+	"sync"
+	"time"
+)
+
+// Problem is the Speed Daemon problem.
+var Problem = internal.Problem{ID: 6, Name: "Speed Daemon", Run: Run}
+
+// Run runs the Speed Daemon server until ctx is cancelled.
+func Run(ctx context.Context, server *internal.Server) error {
+	coordinator := Coordinator{
+		Logger:             server.Log(),
+		Clients:            make(map[int]*Client),
+		Observations:       make(map[string][]Observation),
+		Limits:             make(map[Road]uint16),
+		SentTickets:        make(map[string][]uint32),
+		TicketsToSendLater: make(map[Road][]Ticket),
+	}
+	go SendHeartbeats(ctx, &coordinator)
+
+	return server.ServeTCP(ctx, func(conn net.Conn) {
+		client := &Client{ID: GetNextID(), Connection: conn}
+		logger := internal.Logger(conn)
+
+		defer func() {
+			coordinator.Remove(client)
+			conn.Close()
+		}()
+
+		for client.Err() == nil {
+			switch client.Read8() {
+			case 0x20: // Plate
+				if client.IsDispatcher {
+					logger.Debug("illegal message", "message", "Plate")
+					client.WriteError("illegal plate message from dispatcher")
+					return
+				}
+
+				plate := client.ReadString()
+				tm := client.Read32()
+				if client.Err() != nil {
+					return
+				}
+				logger.Debug("plate observed", "plate", plate, "timestamp", tm, "road", client.Road, "mile", client.Mile)
+				coordinator.AddPlate(plate, tm, client.Road, client.Mile)
+
+			case 0x40: // WantHeartbeat
+				// The client may already be known to the coordinator, which reads
+				// these fields while holding its lock.
+				interval := client.Read32()
+				coordinator.Lock()
+				client.HeartbeatInterval = interval
+				client.HeartbeatCounter = interval
+				client.WantsHeartbeat = interval > 0
+				coordinator.Unlock()
+				logger.Debug("heartbeat requested", "interval", interval)
+				coordinator.AddClient(client)
+
+			case 0x80: // IAmCamera
+				if client.IsCamera || client.IsDispatcher {
+					logger.Debug("illegal message", "message", "IAmCamera")
+					client.WriteError("illegal IAmCamera message")
+					return
+				}
+
+				client.IsCamera = true
+				client.Road = Road(client.Read16())
+				client.Mile = client.Read16()
+				client.Limit = client.Read16()
+				logger.Debug("camera registered", "road", client.Road, "mile", client.Mile, "limit", client.Limit)
+				coordinator.AddClient(client)
+
+			case 0x81: // IAmDispatcher
+				if client.IsCamera || client.IsDispatcher {
+					logger.Debug("illegal message", "message", "IAmDispatcher")
+					client.WriteError("illegal IAmDispatcher message")
+					return
+				}
+
+				roads := make([]Road, client.Read8())
+				for i := 0; i < len(roads); i++ {
+					roads[i] = Road(client.Read16())
+				}
+				coordinator.Lock()
+				client.IsDispatcher = true
+				client.Roads = roads
+				coordinator.Unlock()
+				logger.Debug("dispatcher registered", "roads", roads)
+				coordinator.AddClient(client)
+
+			default:
+				if client.Err() == nil {
+					logger.Debug("unsupported message")
+					client.WriteError("unsupported message")
+				}
+				return
+			}
+		}
+	})
+}
+
+type Road uint16
+
+// Client is a connection from a camera or a dispatcher.  Besides its own
+// handler, the heartbeat goroutine and the handlers of cameras that issue
+// tickets write to it, so writes and the error they leave behind are guarded
+// by mutex.  The heartbeat and dispatcher fields are read by those goroutines
+// too, and are only changed while holding the coordinator's lock.
+type Client struct {
+	ID         int
+	Connection net.Conn
+
+	mutex sync.Mutex
+	err   error // The first error reading from or writing to the connection
+
+	WantsHeartbeat    bool
+	HeartbeatInterval uint32
+	HeartbeatCounter  uint32
+
+	IsDispatcher bool
+	Roads        []Road
+
+	IsCamera    bool
+	Road        Road
+	Mile, Limit uint16
+}
+
+// Err returns the first error reading from or writing to the client, once
+// there's been one every later read and write is skipped.
+func (c *Client) Err() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.err
+}
+
+func (c *Client) Read8() uint8 {
+	var data uint8
+	c.read(&data)
+	return data
+}
+
+func (c *Client) Read16() uint16 {
+	var data uint16
+	c.read(&data)
+	return data
+}
+
+func (c *Client) Read32() uint32 {
+	var data uint32
+	c.read(&data)
+	return data
+}
+
+func (c *Client) ReadString() string {
+	data := make([]byte, c.Read8())
+	c.read(data)
+	return string(data)
+}
+
+// read reads data from the client.  Only the client's handler reads, so the
+// mutex isn't held while it waits, which would hold up writes to the client.
+func (c *Client) read(data any) {
+	if c.Err() != nil {
+		return
+	}
+
+	err := binary.Read(c.Connection, binary.BigEndian, data)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+func (c *Client) Write8(n uint8) {
+	c.write(n)
+}
+
+func (c *Client) Write16(n uint16) {
+	c.write(n)
+}
+
+func (c *Client) Write32(n uint32) {
+	c.write(n)
+}
+
+func (c *Client) WriteString(s string) {
+	c.write(uint8(len(s)), []byte(s))
+}
+
+func (c *Client) WriteError(s string) {
+	c.write(uint8(0x10), uint8(len(s)), []byte(s))
+}
+
+func (c *Client) WriteTicket(t Ticket) {
+	c.write(
+		uint8(0x21),
+		uint8(len(t.Plate)), []byte(t.Plate),
+		uint16(t.Road),
+		t.Mile1,
+		t.Timestamp1,
+		t.Mile2,
+		t.Timestamp2,
+		t.Speed,
+	)
+}
+
+// write writes the fields of a message to the client in order, holding the
+// mutex so that messages written by different goroutines don't interleave.
+func (c *Client) write(data ...any) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for i := 0; c.err == nil && i < len(data); i++ {
+		c.err = binary.Write(c.Connection, binary.BigEndian, data[i])
+	}
+}
+
+var NextID int
+var NextIDMutex sync.Mutex
+
+func GetNextID() int {
+	NextIDMutex.Lock()
+	defer NextIDMutex.Unlock()
+
+	id := NextID
+	NextID++
+	return id
+}
+
+// SendHeartbeats sends the heartbeats that are due every tenth of a second
+// until ctx is cancelled.
+func SendHeartbeats(ctx context.Context, c *Coordinator) {
+	ticker := time.NewTicker(time.Second / 10)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.SendHeartbeats()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package problem06_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bbeck/protohackers/internal/client"
+	"github.com/bbeck/protohackers/internal/problem06"
+	"github.com/bbeck/protohackers/internal/servertest"
+	"os"
+	"testing"
+	"time"
+)
+
+func camera(t *testing.T, addr string, road, mile, limit uint16) *client.Camera {
+	t.Helper()
+
+	conn := servertest.Dial(t, "tcp", addr)
+	c, err := client.NewCamera(conn, road, mile, limit)
+	if err != nil {
+		t.Fatalf("error registering camera: %v", err)
+	}
+	return c
+}
+
+func dispatcher(t *testing.T, addr string, roads ...uint16) (*client.Dispatcher, *servertest.Conn) {
+	t.Helper()
+
+	conn := servertest.Dial(t, "tcp", addr)
+	d, err := client.NewDispatcher(conn, roads...)
+	if err != nil {
+		t.Fatalf("error registering dispatcher: %v", err)
+	}
+	return d, conn
+}
+
+func plate(t *testing.T, c *client.Camera, plate string, timestamp uint32) {
+	t.Helper()
+
+	if err := c.Plate(plate, timestamp); err != nil {
+		t.Fatalf("error reporting plate: %v", err)
+	}
+}
+
+func expectTicket(t *testing.T, d *client.Dispatcher, conn *servertest.Conn, want client.Ticket) {
+	t.Helper()
+
+	_ = conn.SetReadDeadline(time.Now().Add(servertest.Timeout))
+	got, err := d.Ticket()
+	if err != nil {
+		t.Fatalf("error reading ticket: %v", err)
+	}
+	if got != want {
+		t.Fatalf("unexpected ticket\nwant: %+v\n got: %+v", want, got)
+	}
+}
+
+func expectNoTicket(t *testing.T, d *client.Dispatcher, conn *servertest.Conn) {
+	t.Helper()
+
+	_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	ticket, err := d.Ticket()
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("unexpected ticket: %+v (error: %v)", ticket, err)
+	}
+}
+
+func TestExampleSession(t *testing.T) {
+	addr := servertest.Start(t, problem06.Problem)
+
+	camera1 := servertest.Dial(t, "tcp", addr)
+	camera1.Send([]byte{0x80, 0x00, 0x7b, 0x00, 0x08, 0x00, 0x3c})
+	camera1.Send([]byte{0x20, 0x04, 0x55, 0x4e, 0x31, 0x58, 0x00, 0x00, 0x00, 0x00})
+
+	camera2 := servertest.Dial(t, "tcp", addr)
+	camera2.Send([]byte{0x80, 0x00, 0x7b, 0x00, 0x09, 0x00, 0x3c})
+	camera2.Send([]byte{0x20, 0x04, 0x55, 0x4e, 0x31, 0x58, 0x00, 0x00, 0x00, 0x2d})
+
+	dispatcher := servertest.Dial(t, "tcp", addr)
+	dispatcher.Send([]byte{0x81, 0x01, 0x00, 0x7b})
+	dispatcher.Expect([]byte{
+		0x21,
+		0x04, 0x55, 0x4e, 0x31, 0x58, // UN1X
+		0x00, 0x7b, // road 123
+		0x00, 0x08, // mile1 8
+		0x00, 0x00, 0x00, 0x00, // timestamp1 0
+		0x00, 0x09, // mile2 9
+		0x00, 0x00, 0x00, 0x2d, // timestamp2 45
+		0x1f, 0x40, // speed 80.00
+	})
+}
+
+func TestOneTicketPerDay(t *testing.T) {
+	addr := servertest.Start(t, problem06.Problem)
+	d, conn := dispatcher(t, addr, 10)
+
+	mile0 := camera(t, addr, 10, 0, 60)
+	mile10 := camera(t, addr, 10, 10, 60)
+	mile20 := camera(t, addr, 10, 20, 60)
+
+	plate(t, mile0, "DUP1", 0)
+	plate(t, mile10, "DUP1", 300)
+	expectTicket(t, d, conn, client.Ticket{Plate: "DUP1", Road: 10, Mile1: 0, Timestamp1: 0, Mile2: 10, Timestamp2: 300, Speed: 12000})
+
+	// Speeding again on the same day doesn't earn a second ticket, but speeding
+	// on the next day does.
+	plate(t, mile20, "DUP1", 600)
+	plate(t, mile0, "DUP1", 86400)
+	plate(t, mile10, "DUP1", 86700)
+	expectTicket(t, d, conn, client.Ticket{Plate: "DUP1", Road: 10, Mile1: 0, Timestamp1: 86400, Mile2: 10, Timestamp2: 86700, Speed: 12000})
+	expectNoTicket(t, d, conn)
+}
+
+func TestTicketSpanningDays(t *testing.T) {
+	addr := servertest.Start(t, problem06.Problem)
+	d, conn := dispatcher(t, addr, 20)
+
+	mile0 := camera(t, addr, 20, 0, 60)
+	mile100 := camera(t, addr, 20, 100, 60)
+	mile200 := camera(t, addr, 20, 200, 60)
+
+	// The first ticket covers both days, so speeding on either of them again
+	// doesn't earn another.
+	plate(t, mile0, "SPAN", 86400-1800)
+	plate(t, mile100, "SPAN", 86400+1800)
+	expectTicket(t, d, conn, client.Ticket{Plate: "SPAN", Road: 20, Mile1: 0, Timestamp1: 84600, Mile2: 100, Timestamp2: 88200, Speed: 10000})
+
+	plate(t, mile200, "SPAN", 86400+5400)
+	expectNoTicket(t, d, conn)
+}
+
+func TestWithinLimit(t *testing.T) {
+	addr := servertest.Start(t, problem06.Problem)
+	d, conn := dispatcher(t, addr, 30)
+
+	mile0 := camera(t, addr, 30, 0, 60)
+	mile1 := camera(t, addr, 30, 1, 60)
+
+	// Exactly 60 mph, and speeding on a different road, are both fine.
+	plate(t, mile0, "SLOW", 0)
+	plate(t, mile1, "SLOW", 60)
+	other := camera(t, addr, 31, 1, 60)
+	plate(t, other, "SLOW", 1)
+	expectNoTicket(t, d, conn)
+}
+
+func TestTicketsQueuedForDispatcher(t *testing.T) {
+	addr := servertest.Start(t, problem06.Problem)
+
+	mile0 := camera(t, addr, 40, 0, 30)
+	mile5 := camera(t, addr, 40, 5, 30)
+	plate(t, mile0, "LATE", 1000)
+	plate(t, mile5, "LATE", 1300)
+
+	// Give the server a moment to issue the ticket before a dispatcher exists.
+	time.Sleep(50 * time.Millisecond)
+
+	d, conn := dispatcher(t, addr, 39, 40, 41)
+	expectTicket(t, d, conn, client.Ticket{Plate: "LATE", Road: 40, Mile1: 0, Timestamp1: 1000, Mile2: 5, Timestamp2: 1300, Speed: 6000})
+}
+
+func TestHeartbeats(t *testing.T) {
+	addr := servertest.Start(t, problem06.Problem)
+	conn := servertest.Dial(t, "tcp", addr)
+
+	// Heartbeats can be requested before identifying as a camera or dispatcher.
+	conn.Send([]byte{0x40, 0x00, 0x00, 0x00, 0x01})
+	conn.Expect([]byte{0x41, 0x41, 0x41})
+}
+
+func TestHeartbeatsWithTickets(t *testing.T) {
+	addr := servertest.Start(t, problem06.Problem)
+
+	// Heartbeats are requested a while after identifying, once the server has
+	// started checking whether the dispatcher wants any, and are written to it by
+	// a different goroutine than its tickets.
+	d, conn := dispatcher(t, addr, 60)
+	time.Sleep(200 * time.Millisecond)
+	if err := d.WantHeartbeat(1); err != nil {
+		t.Fatalf("error requesting heartbeats: %v", err)
+	}
+	mile0 := camera(t, addr, 60, 0, 30)
+	if err := mile0.WantHeartbeat(1); err != nil {
+		t.Fatalf("error requesting heartbeats: %v", err)
+	}
+	mile5 := camera(t, addr, 60, 5, 30)
+
+	want := make(map[client.Ticket]bool)
+	for i := 0; i < 20; i++ {
+		car := fmt.Sprintf("CAR%02d", i)
+		plate(t, mile0, car, 1000)
+		plate(t, mile5, car, 1300)
+		want[client.Ticket{Plate: car, Road: 60, Mile1: 0, Timestamp1: 1000, Mile2: 5, Timestamp2: 1300, Speed: 6000}] = true
+	}
+
+	// The cameras report concurrently, so the tickets may arrive in any order.
+	for len(want) > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(servertest.Timeout))
+		ticket, err := d.Ticket()
+		if err != nil {
+			t.Fatalf("error reading ticket: %v", err)
+		}
+		if !want[ticket] {
+			t.Fatalf("unexpected ticket: %+v", ticket)
+		}
+		delete(want, ticket)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	addr := servertest.Start(t, problem06.Problem)
+
+	tests := []struct {
+		name     string
+		messages []byte
+		error    string
+	}{
+		{
+			"camera identifies twice",
+			[]byte{0x80, 0x00, 0x01, 0x00, 0x01, 0x00, 0x01, 0x80, 0x00, 0x01, 0x00, 0x01, 0x00, 0x01},
+			"illegal IAmCamera message",
+		},
+		{
+			"camera becomes dispatcher",
+			[]byte{0x80, 0x00, 0x01, 0x00, 0x01, 0x00, 0x01, 0x81, 0x01, 0x00, 0x01},
+			"illegal IAmDispatcher message",
+		},
+		{
+			"dispatcher reports plate",
+			[]byte{0x81, 0x01, 0x00, 0x01, 0x20, 0x01, 0x41, 0x00, 0x00, 0x00, 0x00},
+			"illegal plate message from dispatcher",
+		},
+		{
+			"unknown message type",
+			[]byte{0x99},
+			"unsupported message",
+		},
+		{
+			"server-to-client message type",
+			[]byte{0x21},
+			"unsupported message",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn := servertest.Dial(t, "tcp", addr)
+			conn.Send(test.messages)
+			conn.Expect(append([]byte{0x10, byte(len(test.error))}, test.error...))
+			conn.ExpectClosed()
+		})
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package problem07
 
 func Split(in []byte) [][]byte {
 	var fields [][]byte
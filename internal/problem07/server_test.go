@@ -0,0 +1,221 @@
+package problem07_test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/bbeck/protohackers/internal"
+	"github.com/bbeck/protohackers/internal/client"
+	"github.com/bbeck/protohackers/internal/faults"
+	"github.com/bbeck/protohackers/internal/problem07"
+	"github.com/bbeck/protohackers/internal/servertest"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestExampleSession(t *testing.T) {
+	addr := servertest.Start(t, problem07.Problem)
+	conn := servertest.Dial(t, "udp", addr)
+
+	conn.SendString("/connect/12345/")
+	conn.ExpectDatagram("/ack/12345/0/")
+
+	conn.SendString("/data/12345/0/hello\n/")
+	conn.ExpectDatagram("/ack/12345/6/")
+	conn.ExpectDatagram("/data/12345/0/olleh\n/")
+	conn.SendString("/ack/12345/6/")
+
+	conn.SendString("/data/12345/6/Hello, world!\n/")
+	conn.ExpectDatagram("/ack/12345/20/")
+	conn.ExpectDatagram("/data/12345/6/!dlrow ,olleH\n/")
+	conn.SendString("/ack/12345/20/")
+
+	conn.SendString("/close/12345/")
+	conn.ExpectDatagram("/close/12345/")
+}
+
+func TestEscaping(t *testing.T) {
+	addr := servertest.Start(t, problem07.Problem)
+	conn := servertest.Dial(t, "udp", addr)
+
+	conn.SendString("/connect/1/")
+	conn.ExpectDatagram("/ack/1/0/")
+
+	// The positions and lengths count unescaped bytes.
+	conn.SendString(`/data/1/0/foo\/bar\\baz` + "\n/")
+	conn.ExpectDatagram("/ack/1/12/")
+	conn.ExpectDatagram(`/data/1/0/zab\\rab\/oof` + "\n/")
+}
+
+func TestUnknownSession(t *testing.T) {
+	addr := servertest.Start(t, problem07.Problem)
+	conn := servertest.Dial(t, "udp", addr)
+
+	conn.SendString("/data/99/0/hello\n/")
+	conn.ExpectDatagram("/close/99/")
+
+	conn.SendString("/ack/99/0/")
+	conn.ExpectDatagram("/close/99/")
+}
+
+func TestMissingData(t *testing.T) {
+	addr := servertest.Start(t, problem07.Problem)
+	conn := servertest.Dial(t, "udp", addr)
+
+	conn.SendString("/connect/7/")
+	conn.ExpectDatagram("/ack/7/0/")
+
+	// Data after a gap is acknowledged with how much has been received so far,
+	// and data that's already been received isn't passed on again.
+	conn.SendString("/data/7/3/lo\n/")
+	conn.ExpectDatagram("/ack/7/0/")
+	conn.SendString("/data/7/0/hel/")
+	conn.ExpectDatagram("/ack/7/3/")
+	conn.SendString("/data/7/0/hello\n/")
+	conn.ExpectDatagram("/ack/7/6/")
+	conn.ExpectDatagram("/data/7/0/olleh\n/")
+	conn.SendString("/ack/7/6/")
+
+	// Duplicate connects are acknowledged with how much has been received.
+	conn.SendString("/connect/7/")
+	conn.ExpectDatagram("/ack/7/6/")
+}
+
+func TestMalformedPackets(t *testing.T) {
+	addr := servertest.Start(t, problem07.Problem)
+	conn := servertest.Dial(t, "udp", addr)
+
+	for _, packet := range []string{
+		"",
+		"/connect/1",
+		"connect/1/",
+		"/connect/",
+		"/connect/-1/",
+		"/connect/2147483648/",
+		"/connect/1/2/",
+		"/bogus/1/",
+		"/data/1/0/",
+		"/ack/1/",
+	} {
+		conn.SendString(packet)
+	}
+	conn.ExpectNothing(100 * time.Millisecond)
+}
+
+func TestRetransmission(t *testing.T) {
+	previous := problem07.RetransmissionTimeout
+	problem07.RetransmissionTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { problem07.RetransmissionTimeout = previous })
+
+	addr := servertest.Start(t, problem07.Problem)
+	conn := servertest.Dial(t, "udp", addr)
+
+	conn.SendString("/connect/42/")
+	conn.ExpectDatagram("/ack/42/0/")
+
+	conn.SendString("/data/42/0/abc\n/")
+	conn.ExpectDatagram("/ack/42/4/")
+	conn.ExpectDatagram("/data/42/0/cba\n/")
+
+	// Until the data is acknowledged it's sent again.
+	conn.ExpectDatagram("/data/42/0/cba\n/")
+	conn.ExpectDatagram("/data/42/0/cba\n/")
+
+	conn.SendString("/ack/42/4/")
+	conn.ExpectNothing(300 * time.Millisecond)
+}
+
+func TestAckBeyondSentData(t *testing.T) {
+	addr := servertest.Start(t, problem07.Problem)
+	conn := servertest.Dial(t, "udp", addr)
+
+	conn.SendString("/connect/5/")
+	conn.ExpectDatagram("/ack/5/0/")
+
+	// A peer acknowledging data that was never sent is misbehaving.
+	conn.SendString("/ack/5/100/")
+	conn.ExpectDatagram("/close/5/")
+}
+
+func TestLongLines(t *testing.T) {
+	addr := servertest.Start(t, problem07.Problem)
+
+	conn, err := client.DialLRCP(addr)
+	if err != nil {
+		t.Fatalf("error connecting: %v", err)
+	}
+	defer conn.Close()
+
+	// Lines longer than a single packet are reassembled, reversed and split
+	// across packets again.
+	line := bytes.Repeat([]byte("0123456789/\\"), 500)
+	if _, err := conn.Write(append(line, '\n')); err != nil {
+		t.Fatalf("error writing: %v", err)
+	}
+
+	want := make([]byte, len(line))
+	for i := range line {
+		want[i] = line[len(line)-1-i]
+	}
+
+	got := make([]byte, len(line)+1)
+	_ = conn.SetReadDeadline(time.Now().Add(servertest.Timeout))
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatalf("error reading: %v", err)
+	}
+	if !bytes.Equal(got, append(want, '\n')) {
+		t.Fatalf("unexpected line: %q", got)
+	}
+}
+
+func TestFaultyNetwork(t *testing.T) {
+	previous := problem07.RetransmissionTimeout
+	problem07.RetransmissionTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { problem07.RetransmissionTimeout = previous })
+
+	// Every fault is injected into the packets in both directions, with a fixed
+	// seed so that a failure can be reproduced.
+	proxy := &faults.Proxy{
+		Upstream: servertest.Start(t, problem07.Problem),
+		Profile: faults.Profile{
+			Seed:      7,
+			Drop:      0.2,
+			Duplicate: 0.2,
+			Delay:     0.2,
+			Reorder:   0.2,
+			Truncate:  0.1,
+			MaxDelay:  50 * time.Millisecond,
+		},
+	}
+	addr := servertest.Start(t, internal.Problem{Name: "Fault Proxy", Run: proxy.ServeUDP})
+
+	dialer := &client.LRCPDialer{RetransmissionTimeout: 100 * time.Millisecond}
+	conn, err := dialer.Dial(addr)
+	if err != nil {
+		t.Fatalf("error connecting: %v", err)
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	for i := 0; i < 10; i++ {
+		line := fmt.Sprintf("line %02d of the session", i)
+		if _, err := conn.Write([]byte(line + "\n")); err != nil {
+			t.Fatalf("error writing: %v", err)
+		}
+
+		want := make([]byte, len(line))
+		for j := range line {
+			want[j] = line[len(line)-1-j]
+		}
+
+		_ = conn.SetReadDeadline(time.Now().Add(servertest.Timeout))
+		got, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("error reading: %v", err)
+		}
+		if got != string(want)+"\n" {
+			t.Fatalf("unexpected line: %q", got)
+		}
+	}
+}
@@ -1,12 +1,19 @@
-package main
+package problem07
 
 import (
 	"fmt"
+	"github.com/bbeck/protohackers/internal"
+	"log/slog"
 	"sync"
 	"time"
 )
 
-const (
+var (
+	SessionsActive  = internal.NewGauge("protohackers_lrcp_sessions_active", "Number of LRCP sessions that are open.")
+	Retransmissions = internal.NewCounter("protohackers_lrcp_retransmissions_total", "Number of times unacknowledged data was retransmitted.")
+)
+
+var (
 	SessionExpiration     = 60 * time.Second
 	RetransmissionTimeout = 3 * time.Second
 )
@@ -16,31 +23,41 @@ type Sessions struct {
 
 	Cache      map[int]*Session
 	LastAccess map[int]time.Time
+	Done       chan struct{}
 }
 
-func NewSessions() *Sessions {
+// NewSessions returns an empty cache of sessions, expired sessions are logged
+// to logger.
+func NewSessions(logger *slog.Logger) *Sessions {
 	sessions := &Sessions{
 		Cache:      make(map[int]*Session),
 		LastAccess: make(map[int]time.Time),
+		Done:       make(chan struct{}),
 	}
 
 	// Create the background reaper process that closes expired sessions and
-	// removes them from the cache.
+	// removes them from the cache.  This goroutine will stop when the sessions
+	// are closed.
 	go func() {
 		ticker := time.NewTicker(SessionExpiration / 10)
 		defer ticker.Stop()
 
-		for range ticker.C {
+		for {
+			select {
+			case <-sessions.Done:
+				return
+			case <-ticker.C:
+			}
+
 			sessions.Mutex.Lock()
 
 			for id, last := range sessions.LastAccess {
 				if time.Now().Sub(last) >= SessionExpiration {
-					session := sessions.Cache[id]
-					if !session.Closed {
-						session.HandleClose()
-					}
+					sessions.Cache[id].CloseIfOpen()
+					logger.Debug("session expired", "session", id)
 					delete(sessions.Cache, id)
 					delete(sessions.LastAccess, id)
+					SessionsActive.Dec()
 				}
 			}
 
@@ -67,6 +84,9 @@ func (s *Sessions) Put(id int, session *Session) {
 	s.Mutex.Lock()
 	defer s.Mutex.Unlock()
 
+	if _, found := s.Cache[id]; !found {
+		SessionsActive.Inc()
+	}
 	s.Cache[id] = session
 	s.LastAccess[id] = time.Now()
 }
@@ -75,10 +95,29 @@ func (s *Sessions) Invalidate(id int) {
 	s.Mutex.Lock()
 	defer s.Mutex.Unlock()
 
+	if _, found := s.Cache[id]; found {
+		SessionsActive.Dec()
+	}
 	delete(s.Cache, id)
 	delete(s.LastAccess, id)
 }
 
+// Close closes every open session, letting each peer know that its session is
+// over, and stops the background reaper.
+func (s *Sessions) Close() {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	for id, session := range s.Cache {
+		session.CloseIfOpen()
+		delete(s.Cache, id)
+		delete(s.LastAccess, id)
+		SessionsActive.Dec()
+	}
+
+	close(s.Done)
+}
+
 // =============================================================================
 
 type Session struct {
@@ -86,7 +125,7 @@ type Session struct {
 
 	ID          int
 	Closed      bool
-	Send        func([]byte)
+	Send        func([]byte) error
 	Application *Application
 
 	// The position in the stream that we've completely received.
@@ -98,7 +137,9 @@ type Session struct {
 	AckTo        int       // The last position we've received an ack for
 }
 
-func NewSession(id int, send func([]byte)) *Session {
+// NewSession returns an open session that sends packets to its peer with send,
+// retransmissions are logged to logger.
+func NewSession(id int, send func([]byte) error, logger *slog.Logger) *Session {
 	session := &Session{
 		ID:   id,
 		Send: send,
@@ -107,17 +148,23 @@ func NewSession(id int, send func([]byte)) *Session {
 
 	// Create the background goroutine to retransmit messages that aren't acked in
 	// a timely fashion.  This goroutine will stop when the session is closed.
+	timeout := RetransmissionTimeout
 	go func() {
-		ticker := time.NewTicker(RetransmissionTimeout / 10)
+		ticker := time.NewTicker(timeout / 10)
 		defer ticker.Stop()
 
 		for range ticker.C {
+			session.Lock()
 			if session.Closed {
+				session.Unlock()
 				break
 			}
 
-			session.Lock()
-			if time.Now().Sub(session.LastSendTime) > RetransmissionTimeout {
+			if time.Now().Sub(session.LastSendTime) > timeout {
+				if session.SentTo > session.AckTo {
+					logger.Debug("retransmitting", "session", session.ID, "from", session.AckTo, "to", session.SentTo)
+					Retransmissions.Inc()
+				}
 				session.SentTo = session.AckTo
 				session.MaybeSend()
 			}
@@ -131,11 +178,11 @@ func NewSession(id int, send func([]byte)) *Session {
 func (s *Session) Reply(kind string, args ...any) {
 	switch kind {
 	case "ack":
-		s.Send([]byte(fmt.Sprintf("/ack/%d/%d/", s.ID, args[0])))
+		_ = s.Send([]byte(fmt.Sprintf("/ack/%d/%d/", s.ID, args[0])))
 	case "close":
-		s.Send([]byte(fmt.Sprintf("/close/%d/", s.ID)))
+		_ = s.Send([]byte(fmt.Sprintf("/close/%d/", s.ID)))
 	case "data":
-		s.Send([]byte(fmt.Sprintf("/data/%d/%d/%s/", s.ID, args[0], args[1])))
+		_ = s.Send([]byte(fmt.Sprintf("/data/%d/%d/%s/", s.ID, args[0], args[1])))
 	}
 }
 
@@ -208,6 +255,16 @@ func (s *Session) HandleClose() {
 	s.Close()
 }
 
+// CloseIfOpen closes the session unless it's already closed.
+func (s *Session) CloseIfOpen() {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.Closed {
+		s.Close()
+	}
+}
+
 func (s *Session) Close() {
 	// When you receive a /close/SESSION/ message, send a matching close
 	// message back.
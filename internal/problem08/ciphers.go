@@ -1,4 +1,4 @@
-package main
+package problem08
 
 import (
 	"io"
@@ -84,14 +84,23 @@ type Encrypter struct {
 	ciphers []CipherFunc
 }
 
+// NewEncrypter returns a writer that applies ciphers, in order, to each byte
+// written to it before writing it to w.
+func NewEncrypter(w io.Writer, ciphers []CipherFunc) *Encrypter {
+	return &Encrypter{w: w, ciphers: ciphers}
+}
+
 func (e *Encrypter) Write(bs []byte) (int, error) {
+	// Encrypt into a copy, the caller's buffer must not be modified.
+	out := make([]byte, len(bs))
 	for i := 0; i < len(bs); i++ {
+		out[i] = bs[i]
 		for _, cipher := range e.ciphers {
-			bs[i] = cipher(bs[i])
+			out[i] = cipher(out[i])
 		}
 	}
 
-	n, err := e.w.Write(bs)
+	n, err := e.w.Write(out)
 	return n, err
 }
 
@@ -100,6 +109,13 @@ type Decrypter struct {
 	ciphers []CipherFunc
 }
 
+// NewDecrypter returns a reader that undoes ciphers, in reverse order, on each
+// byte read from r.  The ciphers must be the inverses of the ones that
+// encrypted the stream.
+func NewDecrypter(r io.Reader, ciphers []CipherFunc) *Decrypter {
+	return &Decrypter{r: r, ciphers: ciphers}
+}
+
 func (d *Decrypter) Read(bs []byte) (int, error) {
 	n, err := d.r.Read(bs)
 	for i := 0; i < n; i++ {
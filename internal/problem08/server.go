@@ -0,0 +1,118 @@
+package problem08
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/bbeck/protohackers/internal"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Problem is the Insecure Sockets Layer problem.
+var Problem = internal.Problem{ID: 8, Name: "Insecure Sockets Layer", Run: Run}
+
+// Run runs the Insecure Sockets Layer server until ctx is cancelled.
+func Run(ctx context.Context, server *internal.Server) error {
+	return server.ServeTCP(ctx, func(conn net.Conn) {
+		defer conn.Close()
+		logger := internal.Logger(conn)
+
+		r := bufio.NewReader(conn)
+		header, err := r.ReadBytes(0)
+		if err != nil {
+			return
+		}
+
+		encrypt, decrypt := GetCiphers(header)
+		if IsIdentityCipher(encrypt) {
+			logger.Debug("identity cipher rejected", "spec", fmt.Sprintf("%x", header))
+			return
+		}
+		logger.Debug("cipher spec negotiated", "spec", fmt.Sprintf("%x", header))
+
+		// Re-create the ciphers so that any state that was mutated by identity
+		// checking is reset.
+		encrypt, decrypt = GetCiphers(header)
+
+		// Wrap the reader in a decrypter, and build an encrypting writer.
+		in := bufio.NewScanner(&Decrypter{r: r, ciphers: decrypt})
+		out := Encrypter{w: conn, ciphers: encrypt}
+
+		for in.Scan() {
+			if in.Err() != nil {
+				return
+			}
+
+			line := in.Text()
+			choice := ChooseToy(line)
+			logger.Debug("toy chosen", "toy", choice)
+			out.Write([]byte(choice + "\n"))
+		}
+	})
+}
+
+func GetCiphers(bs []byte) ([]CipherFunc, []CipherFunc) {
+	var encrypt, decrypt []CipherFunc
+
+	for i := 0; i < len(bs); i++ {
+		switch bs[i] {
+		case 0x01:
+			encrypt = append(encrypt, ReverseBits())
+			decrypt = append(decrypt, ReverseBits())
+
+		case 0x02:
+			N := bs[i+1]
+			encrypt = append(encrypt, XorN(N))
+			decrypt = append(decrypt, XorN(N))
+			i++
+
+		case 0x03:
+			encrypt = append(encrypt, XorPos())
+			decrypt = append(decrypt, XorPos())
+
+		case 0x04:
+			N := bs[i+1]
+			encrypt = append(encrypt, AddN(N))
+			decrypt = append(decrypt, SubN(N))
+			i++
+
+		case 0x05:
+			encrypt = append(encrypt, AddPos())
+			decrypt = append(decrypt, SubPos())
+		}
+	}
+
+	return encrypt, decrypt
+}
+
+func IsIdentityCipher(ciphers []CipherFunc) bool {
+	for n := 0; n < 10; n++ {
+		for b := 0; b < 256; b++ {
+			data := byte(b)
+			for _, cipher := range ciphers {
+				data = cipher(data)
+			}
+			if data != byte(b) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func ChooseToy(s string) string {
+	var count int64
+	var best string
+	for _, part := range strings.Split(s, ",") {
+		n, _, _ := strings.Cut(part, "x")
+		c, _ := strconv.ParseInt(n, 10, 64)
+		if c > count {
+			count = c
+			best = part
+		}
+	}
+
+	return best
+}
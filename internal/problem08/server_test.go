@@ -0,0 +1,97 @@
+package problem08_test
+
+import (
+	"github.com/bbeck/protohackers/internal/client"
+	"github.com/bbeck/protohackers/internal/problem08"
+	"github.com/bbeck/protohackers/internal/servertest"
+	"testing"
+)
+
+func TestExampleSessions(t *testing.T) {
+	addr := servertest.Start(t, problem08.Problem)
+
+	t.Run("xor(123),addpos,reversebits", func(t *testing.T) {
+		conn := servertest.Dial(t, "tcp", addr)
+		conn.Send([]byte{0x02, 0x7b, 0x05, 0x01, 0x00})
+
+		// 4x dog,5x car
+		conn.Send([]byte{0xf2, 0x20, 0xba, 0x44, 0x18, 0x84, 0xba, 0xaa, 0xd0, 0x26, 0x44, 0xa4, 0xa8, 0x7e})
+		// 5x car
+		conn.Expect([]byte{0x72, 0x20, 0xba, 0xd8, 0x78, 0x70, 0xee})
+
+		// 3x rat,2x cat
+		conn.Send([]byte{0x6a, 0x48, 0xd6, 0x58, 0x34, 0x44, 0xd6, 0x7a, 0x98, 0x4e, 0x0c, 0xcc, 0x94, 0x31})
+		// 3x rat
+		conn.Expect([]byte{0xf2, 0xd0, 0x26, 0xc8, 0xa4, 0xd8, 0x7e})
+	})
+
+	t.Run("addpos,addpos", func(t *testing.T) {
+		conn := servertest.Dial(t, "tcp", addr)
+		conn.Send([]byte{0x05, 0x05, 0x00})
+
+		// Each byte has twice its position in the stream added to it.
+		encode := func(s string, pos int) []byte {
+			bs := []byte(s)
+			for i := range bs {
+				bs[i] += byte(2 * (pos + i))
+			}
+			return bs
+		}
+
+		conn.Send(encode("4x dog,5x car\n", 0))
+		conn.Expect(encode("5x car\n", 0))
+		conn.Send(encode("3x rat,2x cat\n", 14))
+		conn.Expect(encode("3x rat\n", 7))
+	})
+}
+
+func TestIdentityCiphersRejected(t *testing.T) {
+	addr := servertest.Start(t, problem08.Problem)
+
+	specs := map[string][]byte{
+		"empty":                    {0x00},
+		"xor(0)":                   {0x02, 0x00, 0x00},
+		"xor(123),xor(123)":        {0x02, 0x7b, 0x02, 0x7b, 0x00},
+		"reversebits,reversebits":  {0x01, 0x01, 0x00},
+		"xor(160),xor(160),add(0)": {0x02, 0xa0, 0x02, 0xa0, 0x04, 0x00, 0x00},
+		"xorpos,xorpos":            {0x03, 0x03, 0x00},
+		"add(128),add(128)":        {0x04, 0x80, 0x04, 0x80, 0x00},
+	}
+
+	for name, spec := range specs {
+		t.Run(name, func(t *testing.T) {
+			conn := servertest.Dial(t, "tcp", addr)
+			conn.Send(spec)
+			conn.SendString("4x dog,5x car\n")
+			conn.ExpectClosed()
+		})
+	}
+}
+
+func TestChoosesMostCopies(t *testing.T) {
+	addr := servertest.Start(t, problem08.Problem)
+
+	c, err := client.DialISL(addr, []byte{0x02, 0x7b, 0x05, 0x01, 0x03, 0x04, 0x2a})
+	if err != nil {
+		t.Fatalf("error connecting: %v", err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		request, toy string
+	}{
+		{"10x toy car,15x dog on a string,4x inflatable motorcycle", "15x dog on a string"},
+		{"1x a", "1x a"},
+		{"3x a,300x b,20x c", "300x b"},
+	}
+
+	for _, test := range tests {
+		toy, err := c.ChooseToy(test.request)
+		if err != nil {
+			t.Fatalf("error choosing toy: %v", err)
+		}
+		if toy != test.toy {
+			t.Errorf("unexpected toy for %q, want %q, got %q", test.request, test.toy, toy)
+		}
+	}
+}
@@ -1,30 +1,43 @@
-package main
+package problem09
 
 import (
 	"encoding/json"
+	"github.com/bbeck/protohackers/internal"
+	"log/slog"
 	"sync"
 )
 
+var (
+	JobsQueued     = internal.NewGaugeVec("protohackers_jobs_queued", "Number of jobs waiting in each queue.", "server", "queue")
+	JobsInProgress = internal.NewGaugeVec("protohackers_jobs_in_progress", "Number of jobs that are assigned to a client.", "server")
+)
+
 type JobID uint64
 type ClientID uint64
 
 type JobManager struct {
 	sync.Mutex
+	Server     string // The name of the server in the job metrics
+	Logger     *slog.Logger
 	Jobs       map[JobID]*Job
 	Queues     map[string]*PriorityQueue[*Job]
 	InProgress map[ClientID][]*Job
+	LastJobID  JobID // The ID of the most recently added job
 }
 
+// AddJob adds a job to a queue and returns its ID.  Each manager numbers its
+// jobs from 1, so that servers in the same process don't share IDs.
 func (m *JobManager) AddJob(queue string, priority int, payload json.RawMessage) JobID {
 	m.Lock()
 	defer m.Unlock()
 
 	job := &Job{
-		ID:       GetNextJobId(),
+		ID:       m.LastJobID + 1,
 		Queue:    queue,
 		Priority: priority,
 		Payload:  payload,
 	}
+	m.LastJobID = job.ID
 	m.Jobs[job.ID] = job
 
 	q := m.Queues[queue]
@@ -33,6 +46,7 @@ func (m *JobManager) AddJob(queue string, priority int, payload json.RawMessage)
 		m.Queues[queue] = q
 	}
 	q.Push(job, -job.Priority) // -priority for max heap
+	JobsQueued.With(m.Server, queue).Inc()
 
 	return job.ID
 }
@@ -65,7 +79,10 @@ func (m *JobManager) GetJob(id ClientID, queues []string) (Job, bool) {
 	}
 
 	job := bestQueue.Pop()
+	job.Assigned = true
 	m.InProgress[id] = append(m.InProgress[id], job)
+	JobsQueued.With(m.Server, job.Queue).Dec()
+	JobsInProgress.With(m.Server).Inc()
 	return *job, true
 }
 
@@ -80,6 +97,11 @@ func (m *JobManager) DeleteJob(id JobID) bool {
 
 	job.Deleted = true
 	delete(m.Jobs, id)
+	if job.Assigned {
+		JobsInProgress.With(m.Server).Dec()
+	} else {
+		JobsQueued.With(m.Server, job.Queue).Dec()
+	}
 	return true
 }
 
@@ -99,7 +121,10 @@ func (m *JobManager) AbortJob(clientID ClientID, jobID JobID) bool {
 			m.InProgress[clientID] = m.InProgress[clientID][1:]
 
 			// Put it back into its queue
+			job.Assigned = false
 			m.Queues[job.Queue].Push(job, -job.Priority)
+			JobsInProgress.With(m.Server).Dec()
+			JobsQueued.With(m.Server, job.Queue).Inc()
 
 			return true
 		}
@@ -114,24 +139,22 @@ func (m *JobManager) OnClientDisconnect(id ClientID) {
 
 	for _, job := range m.InProgress[id] {
 		if !job.Deleted {
+			job.Assigned = false
 			m.Queues[job.Queue].Push(job, -job.Priority)
+			JobsInProgress.With(m.Server).Dec()
+			JobsQueued.With(m.Server, job.Queue).Inc()
+			m.Logger.Debug("job requeued", "job_id", job.ID, "client_id", id)
 		}
 	}
 
 	delete(m.InProgress, id)
 }
 
-var NextJobId JobID
-
-func GetNextJobId() JobID {
-	NextJobId++
-	return NextJobId
-}
-
 type Job struct {
 	ID       JobID
 	Queue    string
 	Priority int
 	Payload  json.RawMessage
 	Deleted  bool
+	Assigned bool // Whether a client is working on the job
 }
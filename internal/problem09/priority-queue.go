@@ -1,4 +1,4 @@
-package main
+package problem09
 
 // PriorityQueue represents a min heap.  The zero value for PriorityQueue is
 // an empty heap ready to use.
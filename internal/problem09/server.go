@@ -0,0 +1,180 @@
+package problem09
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/bbeck/protohackers/internal"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// Problem is the Job Centre problem.
+var Problem = internal.Problem{ID: 9, Name: "Job Centre", Run: Run}
+
+// Run runs the Job Centre server until ctx is cancelled.
+func Run(ctx context.Context, server *internal.Server) error {
+	manager := JobManager{
+		Server:     server.Name,
+		Logger:     server.Log(),
+		Jobs:       make(map[JobID]*Job),
+		Queues:     make(map[string]*PriorityQueue[*Job]),
+		InProgress: make(map[ClientID][]*Job),
+	}
+
+	return server.ServeTCP(ctx, func(conn net.Conn) {
+		defer conn.Close()
+
+		clientID := GetNextClientID()
+		defer manager.OnClientDisconnect(clientID)
+
+		logger := internal.Logger(conn).With("client_id", clientID)
+
+		disconnected := false
+		defer func() { disconnected = true }()
+
+		r := bufio.NewReaderSize(conn, 1024*1024)
+		for !disconnected {
+			bs, _, err := r.ReadLine()
+			if err != nil {
+				return
+			}
+
+			var request Request
+			if err := json.Unmarshal(bs, &request); err != nil || !request.IsValid() {
+				logger.Debug("invalid request", "request", string(bs))
+				io.WriteString(conn, InvalidRequest)
+				return
+			}
+
+			switch request.Type {
+			case "put":
+				id := manager.AddJob(*request.Queue, request.Priority, request.Job)
+				logger.Debug("job added", "job_id", id, "queue", *request.Queue, "priority", request.Priority)
+				io.WriteString(conn, fmt.Sprintf(`{"status":"ok","id":%d}`+"\n", id))
+
+			case "get":
+				queues := request.GetQueues()
+
+				for {
+					job, ok := manager.GetJob(clientID, queues)
+					if !ok && request.Wait {
+						time.Sleep(100 * time.Millisecond)
+						if disconnected || ctx.Err() != nil {
+							break
+						}
+						continue
+					}
+
+					if !ok {
+						io.WriteString(conn, NoJob)
+						break
+					}
+
+					logger.Debug("job assigned", "job_id", job.ID, "queue", job.Queue, "priority", job.Priority)
+					bs, _ := json.Marshal(GetResponse{
+						Status:   "ok",
+						ID:       job.ID,
+						Job:      job.Payload,
+						Priority: job.Priority,
+						Queue:    job.Queue,
+					})
+					io.WriteString(conn, string(bs)+"\n")
+					break
+				}
+
+			case "delete":
+				if !manager.DeleteJob(request.ID) {
+					io.WriteString(conn, NoJob)
+					continue
+				}
+				logger.Debug("job deleted", "job_id", request.ID)
+
+				io.WriteString(conn, Ok)
+
+			case "abort":
+				if !manager.AbortJob(clientID, request.ID) {
+					io.WriteString(conn, NoJob)
+					continue
+				}
+				logger.Debug("job aborted", "job_id", request.ID)
+
+				io.WriteString(conn, Ok)
+			}
+		}
+	})
+}
+
+type Request struct {
+	Type string `json:"request"`
+
+	// Put
+	Queue    *string         `json:"queue"`
+	Priority int             `json:"pri"`
+	Job      json.RawMessage `json:"job"`
+
+	// Get
+	Queues []*string `json:"queues"`
+	Wait   bool      `json:"wait"`
+
+	// Delete & Abort
+	ID JobID `json:"id"`
+}
+
+func (r *Request) IsValid() bool {
+	switch r.Type {
+	case "put":
+		return r.Queue != nil && r.Priority >= 0
+
+	case "get":
+		for _, queue := range r.Queues {
+			if queue == nil {
+				return false
+			}
+		}
+		return true
+
+	case "delete":
+		return true
+
+	case "abort":
+		return true
+
+	default:
+		return false
+	}
+}
+
+func (r *Request) GetQueues() []string {
+	out := make([]string, len(r.Queues))
+	for i := range r.Queues {
+		out[i] = *r.Queues[i]
+	}
+	return out
+}
+
+// GetResponse is the response to a get request that found a job.  The job's
+// ID is a number, as it is in the put response and in the requests that refer
+// to it.
+type GetResponse struct {
+	Status   string          `json:"status"`
+	ID       JobID           `json:"id"`
+	Job      json.RawMessage `json:"job"`
+	Priority int             `json:"pri"`
+	Queue    string          `json:"queue"`
+}
+
+// Every response is a JSON object on a line of its own, clients read them a line
+// at a time and wait forever for a response without a newline.
+var Ok = `{"status":"ok"}` + "\n"
+var InvalidRequest = `{"status":"error","error":"invalid request"}` + "\n"
+var NoJob = `{"status":"no-job"}` + "\n"
+
+var NextClientID atomic.Uint64
+
+func GetNextClientID() ClientID {
+	return ClientID(NextClientID.Add(1))
+}
@@ -0,0 +1,217 @@
+package problem09_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/bbeck/protohackers/internal"
+	"github.com/bbeck/protohackers/internal/problem09"
+	"github.com/bbeck/protohackers/internal/servertest"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	ok      = `{"status":"ok"}` + "\n"
+	noJob   = `{"status":"no-job"}` + "\n"
+	invalid = `{"status":"error","error":"invalid request"}` + "\n"
+)
+
+func TestExampleSession(t *testing.T) {
+	addr := servertest.Start(t, problem09.Problem)
+	conn := servertest.Dial(t, "tcp", addr)
+
+	conn.SendString(`{"request":"put","queue":"queue1","job":{"title":"example-job"},"pri":123}` + "\n")
+	conn.ExpectString(`{"status":"ok","id":1}` + "\n")
+
+	conn.SendString(`{"request":"get","queues":["queue1"]}` + "\n")
+	conn.ExpectString(`{"status":"ok","id":1,"job":{"title":"example-job"},"pri":123,"queue":"queue1"}` + "\n")
+
+	conn.SendString(`{"request":"abort","id":1}` + "\n")
+	conn.ExpectString(ok)
+
+	conn.SendString(`{"request":"get","queues":["queue1"]}` + "\n")
+	conn.ExpectString(`{"status":"ok","id":1,"job":{"title":"example-job"},"pri":123,"queue":"queue1"}` + "\n")
+
+	conn.SendString(`{"request":"delete","id":1}` + "\n")
+	conn.ExpectString(ok)
+
+	conn.SendString(`{"request":"get","queues":["queue1"]}` + "\n")
+	conn.ExpectString(noJob)
+
+	conn.SendString(`{"request":"delete","id":1}` + "\n")
+	conn.ExpectString(noJob)
+}
+
+func TestHighestPriorityFirst(t *testing.T) {
+	addr := servertest.Start(t, problem09.Problem)
+	conn := servertest.Dial(t, "tcp", addr)
+
+	conn.SendString(`{"request":"put","queue":"a","job":1,"pri":10}` + "\n")
+	conn.ExpectString(`{"status":"ok","id":1}` + "\n")
+	conn.SendString(`{"request":"put","queue":"b","job":2,"pri":30}` + "\n")
+	conn.ExpectString(`{"status":"ok","id":2}` + "\n")
+	conn.SendString(`{"request":"put","queue":"a","job":3,"pri":20}` + "\n")
+	conn.ExpectString(`{"status":"ok","id":3}` + "\n")
+	conn.SendString(`{"request":"put","queue":"c","job":4,"pri":99}` + "\n")
+	conn.ExpectString(`{"status":"ok","id":4}` + "\n")
+
+	conn.SendString(`{"request":"get","queues":["a","b"]}` + "\n")
+	conn.ExpectString(`{"status":"ok","id":2,"job":2,"pri":30,"queue":"b"}` + "\n")
+	conn.SendString(`{"request":"get","queues":["a","b"]}` + "\n")
+	conn.ExpectString(`{"status":"ok","id":3,"job":3,"pri":20,"queue":"a"}` + "\n")
+	conn.SendString(`{"request":"get","queues":["a","b"]}` + "\n")
+	conn.ExpectString(`{"status":"ok","id":1,"job":1,"pri":10,"queue":"a"}` + "\n")
+	conn.SendString(`{"request":"get","queues":["a","b"]}` + "\n")
+	conn.ExpectString(noJob)
+}
+
+func TestAbortOnDisconnect(t *testing.T) {
+	addr := servertest.Start(t, problem09.Problem)
+
+	producer := servertest.Dial(t, "tcp", addr)
+	producer.SendString(`{"request":"put","queue":"q","job":{"n":1},"pri":5}` + "\n")
+	producer.ExpectString(`{"status":"ok","id":1}` + "\n")
+
+	worker1 := servertest.Dial(t, "tcp", addr)
+	worker1.SendString(`{"request":"get","queues":["q"]}` + "\n")
+	worker1.ExpectString(`{"status":"ok","id":1,"job":{"n":1},"pri":5,"queue":"q"}` + "\n")
+
+	// While the job is assigned nobody else can get it.
+	worker2 := servertest.Dial(t, "tcp", addr)
+	worker2.SendString(`{"request":"get","queues":["q"]}` + "\n")
+	worker2.ExpectString(noJob)
+
+	// When the first worker disconnects its job goes back in the queue.
+	_ = worker1.Close()
+	worker2.SendString(`{"request":"get","queues":["q"],"wait":true}` + "\n")
+	worker2.ExpectString(`{"status":"ok","id":1,"job":{"n":1},"pri":5,"queue":"q"}` + "\n")
+}
+
+func TestAbortByOtherClient(t *testing.T) {
+	addr := servertest.Start(t, problem09.Problem)
+
+	worker := servertest.Dial(t, "tcp", addr)
+	worker.SendString(`{"request":"put","queue":"q","job":null,"pri":1}` + "\n")
+	worker.ExpectString(`{"status":"ok","id":1}` + "\n")
+	worker.SendString(`{"request":"get","queues":["q"]}` + "\n")
+	worker.ExpectString(`{"status":"ok","id":1,"job":null,"pri":1,"queue":"q"}` + "\n")
+
+	// Only the client working on a job can abort it.
+	other := servertest.Dial(t, "tcp", addr)
+	other.SendString(`{"request":"abort","id":1}` + "\n")
+	other.ExpectString(noJob)
+
+	worker.SendString(`{"request":"abort","id":1}` + "\n")
+	worker.ExpectString(ok)
+
+	// Aborted jobs can be deleted by anyone.
+	other.SendString(`{"request":"delete","id":1}` + "\n")
+	other.ExpectString(ok)
+	worker.SendString(`{"request":"abort","id":1}` + "\n")
+	worker.ExpectString(noJob)
+}
+
+func TestWaitForJob(t *testing.T) {
+	addr := servertest.Start(t, problem09.Problem)
+
+	worker := servertest.Dial(t, "tcp", addr)
+	worker.SendString(`{"request":"get","queues":["later"],"wait":true}` + "\n")
+	worker.ExpectNothing(200 * time.Millisecond)
+
+	producer := servertest.Dial(t, "tcp", addr)
+	producer.SendString(`{"request":"put","queue":"later","job":"x","pri":0}` + "\n")
+	producer.ExpectString(`{"status":"ok","id":1}` + "\n")
+
+	worker.ExpectString(`{"status":"ok","id":1,"job":"x","pri":0,"queue":"later"}` + "\n")
+}
+
+func TestInvalidRequests(t *testing.T) {
+	addr := servertest.Start(t, problem09.Problem)
+
+	requests := []string{
+		`{"request":"bogus"}`,
+		`{"queue":"q","job":{},"pri":1}`,
+		`{"request":"put","job":{},"pri":1}`,
+		`{"request":"put","queue":"q","job":{},"pri":-1}`,
+		`{"request":"get","queues":[null]}`,
+		`{"request":"put"`,
+		`not json`,
+	}
+
+	for _, request := range requests {
+		t.Run(request, func(t *testing.T) {
+			conn := servertest.Dial(t, "tcp", addr)
+			conn.SendString(request + "\n")
+			conn.ExpectString(invalid)
+		})
+	}
+}
+
+func TestMetricsPerServer(t *testing.T) {
+	manager := func(server string) *problem09.JobManager {
+		return &problem09.JobManager{
+			Server:     server,
+			Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+			Jobs:       make(map[problem09.JobID]*problem09.Job),
+			Queues:     make(map[string]*problem09.PriorityQueue[*problem09.Job]),
+			InProgress: make(map[problem09.ClientID][]*problem09.Job),
+		}
+	}
+	a, b := manager("jobs-a"), manager("jobs-b")
+
+	series := []string{
+		`protohackers_jobs_queued{server="jobs-a",queue="queue"}`,
+		`protohackers_jobs_queued{server="jobs-b",queue="queue"}`,
+		`protohackers_jobs_in_progress{server="jobs-a"}`,
+		`protohackers_jobs_in_progress{server="jobs-b"}`,
+	}
+	before := make(map[string]int)
+	for _, s := range series {
+		before[s] = metric(t, s)
+	}
+	expect := func(want ...int) {
+		t.Helper()
+		for i, s := range series {
+			if got := metric(t, s) - before[s]; got != want[i] {
+				t.Errorf("%s changed by %d, want %d", s, got, want[i])
+			}
+		}
+	}
+
+	a.AddJob("queue", 1, json.RawMessage("1"))
+	a.AddJob("queue", 2, json.RawMessage("2"))
+	b.AddJob("queue", 1, json.RawMessage("1"))
+	a.GetJob(1, []string{"queue"})
+
+	// Each manager's jobs are counted separately.
+	expect(1, 1, 1, 0)
+
+	a.OnClientDisconnect(1)
+	expect(2, 1, 0, 0)
+}
+
+// metric returns the value of the series in DefaultRegistry, or 0 if it
+// doesn't exist yet.
+func metric(t *testing.T, series string) int {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := internal.DefaultRegistry.Write(&buf); err != nil {
+		t.Fatalf("error writing metrics: %v", err)
+	}
+
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if value, found := strings.CutPrefix(line, series+" "); found {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				t.Fatalf("malformed metric: %q", line)
+			}
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,145 @@
+package problem10_test
+
+import (
+	"fmt"
+	"github.com/bbeck/protohackers/internal/problem10"
+	"github.com/bbeck/protohackers/internal/servertest"
+	"regexp"
+	"testing"
+)
+
+func dial(t *testing.T, addr string) *servertest.Conn {
+	t.Helper()
+
+	conn := servertest.Dial(t, "tcp", addr)
+	conn.ExpectString("READY\n")
+	return conn
+}
+
+func TestRevisions(t *testing.T) {
+	addr := servertest.Start(t, problem10.Problem)
+	conn := dial(t, addr)
+
+	conn.SendString("PUT /test.txt 6\nhello\n")
+	conn.ExpectString("OK r1\nREADY\n")
+
+	conn.SendString("PUT /test.txt 6\nworld\n")
+	conn.ExpectString("OK r2\nREADY\n")
+
+	// Storing the same contents again doesn't create a new revision.
+	conn.SendString("PUT /test.txt 6\nworld\n")
+	conn.ExpectString("OK r2\nREADY\n")
+
+	conn.SendString("GET /test.txt\n")
+	conn.ExpectString("OK 6\nworld\nREADY\n")
+
+	conn.SendString("GET /test.txt r1\n")
+	conn.ExpectString("OK 6\nhello\nREADY\n")
+
+	conn.SendString("GET /test.txt 2\n")
+	conn.ExpectString("OK 6\nworld\nREADY\n")
+
+	conn.SendString("GET /test.txt r3\n")
+	conn.ExpectString("ERR no such revision\nREADY\n")
+
+	conn.SendString("GET /other.txt\n")
+	conn.ExpectString("ERR no such revision\nREADY\n")
+}
+
+func TestRevisionsAreShared(t *testing.T) {
+	addr := servertest.Start(t, problem10.Problem)
+	a := dial(t, addr)
+	b := dial(t, addr)
+
+	a.SendString("PUT /shared 3\nabc")
+	a.ExpectString("OK r1\nREADY\n")
+
+	b.SendString("put /shared 3\ndef")
+	b.ExpectString("OK r2\nREADY\n")
+
+	a.SendString("get /shared\n")
+	a.ExpectString("OK 3\ndefREADY\n")
+}
+
+func TestList(t *testing.T) {
+	addr := servertest.Start(t, problem10.Problem)
+	conn := dial(t, addr)
+
+	conn.SendString("PUT /a/b.txt 1\nx")
+	conn.ExpectString("OK r1\nREADY\n")
+	conn.SendString("PUT /a/b.txt 1\ny")
+	conn.ExpectString("OK r2\nREADY\n")
+	conn.SendString("PUT /a/c/d.txt 1\nz")
+	conn.ExpectString("OK r1\nREADY\n")
+	conn.SendString("PUT /a.txt 1\nw")
+	conn.ExpectString("OK r1\nREADY\n")
+
+	conn.SendString("LIST /\n")
+	conn.ExpectString("OK 2\na/ DIR\na.txt r1\nREADY\n")
+
+	conn.SendString("LIST /a\n")
+	conn.ExpectString("OK 2\nb.txt r2\nc/ DIR\nREADY\n")
+
+	conn.SendString("LIST /a/\n")
+	conn.ExpectString("OK 2\nb.txt r2\nc/ DIR\nREADY\n")
+
+	conn.SendString("LIST /nothing\n")
+	conn.ExpectString("OK 0\nREADY\n")
+}
+
+func TestErrors(t *testing.T) {
+	addr := servertest.Start(t, problem10.Problem)
+	conn := dial(t, addr)
+
+	tests := []struct {
+		name     string
+		request  string
+		response string
+	}{
+		{"help", "HELP\n", "OK usage: HELP|GET|PUT|LIST\n"},
+		{"unknown method", "DELETE /a\n", "ERR illegal method: DELETE\n"},
+		{"get usage", "GET\n", "ERR usage: GET file [revision]\n"},
+		{"put usage", "PUT /a\n", "ERR usage: PUT file length newline data\n"},
+		{"list usage", "LIST\n", "ERR usage: LIST dir\n"},
+		{"relative file name", "GET a.txt\n", "ERR illegal file name\n"},
+		{"directory as file name", "GET /a/\n", "ERR illegal file name\n"},
+		{"illegal characters", "PUT /a*b 1\n", "ERR illegal file name\n"},
+		{"double slash", "GET /a//b\n", "ERR illegal file name\n"},
+		{"illegal dir name", "LIST a\n", "ERR illegal dir name\n"},
+		{"binary payload", "PUT /bin 2\n\x00\x01", "ERR illegal payload\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn.SendString(test.request)
+			conn.ExpectString(test.response + "READY\n")
+		})
+	}
+}
+
+func TestConcurrentPuts(t *testing.T) {
+	addr := servertest.Start(t, problem10.Problem)
+
+	// Every client pipelines its puts so that they're handled at the same time
+	// as the other clients'.
+	var conns []*servertest.Conn
+	for i := 0; i < 8; i++ {
+		conn := dial(t, addr)
+		for j := 0; j < 20; j++ {
+			conn.SendString(fmt.Sprintf("PUT /shared 4\n%d-%02d", i, j))
+		}
+		conns = append(conns, conn)
+	}
+
+	for _, conn := range conns {
+		for j := 0; j < 20; j++ {
+			conn.ExpectLine(regexp.MustCompile(`^OK r\d+$`))
+			conn.ExpectString("READY\n")
+		}
+	}
+
+	// Every put stored different contents, so each made a revision.
+	conn := dial(t, addr)
+	conn.SendString("LIST /\n")
+	conn.ExpectString("OK 1\nshared r160\nREADY\n")
+}
@@ -1,18 +1,33 @@
-package main
+package problem11
 
 import (
+	"errors"
+	"github.com/bbeck/protohackers/internal"
+	"log/slog"
 	"net"
 	"sync"
 )
 
+var (
+	AuthorityConnections = internal.NewGauge("protohackers_pestcontrol_authority_connections", "Number of open connections to the authority server.")
+	PolicyChanges        = internal.NewCounterVec("protohackers_pestcontrol_policy_changes_total", "Number of policies created and deleted.", "change")
+)
+
+// ErrAuthoritiesClosed is returned when an authority is needed after the
+// authorities have been closed.
+var ErrAuthoritiesClosed = errors.New("authorities closed")
+
 type Authorities struct {
 	sync.Mutex
-	Cache map[uint32]*Authority
+	Cache  map[uint32]*Authority
+	Closed bool
+	Logger *slog.Logger
 }
 
-func NewAuthorities() *Authorities {
+func NewAuthorities(logger *slog.Logger) *Authorities {
 	return &Authorities{
-		Cache: make(map[uint32]*Authority),
+		Cache:  make(map[uint32]*Authority),
+		Logger: logger,
 	}
 }
 
@@ -20,20 +35,42 @@ func (a *Authorities) GetAuthority(site uint32) (*Authority, error) {
 	a.Lock()
 	defer a.Unlock()
 
+	if a.Closed {
+		return nil, ErrAuthoritiesClosed
+	}
 	if authority := a.Cache[site]; authority != nil {
 		return authority, nil
 	}
 
-	authority, err := NewAuthority(site)
+	authority, err := NewAuthority(site, a.Logger.With("site", site))
 	if err != nil {
 		return nil, err
 	}
 
 	a.Cache[site] = authority
+	AuthorityConnections.Inc()
+	authority.Logger.Debug("authority connected", "targets", len(authority.Targets))
 	return authority, nil
 }
 
-const AuthorityAddress = "pestcontrol.protohackers.com:20547"
+// Close closes the connection to every authority and stops handling their site
+// visits.
+func (a *Authorities) Close() {
+	a.Lock()
+	defer a.Unlock()
+
+	for site, authority := range a.Cache {
+		authority.Close()
+		delete(a.Cache, site)
+		AuthorityConnections.Dec()
+		authority.Logger.Debug("authority disconnected")
+	}
+	a.Closed = true
+}
+
+// AuthorityAddress is the address of the authority server that policies are
+// created and deleted on.
+var AuthorityAddress = "pestcontrol.protohackers.com:20547"
 
 type Authority struct {
 	Channel    chan *SiteVisit
@@ -41,12 +78,13 @@ type Authority struct {
 	Targets    map[Species]Target
 	Actions    map[Species]Action
 	PolicyIDs  map[Species]uint32
+	Done       chan struct{} // Closed when the authority is closed
+	Logger     *slog.Logger
 }
 
-func NewAuthority(site uint32) (*Authority, error) {
+func NewAuthority(site uint32, logger *slog.Logger) (*Authority, error) {
 	conn, err := net.Dial("tcp", AuthorityAddress)
 	if err != nil {
-		_ = conn.Close()
 		return nil, err
 	}
 
@@ -82,19 +120,38 @@ func NewAuthority(site uint32) (*Authority, error) {
 		Targets:    target.Populations,
 		Actions:    make(map[Species]Action),
 		PolicyIDs:  make(map[Species]uint32),
+		Done:       make(chan struct{}),
+		Logger:     logger,
 	}
 
 	go func(ch chan *SiteVisit) {
 		for {
-			authority.HandleRequest(<-ch)
+			select {
+			case sv := <-ch:
+				authority.HandleRequest(sv)
+			case <-authority.Done:
+				return
+			}
 		}
 	}(authority.Channel)
 
 	return authority, nil
 }
 
+// HandleSiteVisit queues a site visit to be reconciled with the authority's
+// policies, it's dropped if the authority has been closed.
 func (a *Authority) HandleSiteVisit(sv *SiteVisit) {
-	a.Channel <- sv
+	select {
+	case a.Channel <- sv:
+	case <-a.Done:
+	}
+}
+
+// Close closes the connection to the authority, which interrupts any request
+// that's in progress, and stops handling site visits.
+func (a *Authority) Close() {
+	close(a.Done)
+	_ = a.Connection.Close()
 }
 
 func (a *Authority) HandleRequest(sv *SiteVisit) {
@@ -143,6 +200,8 @@ func (a *Authority) SetPolicy(species Species, action Action) error {
 			return err
 		}
 
+		a.Logger.Debug("policy deleted", "policy", id, "species", species, "action", a.Actions[species])
+		PolicyChanges.With("deleted").Inc()
 		delete(a.Actions, species)
 		delete(a.PolicyIDs, species)
 	}
@@ -161,6 +220,8 @@ func (a *Authority) SetPolicy(species Species, action Action) error {
 
 		a.Actions[species] = action
 		a.PolicyIDs[species] = m.Policy
+		a.Logger.Debug("policy created", "policy", m.Policy, "species", species, "action", action)
+		PolicyChanges.With("created").Inc()
 	}
 
 	return nil
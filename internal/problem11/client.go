@@ -1,4 +1,4 @@
-package main
+package problem11
 
 import (
 	"bytes"
@@ -0,0 +1,48 @@
+package problem11
+
+import (
+	"context"
+	"github.com/bbeck/protohackers/internal"
+	"io"
+	"net"
+)
+
+// Problem is the Pest Control problem.
+var Problem = internal.Problem{ID: 11, Name: "Pest Control", Run: Run}
+
+// Run runs the Pest Control server until ctx is cancelled.
+func Run(ctx context.Context, server *internal.Server) error {
+	authorities := NewAuthorities(server.Log())
+	server.RegisterOnShutdown(authorities.Close)
+
+	return server.ServeTCP(ctx, func(conn net.Conn) {
+		defer func(c io.Closer) { _ = c.Close() }(conn)
+		logger := internal.Logger(conn)
+
+		if err := WriteMessage(conn, HelloMessage{}); err != nil {
+			return
+		}
+
+		if _, err := ReadMessage[HelloMessage](conn); err != nil {
+			_ = WriteMessage(conn, Error{Message: err.Error()})
+			return
+		}
+
+		for {
+			m, err := ReadMessage[SiteVisit](conn)
+			if err != nil {
+				_ = WriteMessage(conn, Error{Message: err.Error()})
+				return
+			}
+			logger.Debug("site visited", "site", m.Site, "species", len(m.Populations))
+
+			a, err := authorities.GetAuthority(m.Site)
+			if err != nil {
+				logger.Error("error connecting to authority", "site", m.Site, "error", err)
+				return
+			}
+
+			a.HandleSiteVisit(m)
+		}
+	})
+}
@@ -0,0 +1,338 @@
+package problem11_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"github.com/bbeck/protohackers/internal/problem11"
+	"github.com/bbeck/protohackers/internal/servertest"
+	"io"
+	"log/slog"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hello is the Hello message from the spec, which every client and server
+// sends first.
+var hello = []byte{
+	0x50,
+	0x00, 0x00, 0x00, 0x19,
+	0x00, 0x00, 0x00, 0x0b, 0x70, 0x65, 0x73, 0x74, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c,
+	0x00, 0x00, 0x00, 0x01,
+	0xce,
+}
+
+// message frames a payload made up of fields, which are either strings, uint32
+// or uint8 values.
+func message(kind byte, fields ...any) []byte {
+	var payload []byte
+	for _, field := range fields {
+		switch f := field.(type) {
+		case string:
+			payload = binary.BigEndian.AppendUint32(payload, uint32(len(f)))
+			payload = append(payload, f...)
+		case uint32:
+			payload = binary.BigEndian.AppendUint32(payload, f)
+		case int:
+			payload = binary.BigEndian.AppendUint32(payload, uint32(f))
+		case uint8:
+			payload = append(payload, f)
+		}
+	}
+
+	bs := []byte{kind}
+	bs = binary.BigEndian.AppendUint32(bs, uint32(len(payload)+6))
+	bs = append(bs, payload...)
+
+	var sum byte
+	for _, b := range bs {
+		sum += b
+	}
+	return append(bs, -sum)
+}
+
+// readMessage reads a single framed message and returns its type and payload.
+func readMessage(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+
+	rest := make([]byte, binary.BigEndian.Uint32(header[1:])-5)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return 0, nil, err
+	}
+
+	return header[0], rest[:len(rest)-1], nil
+}
+
+// authority is a fake authority server that records the policy changes it's
+// asked to make as "create SPECIES ACTION" and "delete ID", and records
+// "disconnect" when the server closes a connection to it.
+type authority struct {
+	addr    string
+	targets map[uint32][]any // The TargetPopulations fields for each site
+	events  chan string
+}
+
+func startAuthority(t *testing.T, targets map[uint32][]any) *authority {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+
+	a := &authority{
+		addr:    listener.Addr().String(),
+		targets: targets,
+		events:  make(chan string, 100),
+	}
+
+	previous := problem11.AuthorityAddress
+	problem11.AuthorityAddress = a.addr
+	t.Cleanup(func() { problem11.AuthorityAddress = previous })
+
+	// Connections are closed when the listener is, so that the server's
+	// authority clients don't outlive the test.
+	var wg sync.WaitGroup
+	conns := make(chan net.Conn, 100)
+	t.Cleanup(func() {
+		_ = listener.Close()
+		wg.Wait()
+		close(conns)
+		for conn := range conns {
+			_ = conn.Close()
+		}
+	})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+			go a.serve(conn)
+		}
+	}()
+
+	return a
+}
+
+func (a *authority) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	var policy uint32
+
+	for {
+		kind, payload, err := readMessage(r)
+		if err == io.EOF {
+			a.events <- "disconnect"
+		}
+		if err != nil {
+			return
+		}
+
+		switch kind {
+		case 0x50: // Hello
+			_, _ = conn.Write(hello)
+
+		case 0x53: // DialAuthority
+			site := binary.BigEndian.Uint32(payload)
+			a.events <- fmt.Sprintf("dial %d", site)
+			_, _ = conn.Write(message(0x54, append([]any{site}, a.targets[site]...)...))
+
+		case 0x55: // CreatePolicy
+			n := binary.BigEndian.Uint32(payload)
+			species, action := string(payload[4:4+n]), payload[4+n]
+			policy++
+			a.events <- fmt.Sprintf("create %s %x", species, action)
+			_, _ = conn.Write(message(0x57, policy))
+
+		case 0x56: // DeletePolicy
+			a.events <- fmt.Sprintf("delete %d", binary.BigEndian.Uint32(payload))
+			_, _ = conn.Write(message(0x52))
+		}
+	}
+}
+
+// expect checks that the authority receives exactly the events in any order,
+// and nothing else.
+func (a *authority) expect(t *testing.T, want ...string) {
+	t.Helper()
+
+	var got []string
+	timeout := time.After(servertest.Timeout)
+	for len(got) < len(want) {
+		select {
+		case event := <-a.events:
+			got = append(got, event)
+		case <-timeout:
+			t.Fatalf("timed out waiting for events, want %q, got %q", want, got)
+		}
+	}
+
+	select {
+	case event := <-a.events:
+		got = append(got, event)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	sort.Strings(got)
+	sort.Strings(want)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected events\nwant: %q\n got: %q", want, got)
+	}
+}
+
+func dial(t *testing.T, addr string) *servertest.Conn {
+	t.Helper()
+
+	conn := servertest.Dial(t, "tcp", addr)
+	conn.Expect(hello)
+	conn.Send(hello)
+	return conn
+}
+
+func TestPolicyReconciliation(t *testing.T) {
+	a := startAuthority(t, map[uint32][]any{
+		12345: {2, "dog", 1, 3, "rat", 0, 10},
+	})
+	addr := servertest.Start(t, problem11.Problem)
+	conn := dial(t, addr)
+
+	// Too many dogs, the right number of rats, and cats aren't controlled.
+	conn.Send(message(0x58, 12345, 3, "dog", 5, "rat", 0, "cat", 100))
+	a.expect(t, "dial 12345", "create dog 90")
+
+	// Too few dogs, the cull policy is replaced by a conserve policy.
+	conn.Send(message(0x58, 12345, 1, "rat", 10))
+	a.expect(t, "delete 1", "create dog a0")
+
+	// The right number of dogs, no policy is needed.
+	conn.Send(message(0x58, 12345, 1, "dog", 2))
+	a.expect(t, "delete 2")
+
+	// Nothing has changed, so there's nothing to do.
+	conn.Send(message(0x58, 12345, 1, "dog", 3))
+	a.expect(t)
+
+	// Too many rats, and duplicate species with the same count are allowed.
+	conn.Send(message(0x58, 12345, 3, "dog", 1, "rat", 11, "rat", 11))
+	a.expect(t, "create rat 90")
+}
+
+func TestAuthorityPerSite(t *testing.T) {
+	a := startAuthority(t, map[uint32][]any{
+		1: {1, "fox", 0, 0},
+		2: {1, "fox", 5, 5},
+	})
+	addr := servertest.Start(t, problem11.Problem)
+
+	// Site visits from different clients for the same site share an authority.
+	dial(t, addr).Send(message(0x58, 1, 1, "fox", 1))
+	a.expect(t, "dial 1", "create fox 90")
+	dial(t, addr).Send(message(0x58, 1, 1, "fox", 0))
+	a.expect(t, "delete 1")
+
+	dial(t, addr).Send(message(0x58, 2, 0))
+	a.expect(t, "dial 2", "create fox a0")
+}
+
+func TestAuthoritiesClosedOnShutdown(t *testing.T) {
+	a := startAuthority(t, map[uint32][]any{
+		1: {1, "fox", 0, 0},
+		2: {1, "fox", 5, 5},
+	})
+
+	connections := problem11.AuthorityConnections.Value()
+	t.Run("serve", func(t *testing.T) {
+		addr := servertest.Start(t, problem11.Problem)
+		conn := dial(t, addr)
+		conn.Send(message(0x58, 1, 1, "fox", 1))
+		conn.Send(message(0x58, 2, 0))
+		a.expect(t, "dial 1", "create fox 90", "dial 2", "create fox a0")
+
+		if n := problem11.AuthorityConnections.Value() - connections; n != 2 {
+			t.Errorf("%d authority connections opened, want 2", n)
+		}
+	})
+
+	// The server stopped at the end of the subtest.
+	a.expect(t, "disconnect", "disconnect")
+	if n := problem11.AuthorityConnections.Value() - connections; n != 0 {
+		t.Errorf("%d authority connections open after the server stopped, want 0", n)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	startAuthority(t, nil)
+	addr := servertest.Start(t, problem11.Problem)
+
+	tests := []struct {
+		name    string
+		message []byte
+	}{
+		{"bad checksum", append(message(0x58, 1, 0)[:13], 0x00)},
+		{"conflicting counts", message(0x58, 1, 2, "dog", 1, "dog", 2)},
+		{"trailing bytes", message(0x58, 1, 0, 0)},
+		{"unexpected type", message(0x52)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn := dial(t, addr)
+			conn.Send(test.message)
+
+			kind, _, err := readMessage(conn)
+			if err != nil {
+				t.Fatalf("error reading response: %v", err)
+			}
+			if kind != 0x51 {
+				t.Fatalf("unexpected response type, want 0x51, got %#02x", kind)
+			}
+			conn.ExpectClosed()
+		})
+	}
+
+	t.Run("bad hello", func(t *testing.T) {
+		conn := servertest.Dial(t, "tcp", addr)
+		conn.Expect(hello)
+		conn.Send(message(0x50, "pestcontrol", 2))
+
+		kind, _, err := readMessage(conn)
+		if err != nil || kind != 0x51 {
+			t.Fatalf("unexpected response, want an error, got %#02x (error: %v)", kind, err)
+		}
+		conn.ExpectClosed()
+	})
+}
+
+func TestUnreachableAuthority(t *testing.T) {
+	// Nothing is listening on the address once the listener is closed.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	_ = l.Close()
+
+	previous := problem11.AuthorityAddress
+	problem11.AuthorityAddress = l.Addr().String()
+	t.Cleanup(func() { problem11.AuthorityAddress = previous })
+
+	if _, err := problem11.NewAuthority(1, slog.Default()); err == nil {
+		t.Fatal("NewAuthority() succeeded, want an error")
+	}
+
+	// The client whose site visit needed the authority is disconnected, and the
+	// server carries on.
+	addr := servertest.Start(t, problem11.Problem)
+	conn := dial(t, addr)
+	conn.Send(message(0x58, 1, 0))
+	conn.ExpectClosed()
+	dial(t, addr)
+}
@@ -0,0 +1,211 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProxyHeaderTimeout limits how long a load balancer may take to send the
+// PROXY protocol header after connecting.
+const ProxyHeaderTimeout = 10 * time.Second
+
+// proxySignature is the signature that every version 2 PROXY header starts
+// with.
+var proxySignature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// ReadProxyHeader reads a version 1 or version 2 PROXY protocol header from
+// conn and returns a connection whose RemoteAddr and LocalAddr are the
+// addresses of the original client and server from the header.  If the header
+// doesn't describe a proxied TCP connection, e.g. it's a health check from the
+// load balancer itself, the returned connection reports conn's own addresses.
+//
+// The header must be read in full before deadline or an error is returned.
+func ReadProxyHeader(conn net.Conn, deadline time.Time) (net.Conn, error) {
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	pc := &proxyConn{
+		Conn:   conn,
+		reader: bufio.NewReader(conn),
+		remote: conn.RemoteAddr(),
+		local:  conn.LocalAddr(),
+	}
+
+	first, err := pc.reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch first[0] {
+	case 'P':
+		err = pc.readV1()
+	case '\r':
+		err = pc.readV2()
+	default:
+		err = errors.New("missing PROXY header")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+
+	return pc, nil
+}
+
+// proxyConn is a connection that was accepted from a load balancer that
+// described the original connection in a PROXY protocol header.
+type proxyConn struct {
+	net.Conn
+
+	// The reader holds any bytes that were read from the connection after the
+	// PROXY header.
+	reader *bufio.Reader
+
+	remote net.Addr
+	local  net.Addr
+}
+
+func (c *proxyConn) Read(bs []byte) (int, error) {
+	return c.reader.Read(bs)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *proxyConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// readV1 reads a human-readable version 1 header, for example:
+//
+//	PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n
+func (c *proxyConn) readV1() error {
+	// The longest possible header is 107 bytes, including the CRLF.
+	var line []byte
+	for len(line) < 107 {
+		b, err := c.reader.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		line = append(line, b)
+		if bytes.HasSuffix(line, []byte("\r\n")) {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return errors.New("PROXY v1 header too long")
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return errors.New("malformed PROXY v1 header")
+	}
+
+	if fields[1] == "UNKNOWN" {
+		return nil
+	}
+
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return fmt.Errorf("malformed PROXY v1 header: %q", line)
+	}
+
+	src, err := parseTCPAddr(fields[2], fields[4])
+	if err != nil {
+		return err
+	}
+
+	dst, err := parseTCPAddr(fields[3], fields[5])
+	if err != nil {
+		return err
+	}
+
+	c.remote, c.local = src, dst
+	return nil
+}
+
+func parseTCPAddr(host, port string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("malformed PROXY v1 address: %q", host)
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("malformed PROXY v1 port: %q", port)
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(p)}, nil
+}
+
+// readV2 reads a binary version 2 header.
+func (c *proxyConn) readV2() error {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(header[:12], proxySignature) {
+		return errors.New("malformed PROXY v2 signature")
+	}
+
+	version, command := header[12]>>4, header[12]&0x0F
+	if version != 2 {
+		return fmt.Errorf("unsupported PROXY version: %d", version)
+	}
+
+	family := header[13]
+	length := binary.BigEndian.Uint16(header[14:16])
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return err
+	}
+
+	switch command {
+	case 0x0: // LOCAL, the connection was made by the load balancer itself
+		return nil
+	case 0x1: // PROXY
+	default:
+		return fmt.Errorf("unsupported PROXY v2 command: %d", command)
+	}
+
+	// Only TCP over IPv4 and IPv6 is of interest, for any other family the
+	// addresses are ignored.  Any TLVs following the addresses are ignored.
+	var size int
+	switch family {
+	case 0x11: // TCP over IPv4
+		size = net.IPv4len
+	case 0x21: // TCP over IPv6
+		size = net.IPv6len
+	default:
+		return nil
+	}
+
+	if len(payload) < 2*size+4 {
+		return errors.New("PROXY v2 address block too short")
+	}
+
+	c.remote = &net.TCPAddr{
+		IP:   net.IP(payload[:size]),
+		Port: int(binary.BigEndian.Uint16(payload[2*size:])),
+	}
+	c.local = &net.TCPAddr{
+		IP:   net.IP(payload[size : 2*size]),
+		Port: int(binary.BigEndian.Uint16(payload[2*size+2:])),
+	}
+	return nil
+}
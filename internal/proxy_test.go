@@ -0,0 +1,206 @@
+package internal_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"github.com/bbeck/protohackers/internal"
+	"github.com/bbeck/protohackers/internal/servertest"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// proxyV2 builds a version 2 PROXY header with the given command, address
+// family and payload.
+func proxyV2(command, family byte, payload []byte) []byte {
+	header := []byte("\r\n\r\n\x00\r\nQUIT\n")
+	header = append(header, 0x20|command, family)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(payload)))
+	return append(header, payload...)
+}
+
+// addresses builds the address block of a version 2 header.
+func addresses(src, dst string, srcPort, dstPort uint16) []byte {
+	var block []byte
+	for _, ip := range []net.IP{net.ParseIP(src), net.ParseIP(dst)} {
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		block = append(block, ip...)
+	}
+	block = binary.BigEndian.AppendUint16(block, srcPort)
+	return binary.BigEndian.AppendUint16(block, dstPort)
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	tcp4 := addresses("192.0.2.1", "198.51.100.1", 56324, 443)
+	tcp6 := addresses("2001:db8::1", "2001:db8::2", 56324, 443)
+
+	tests := []struct {
+		name   string
+		header string
+		remote string // Empty if the connection's own addresses are kept
+		local  string
+		err    bool
+	}{
+		// Version 1
+		{name: "v1 tcp4", header: "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n", remote: "192.0.2.1:56324", local: "198.51.100.1:443"},
+		{name: "v1 tcp6", header: "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n", remote: "[2001:db8::1]:56324", local: "[2001:db8::2]:443"},
+		{name: "v1 tcp6 longest", header: "PROXY TCP6 ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff 65535 65535\r\n", remote: "[ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff]:65535", local: "[ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff]:65535"},
+		{name: "v1 unknown", header: "PROXY UNKNOWN\r\n"},
+		{name: "v1 unknown with addresses", header: "PROXY UNKNOWN 192.0.2.1 198.51.100.1 56324 443\r\n"},
+		{name: "v1 too long", header: "PROXY TCP4 " + strings.Repeat("1", 200) + "\r\n", err: true},
+		{name: "v1 missing crlf", header: "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\n", err: true},
+		{name: "v1 not proxy", header: "PROXI TCP4 192.0.2.1 198.51.100.1 56324 443\r\n", err: true},
+		{name: "v1 missing protocol", header: "PROXY\r\n", err: true},
+		{name: "v1 unsupported protocol", header: "PROXY UDP4 192.0.2.1 198.51.100.1 56324 443\r\n", err: true},
+		{name: "v1 missing port", header: "PROXY TCP4 192.0.2.1 198.51.100.1 56324\r\n", err: true},
+		{name: "v1 extra field", header: "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443 80\r\n", err: true},
+		{name: "v1 double space", header: "PROXY TCP4  192.0.2.1 198.51.100.1 56324 443\r\n", err: true},
+		{name: "v1 malformed source", header: "PROXY TCP4 192.0.2 198.51.100.1 56324 443\r\n", err: true},
+		{name: "v1 malformed destination", header: "PROXY TCP4 192.0.2.1 example.com 56324 443\r\n", err: true},
+		{name: "v1 port too large", header: "PROXY TCP4 192.0.2.1 198.51.100.1 65536 443\r\n", err: true},
+		{name: "v1 negative port", header: "PROXY TCP4 192.0.2.1 198.51.100.1 56324 -1\r\n", err: true},
+		{name: "v1 named port", header: "PROXY TCP4 192.0.2.1 198.51.100.1 56324 https\r\n", err: true},
+		{name: "v1 truncated", header: "PROXY TCP4 192.0.2.1", err: true},
+
+		// Version 2
+		{name: "v2 proxy tcp4", header: string(proxyV2(0x1, 0x11, tcp4)), remote: "192.0.2.1:56324", local: "198.51.100.1:443"},
+		{name: "v2 proxy tcp6", header: string(proxyV2(0x1, 0x21, tcp6)), remote: "[2001:db8::1]:56324", local: "[2001:db8::2]:443"},
+		{name: "v2 proxy with tlvs", header: string(proxyV2(0x1, 0x11, append(tcp4, 0x04, 0x00, 0x01, 0xff))), remote: "192.0.2.1:56324", local: "198.51.100.1:443"},
+		{name: "v2 proxy unspec", header: string(proxyV2(0x1, 0x00, nil))},
+		{name: "v2 proxy udp", header: string(proxyV2(0x1, 0x12, tcp4))},
+		{name: "v2 proxy unix", header: string(proxyV2(0x1, 0x31, make([]byte, 216)))},
+		{name: "v2 local", header: string(proxyV2(0x0, 0x00, nil))},
+		{name: "v2 local with addresses", header: string(proxyV2(0x0, 0x11, tcp4))},
+		{name: "v2 truncated tcp4 block", header: string(proxyV2(0x1, 0x11, tcp4[:11])), err: true},
+		{name: "v2 tcp4 block for tcp6", header: string(proxyV2(0x1, 0x21, tcp4)), err: true},
+		{name: "v2 truncated payload", header: string(proxyV2(0x1, 0x11, tcp4))[:20], err: true},
+		{name: "v2 truncated header", header: string(proxyV2(0x1, 0x11, tcp4))[:14], err: true},
+		{name: "v2 bad signature", header: "\r\n\r\n\x00\r\nQUIZ\n\x21\x11\x00\x0c" + string(tcp4), err: true},
+		{name: "v2 version 1", header: "\r\n\r\n\x00\r\nQUIT\n\x11\x11\x00\x0c" + string(tcp4), err: true},
+		{name: "v2 unsupported command", header: string(proxyV2(0x2, 0x11, tcp4)), err: true},
+
+		{name: "missing header", header: "GET / HTTP/1.0\r\n", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The client's data follows the header, and must still be readable
+			// once the header is parsed.
+			client, server := net.Pipe()
+			t.Cleanup(func() { _ = client.Close(); _ = server.Close() })
+			go func() {
+				_, _ = client.Write([]byte(test.header + "hello"))
+				_ = client.Close()
+			}()
+
+			conn, err := internal.ReadProxyHeader(server, time.Now().Add(time.Second))
+			if test.err {
+				if err == nil {
+					t.Fatalf("ReadProxyHeader() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadProxyHeader() error: %v", err)
+			}
+
+			remote, local := test.remote, test.local
+			if remote == "" {
+				remote, local = server.RemoteAddr().String(), server.LocalAddr().String()
+			}
+			if got := conn.RemoteAddr().String(); got != remote {
+				t.Errorf("RemoteAddr() = %s, want %s", got, remote)
+			}
+			if got := conn.LocalAddr().String(); got != local {
+				t.Errorf("LocalAddr() = %s, want %s", got, local)
+			}
+
+			data, err := io.ReadAll(conn)
+			if err != nil || string(data) != "hello" {
+				t.Errorf("read %q after the header (error: %v), want %q", data, err, "hello")
+			}
+		})
+	}
+}
+
+func TestReadProxyHeaderDeadline(t *testing.T) {
+	client, server := net.Pipe()
+	t.Cleanup(func() { _ = client.Close(); _ = server.Close() })
+
+	// Half a header arrives, and the rest never does.
+	go func() { _, _ = client.Write([]byte("PROXY TCP4 ")) }()
+
+	_, err := internal.ReadProxyHeader(server, time.Now().Add(50*time.Millisecond))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("ReadProxyHeader() error = %v, want a deadline error", err)
+	}
+}
+
+func TestProxyProtocolServer(t *testing.T) {
+	addr := serve(t, func(conn net.Conn) {
+		defer conn.Close()
+
+		_, _ = io.WriteString(conn, conn.RemoteAddr().String()+"\n")
+		_, _ = io.Copy(conn, conn)
+	}, func(config *internal.Config) {
+		config.ProxyProtocol = true
+	})
+
+	// The handler sees the client from the header, and the data after it.
+	conn := servertest.Dial(t, "tcp", addr)
+	conn.SendString("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\nhello")
+	conn.ExpectString("192.0.2.1:56324\nhello")
+
+	conn = servertest.Dial(t, "tcp", addr)
+	conn.Send(proxyV2(0x0, 0x00, nil))
+	conn.SendString("hello")
+	conn.ExpectString(conn.LocalAddr().String() + "\nhello")
+
+	// A connection without a header is closed without being handled.
+	conn = servertest.Dial(t, "tcp", addr)
+	conn.SendString("hello\n")
+	conn.ExpectClosed()
+}
+
+func TestProxyProtocolShutdown(t *testing.T) {
+	listening := make(chan net.Addr, 1)
+	server := &internal.Server{
+		Config: internal.Config{
+			Host:            "127.0.0.1",
+			ProxyProtocol:   true,
+			ShutdownTimeout: time.Minute,
+		},
+		Logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		OnListen: func(addr net.Addr) { listening <- addr },
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- server.ServeTCP(ctx, echo) }()
+
+	// Half a header arrives, and the rest never does.
+	conn := servertest.Dial(t, "tcp", (<-listening).String())
+	conn.SendString("PROXY TCP4 ")
+	time.Sleep(100 * time.Millisecond)
+
+	// The server doesn't wait for the header before shutting down.
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("ServeTCP() error = %v, want nil", err)
+		}
+	case <-time.After(servertest.Timeout):
+		t.Fatal("timed out waiting for the server to shut down")
+	}
+	conn.ExpectClosed()
+}
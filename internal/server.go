@@ -1,55 +1,544 @@
 package internal
 
 import (
-	"log"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
 	"net"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"sync"
+	"syscall"
+	"time"
 )
 
-func RunTCPServer(handler func(conn net.Conn)) {
-	addr, err := net.ResolveTCPAddr("tcp", "0.0.0.0:40000")
+// DefaultShutdownTimeout is how long a Server waits for in-flight handlers to
+// finish after its context is cancelled when no ShutdownTimeout is configured.
+const DefaultShutdownTimeout = 10 * time.Second
+
+// ErrShutdownTimeout is returned by a Server when its handlers did not finish
+// within the shutdown timeout.  When this happens any connections that are
+// still open are forcibly closed.
+var ErrShutdownTimeout = errors.New("timed out waiting for handlers to finish")
+
+// These metrics are labeled with the name of the server they belong to.
+var (
+	connectionsAccepted = NewCounterVec("protohackers_connections_accepted_total", "Number of TCP connections accepted.", "server")
+	connectionsRejected = NewCounterVec("protohackers_connections_rejected_total", "Number of TCP connections rejected because the server was full.", "server")
+	connectionsActive   = NewGaugeVec("protohackers_connections_active", "Number of TCP connections currently open.", "server")
+	datagramsReceived   = NewCounterVec("protohackers_datagrams_received_total", "Number of UDP datagrams received.", "server")
+	bytesReceived       = NewCounterVec("protohackers_received_bytes_total", "Number of bytes received from clients.", "server")
+	bytesSent           = NewCounterVec("protohackers_sent_bytes_total", "Number of bytes sent to clients.", "server")
+	handlerPanics       = NewCounterVec("protohackers_handler_panics_total", "Number of times a handler panicked.", "server")
+)
+
+// Server runs a protocol handler until its context is cancelled.  The zero
+// value for Server is ready to use and listens on an ephemeral port.
+type Server struct {
+	Config
+
+	// Name identifies the server in its metrics.  If empty the address that the
+	// server is listening on is used.
+	Name string
+
+	// Logger is used to log the server's activity and is the parent of every
+	// connection's logger.  If nil the default logger is used.
+	Logger *slog.Logger
+
+	// OnListen, if set, is called with the address the server is listening on
+	// once it is ready to accept connections or datagrams.  This is useful to
+	// discover the port that was chosen when listening on port 0.
+	OnListen func(addr net.Addr)
+
+	metrics    metrics
+	mutex      sync.Mutex
+	conns      map[*conn]struct{}
+	closing    bool
+	onShutdown []func()
+	handlers   sync.WaitGroup
+}
+
+// RegisterOnShutdown registers a function to call when the server begins
+// shutting down.  These functions are called after the server has stopped
+// accepting new connections or datagrams, but before any connections or the
+// UDP socket are closed, so they may still send data to clients.  A UDP server
+// calls them once its workers have handled every queued datagram.
+func (s *Server) RegisterOnShutdown(f func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.onShutdown = append(s.onShutdown, f)
+}
+
+// ServeTCP listens for TCP connections and runs handler in its own goroutine
+// for each one.  The connection given to the handler enforces the configured
+// timeouts on every read and write.  If the PROXY protocol is enabled the
+// connection reports the client address from the PROXY header, and if TLS is
+// enabled the handshake is completed before the handler is run so that the
+// handler reads and writes plaintext.
+//
+// When ctx is cancelled the server stops accepting connections, interrupts any
+// reads that are blocked on open connections and waits for the handlers to
+// return.
+func (s *Server) ServeTCP(ctx context.Context, handler func(conn net.Conn)) error {
+	tlsConfig, err := s.TLSConfig()
 	if err != nil {
-		log.Fatalf("error resolving TCP address: %v", err)
+		return err
 	}
 
-	listener, err := net.ListenTCP("tcp", addr)
+	listener, err := net.Listen(s.TCPNetwork(), s.Address())
 	if err != nil {
-		log.Fatalf("error listening for TCP connections: %v", err)
+		return fmt.Errorf("error listening for TCP connections: %w", err)
+	}
+	s.listening(listener.Addr())
+
+	stop := s.closeOnDone(ctx, listener.Close)
+	defer stop()
+
+	// Each open connection holds a slot, when there are no slots left new
+	// connections are either rejected or left waiting in the listen queue.
+	var slots chan struct{}
+	if s.MaxConnections > 0 {
+		slots = make(chan struct{}, s.MaxConnections)
+	}
+	queue := s.ConnectionPolicy == QueuePolicy
+
+	release := func() {
+		if slots != nil {
+			<-slots
+		}
 	}
 
 	for {
-		conn, err := listener.Accept()
+		if slots != nil && queue {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+			}
+		}
+
+		c, err := listener.Accept()
+		if ctx.Err() != nil {
+			// A connection accepted as the server stops is never handled.
+			if err == nil {
+				_ = c.Close()
+			}
+			break
+		}
+		if errors.Is(err, net.ErrClosed) {
+			_ = s.shutdown()
+			return err
+		}
 		if err != nil {
-			log.Printf("error accepting connection from %v: %v", conn.RemoteAddr(), err)
+			s.Log().Error("error accepting connection", "error", err)
+			if queue {
+				release()
+			}
+			time.Sleep(10 * time.Millisecond)
 			continue
 		}
 
-		go handler(conn)
+		if slots != nil && !queue {
+			select {
+			case slots <- struct{}{}:
+			default:
+				s.metrics.connectionsRejected.Inc()
+				s.Log().Warn("connection rejected", "remote_addr", c.RemoteAddr().String(), "reason", "too many connections")
+				_ = c.Close()
+				continue
+			}
+		}
+
+		s.handlers.Add(1)
+		go func() {
+			defer s.handlers.Done()
+			defer release()
+
+			s.serveConn(ctx, c, tlsConfig, handler)
+		}()
 	}
+
+	return s.shutdown()
 }
 
-func RunUDPServer(handler func(net.Addr, []byte, func([]byte))) {
-	addr, err := net.ResolveUDPAddr("udp", "0.0.0.0:40000")
+// serveConn prepares a newly accepted connection and runs handler with it.
+func (s *Server) serveConn(ctx context.Context, c net.Conn, tlsConfig *tls.Config, handler func(conn net.Conn)) {
+	if s.ProxyProtocol {
+		// The connection isn't tracked until it's set up, so if the server starts
+		// shutting down while the header is being read it's closed here instead.
+		stop := s.closeOnDone(ctx, c.Close)
+		pc, err := ReadProxyHeader(c, time.Now().Add(ProxyHeaderTimeout))
+		stop()
+		if err != nil {
+			if ctx.Err() == nil {
+				s.Log().Warn("invalid PROXY header", "remote_addr", c.RemoteAddr().String(), "error", err)
+			}
+			_ = c.Close()
+			return
+		}
+		c = pc
+	}
+
+	if tlsConfig != nil {
+		c = tls.Server(c, tlsConfig)
+	}
+
+	conn := newConn(c, &s.Config, s.Log(), &s.metrics)
+	if s.CaptureDir != "" {
+		s.capture(conn)
+		if conn.capture != nil {
+			defer conn.capture.close()
+		}
+	}
+	if !s.track(conn) {
+		// The server started shutting down while the connection was being set up.
+		_ = conn.Close()
+		return
+	}
+	defer s.untrack(conn)
+
+	conn.logger.Info("connection accepted")
+	s.metrics.connectionsAccepted.Inc()
+	s.metrics.connectionsActive.Inc()
+	defer s.metrics.connectionsActive.Dec()
+
+	defer func() {
+		// A panic only affects the connection whose handler panicked, every other
+		// connection carries on.
+		if r := recover(); r != nil {
+			s.panicked(conn.logger, r)
+		}
+
+		_ = conn.Close()
+		conn.logger.Info("connection closed", "duration", time.Since(conn.started))
+	}()
+
+	if tc, ok := conn.Conn.(*tls.Conn); ok {
+		if err := handshake(ctx, tc); err != nil {
+			conn.logger.Warn("TLS handshake failed", "error", err)
+			return
+		}
+
+		state := tc.ConnectionState()
+		conn.logger.Debug("TLS handshake complete", "version", tls.VersionName(state.Version), "client_certificates", len(state.PeerCertificates))
+	}
+
+	handler(conn)
+}
+
+// capture starts recording a connection's traffic in CaptureDir.  If the
+// capture file can't be created the error is logged and the connection is
+// served without being recorded.
+func (s *Server) capture(conn *conn) {
+	name := s.Name
+	if name == "" {
+		name = conn.LocalAddr().String()
+	}
+
+	capture, err := openCapture(s.CaptureDir, CaptureHeader{
+		Server:     name,
+		ConnID:     conn.id,
+		RemoteAddr: conn.RemoteAddr().String(),
+		LocalAddr:  conn.LocalAddr().String(),
+		Time:       conn.started,
+	})
 	if err != nil {
-		log.Fatalf("error resolving UDP address: %v", err)
+		conn.logger.Warn("error capturing traffic", "error", err)
+		return
 	}
 
-	conn, err := net.ListenUDP("udp", addr)
+	conn.capture = capture
+}
+
+// MaxDatagramSize is the size of the buffers that UDP datagrams are received
+// into.  It's large enough to hold any UDP datagram.
+const MaxDatagramSize = 64 * 1024
+
+// buffers holds the buffers that UDP datagrams are received into so that they
+// can be reused once a handler is finished with them.
+var buffers = sync.Pool{
+	New: func() any {
+		buffer := make([]byte, MaxDatagramSize)
+		return &buffer
+	},
+}
+
+// datagram is a UDP datagram waiting to be handled by a worker.
+type datagram struct {
+	addr   net.Addr
+	buffer *[]byte
+	n      int
+}
+
+// ServeUDP listens for UDP datagrams and runs handler for each one that is
+// received on a pool of UDPWorkers goroutines.  The handler is given a function
+// that can be used to send a reply back to the address that the datagram came
+// from.  The datagram's bytes are only valid until the handler returns, after
+// which they're reused for another datagram.  If OrderBySource is set all
+// datagrams from the same address are handled by the same worker in the order
+// they were received.
+//
+// When ctx is cancelled the server stops reading datagrams, waits for the
+// workers to handle any that are queued, runs any shutdown functions and then
+// closes the socket.
+func (s *Server) ServeUDP(ctx context.Context, handler func(addr net.Addr, bs []byte, reply func([]byte) error)) error {
+	conn, err := net.ListenPacket(s.UDPNetwork(), s.Address())
 	if err != nil {
-		log.Fatalf("error listening for UDP connections: %v", err)
+		return fmt.Errorf("error listening for UDP datagrams: %w", err)
 	}
 	defer conn.Close()
+	s.listening(conn.LocalAddr())
 
-	for err == nil {
-		buffer := make([]byte, 1024*1024)
-		n, addr, err := conn.ReadFrom(buffer)
+	// Interrupt the read instead of closing the socket so that shutdown functions
+	// are still able to send datagrams.
+	stop := s.closeOnDone(ctx, func() error {
+		return conn.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	workers := s.UDPWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	// When ordering by source each worker has its own queue and every address
+	// is assigned to a single queue, otherwise the workers share one queue.
+	var queues []chan datagram
+	if s.OrderBySource {
+		for i := 0; i < workers; i++ {
+			queues = append(queues, make(chan datagram, 16))
+		}
+	} else {
+		queues = append(queues, make(chan datagram, 16*workers))
+	}
+
+	for i := 0; i < workers; i++ {
+		s.handlers.Add(1)
+		go func(queue chan datagram) {
+			defer s.handlers.Done()
+
+			for d := range queue {
+				s.handleDatagram(conn, d, handler)
+			}
+		}(queues[i%len(queues)])
+	}
+
+	for {
+		buffer := buffers.Get().(*[]byte)
+		n, addr, err := conn.ReadFrom(*buffer)
+		if ctx.Err() != nil {
+			break
+		}
 		if err != nil {
-			return
+			err = fmt.Errorf("error reading UDP datagram: %w", err)
+			for _, queue := range queues {
+				close(queue)
+			}
+			_ = s.wait()
+			s.runOnShutdown()
+			return err
+		}
+		s.metrics.datagramsReceived.Inc()
+		s.metrics.bytesReceived.Add(n)
+
+		queue := queues[0]
+		if len(queues) > 1 {
+			hash := fnv.New32a()
+			_, _ = io.WriteString(hash, addr.String())
+			queue = queues[hash.Sum32()%uint32(len(queues))]
+		}
+		queue <- datagram{addr: addr, buffer: buffer, n: n}
+	}
+
+	// The workers finish the queued datagrams before the shutdown functions run,
+	// so that the functions don't race with them.
+	for _, queue := range queues {
+		close(queue)
+	}
+	err = s.wait()
+	s.runOnShutdown()
+	return err
+}
+
+// handleDatagram runs handler for a single datagram.  If the handler panics
+// the panic is logged and the datagram is dropped, but the worker carries on.
+func (s *Server) handleDatagram(conn net.PacketConn, d datagram, handler func(net.Addr, []byte, func([]byte) error)) {
+	logger := s.Log().With("remote_addr", d.addr.String())
+	logger.Debug("datagram received", "bytes", d.n)
+
+	defer buffers.Put(d.buffer)
+	defer func() {
+		if r := recover(); r != nil {
+			s.panicked(logger, r)
+		}
+	}()
+
+	handler(
+		d.addr,
+		(*d.buffer)[:d.n],
+		func(bs []byte) error {
+			n, err := conn.WriteTo(bs, d.addr)
+			s.metrics.bytesSent.Add(n)
+			return err
+		},
+	)
+}
+
+// panicked records that a handler panicked with value r.
+func (s *Server) panicked(logger *slog.Logger, r any) {
+	s.metrics.handlerPanics.Inc()
+	logger.Error("handler panicked", "panic", r, "stack", string(debug.Stack()))
+}
+
+// Log returns the server's Logger, or the default logger if it's nil.  It's for
+// activity that isn't tied to a single TCP connection, which should be logged
+// with the connection's logger from Logger instead.
+func (s *Server) Log() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.Default()
+}
+
+func (s *Server) listening(addr net.Addr) {
+	s.Log().Info("listening", "network", addr.Network(), "addr", addr.String())
+
+	name := s.Name
+	if name == "" {
+		name = addr.String()
+	}
+	s.metrics = metrics{
+		connectionsAccepted: connectionsAccepted.With(name),
+		connectionsRejected: connectionsRejected.With(name),
+		connectionsActive:   connectionsActive.With(name),
+		datagramsReceived:   datagramsReceived.With(name),
+		bytesReceived:       bytesReceived.With(name),
+		bytesSent:           bytesSent.With(name),
+		handlerPanics:       handlerPanics.With(name),
+	}
+
+	if s.OnListen != nil {
+		s.OnListen(addr)
+	}
+}
+
+// metrics holds a server's own series of each of the server metrics.
+type metrics struct {
+	connectionsAccepted *Counter
+	connectionsRejected *Counter
+	connectionsActive   *Gauge
+	datagramsReceived   *Counter
+	bytesReceived       *Counter
+	bytesSent           *Counter
+	handlerPanics       *Counter
+}
+
+// closeOnDone arranges for fn to be called once ctx is cancelled.  The
+// returned function must be called to release the resources associated with
+// the arrangement.
+func (s *Server) closeOnDone(ctx context.Context, fn func() error) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = fn()
+		case <-done:
 		}
+	}()
+
+	return func() { close(done) }
+}
+
+// track records that a connection is open so that it can be interrupted when
+// the server shuts down.  It returns false if the server is already shutting
+// down, in which case the connection shouldn't be used.
+func (s *Server) track(c *conn) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closing {
+		return false
+	}
 
-		handler(
-			addr,
-			buffer[:n],
-			func(bs []byte) { _, err = conn.WriteTo(bs, addr) },
-		)
+	if s.conns == nil {
+		s.conns = make(map[*conn]struct{})
 	}
+	s.conns[c] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(c *conn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.conns, c)
+}
+
+// shutdown runs the registered shutdown functions and then waits for all
+// in-flight handlers to finish.  Handlers blocked reading from a connection are
+// interrupted so that they notice the shutdown.  If they don't finish within
+// the shutdown timeout their connections are closed.
+func (s *Server) shutdown() error {
+	s.runOnShutdown()
+
+	s.mutex.Lock()
+	s.closing = true
+	for conn := range s.conns {
+		conn.interrupt()
+	}
+	s.mutex.Unlock()
+
+	return s.wait()
+}
+
+// runOnShutdown calls the registered shutdown functions.
+func (s *Server) runOnShutdown() {
+	s.mutex.Lock()
+	onShutdown := s.onShutdown
+	s.mutex.Unlock()
+
+	for _, f := range onShutdown {
+		f()
+	}
+}
+
+// wait waits for all in-flight handlers to finish.  If they don't finish within
+// the shutdown timeout any open connections are closed.
+func (s *Server) wait() error {
+	done := make(chan struct{})
+	go func() {
+		s.handlers.Wait()
+		close(done)
+	}()
+
+	timeout := s.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+
+	select {
+	case <-done:
+		return nil
+
+	case <-time.After(timeout):
+		s.mutex.Lock()
+		for conn := range s.conns {
+			_ = conn.Close()
+		}
+		s.mutex.Unlock()
+
+		return ErrShutdownTimeout
+	}
+}
+
+// SignalContext returns a context that is cancelled when the process receives
+// SIGINT or SIGTERM.  The returned stop function restores the default signal
+// behavior.
+func SignalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 }
@@ -0,0 +1,115 @@
+package internal_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"github.com/bbeck/protohackers/internal"
+	"github.com/bbeck/protohackers/internal/servertest"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// serve runs a TCP server that handles each connection with handler until the
+// test finishes, and returns the address it's listening on.
+func serve(t *testing.T, handler func(conn net.Conn), options ...func(*internal.Config)) string {
+	t.Helper()
+
+	problem := internal.Problem{
+		ID:   99,
+		Name: "Test",
+		Run: func(ctx context.Context, server *internal.Server) error {
+			return server.ServeTCP(ctx, handler)
+		},
+	}
+	return servertest.Start(t, problem, options...)
+}
+
+// echo is a handler that echoes each line it reads back to the client, and
+// panics if the line is "panic".
+func echo(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		if scanner.Text() == "panic" {
+			panic("asked to panic")
+		}
+		_, _ = io.WriteString(conn, scanner.Text()+"\n")
+	}
+}
+
+func TestHandlerPanic(t *testing.T) {
+	panics := metric(t, `protohackers_handler_panics_total{server="problem-99"}`)
+	addr := serve(t, echo)
+
+	alice := servertest.Dial(t, "tcp", addr)
+	bob := servertest.Dial(t, "tcp", addr)
+	alice.SendString("hello\n")
+	alice.ExpectString("hello\n")
+
+	// Only the connection whose handler panicked is closed.
+	bob.SendString("panic\n")
+	bob.ExpectClosed()
+
+	alice.SendString("still here\n")
+	alice.ExpectString("still here\n")
+
+	// And the server keeps accepting connections.
+	carol := servertest.Dial(t, "tcp", addr)
+	carol.SendString("hello\n")
+	carol.ExpectString("hello\n")
+
+	if n := metric(t, `protohackers_handler_panics_total{server="problem-99"}`) - panics; n != 1 {
+		t.Errorf("%d handler panics counted, want 1", n)
+	}
+}
+
+// metric returns the value of the series in DefaultRegistry, or 0 if it
+// doesn't exist yet.
+func metric(t *testing.T, series string) int {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := internal.DefaultRegistry.Write(&buf); err != nil {
+		t.Fatalf("error writing metrics: %v", err)
+	}
+
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if value, found := strings.CutPrefix(line, series+" "); found {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				t.Fatalf("malformed metric: %q", line)
+			}
+			return n
+		}
+	}
+	return 0
+}
+
+func TestUDPHandlerPanic(t *testing.T) {
+	problem := internal.Problem{
+		ID:   98,
+		Name: "Test UDP",
+		Run: func(ctx context.Context, server *internal.Server) error {
+			return server.ServeUDP(ctx, func(addr net.Addr, bs []byte, reply func([]byte) error) {
+				if string(bs) == "panic" {
+					panic("asked to panic")
+				}
+				_ = reply(bs)
+			})
+		},
+	}
+	addr := servertest.Start(t, problem)
+
+	// The worker that panicked carries on handling datagrams.
+	conn := servertest.Dial(t, "udp", addr)
+	conn.SendString("panic")
+	for i := 0; i < 10; i++ {
+		conn.SendString("hello")
+		conn.ExpectDatagram("hello")
+	}
+}
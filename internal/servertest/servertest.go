@@ -0,0 +1,200 @@
+// Package servertest runs problem servers in-process for tests and provides
+// connections to them that fail the test when the server doesn't respond with
+// exactly the expected bytes.
+package servertest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"github.com/bbeck/protohackers/internal"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Timeout limits how long to wait for a server to start or respond before the
+// test fails.
+var Timeout = 5 * time.Second
+
+// Start runs the problem's server on an ephemeral loopback port until the test
+// finishes and returns the address it's listening on.  The options are applied
+// to the server's configuration before it's started.
+func Start(t testing.TB, problem internal.Problem, options ...func(*internal.Config)) string {
+	t.Helper()
+
+	config := internal.DefaultConfig()
+	config.Host = "127.0.0.1"
+	config.Port = 0
+	config.ShutdownTimeout = time.Second
+	for _, option := range options {
+		option(&config)
+	}
+
+	listening := make(chan net.Addr, 1)
+	server := &internal.Server{
+		Config:   config,
+		Name:     problem.Slug(),
+		Logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		OnListen: func(addr net.Addr) { listening <- addr },
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- problem.Run(ctx, server) }()
+
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("error stopping %s server: %v", problem.Slug(), err)
+		}
+	})
+
+	select {
+	case addr := <-listening:
+		return addr.String()
+	case err := <-done:
+		done <- err
+		t.Fatalf("%s server stopped before listening: %v", problem.Slug(), err)
+	case <-time.After(Timeout):
+		t.Fatalf("timed out waiting for %s server to listen", problem.Slug())
+	}
+	return ""
+}
+
+// Conn is a connection to a server under test.  Every method fails the test if
+// the server doesn't behave as expected.
+type Conn struct {
+	net.Conn
+
+	t      testing.TB
+	reader *bufio.Reader
+}
+
+// Dial connects to the server at addr over network, either "tcp" or "udp".  The
+// connection is closed when the test finishes.
+func Dial(t testing.TB, network, addr string) *Conn {
+	t.Helper()
+
+	conn, err := net.DialTimeout(network, addr, Timeout)
+	if err != nil {
+		t.Fatalf("error connecting to %s: %v", addr, err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return &Conn{Conn: conn, t: t, reader: bufio.NewReader(conn)}
+}
+
+// Read reads from the server, any bytes already buffered by an Expect method
+// are returned first.
+func (c *Conn) Read(bs []byte) (int, error) {
+	return c.reader.Read(bs)
+}
+
+// Send writes bs to the server, over UDP it's sent as a single datagram.
+func (c *Conn) Send(bs []byte) {
+	c.t.Helper()
+
+	if _, err := c.Write(bs); err != nil {
+		c.t.Fatalf("error sending %q: %v", bs, err)
+	}
+}
+
+// SendString writes s to the server.
+func (c *Conn) SendString(s string) {
+	c.t.Helper()
+	c.Send([]byte(s))
+}
+
+// Expect reads len(want) bytes from the server and checks that they're want.
+func (c *Conn) Expect(want []byte) {
+	c.t.Helper()
+
+	got := make([]byte, len(want))
+	_ = c.SetReadDeadline(time.Now().Add(Timeout))
+	n, err := io.ReadFull(c.reader, got)
+	if err != nil {
+		c.t.Fatalf("error reading response, want %q, got %q: %v", want, got[:n], err)
+	}
+
+	if !bytes.Equal(got, want) {
+		c.t.Fatalf("unexpected response\nwant: %q\n got: %q", want, got)
+	}
+}
+
+// ExpectString reads len(want) bytes from the server and checks that they're
+// want.
+func (c *Conn) ExpectString(want string) {
+	c.t.Helper()
+	c.Expect([]byte(want))
+}
+
+// ExpectLine reads a line from the server and checks that it matches pattern,
+// for responses that aren't the same every time.  The line's newline isn't
+// matched against pattern.
+func (c *Conn) ExpectLine(pattern *regexp.Regexp) {
+	c.t.Helper()
+
+	_ = c.SetReadDeadline(time.Now().Add(Timeout))
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("error reading line, want a match for %q, got %q: %v", pattern, line, err)
+	}
+
+	if got := strings.TrimSuffix(line, "\n"); !pattern.MatchString(got) {
+		c.t.Fatalf("unexpected line\nwant: %q\n got: %q", pattern, got)
+	}
+}
+
+// ExpectDatagram reads a single datagram from the server and checks that it's
+// want.
+func (c *Conn) ExpectDatagram(want string) {
+	c.t.Helper()
+
+	buf := make([]byte, internal.MaxDatagramSize)
+	_ = c.SetReadDeadline(time.Now().Add(Timeout))
+	n, err := c.Conn.Read(buf)
+	if err != nil {
+		c.t.Fatalf("error reading datagram, want %q: %v", want, err)
+	}
+
+	if got := string(buf[:n]); got != want {
+		c.t.Fatalf("unexpected datagram\nwant: %q\n got: %q", want, got)
+	}
+}
+
+// ExpectClosed checks that the server closes the connection without sending
+// anything else.
+func (c *Conn) ExpectClosed() {
+	c.t.Helper()
+
+	_ = c.SetReadDeadline(time.Now().Add(Timeout))
+	bs, err := io.ReadAll(c.reader)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		c.t.Fatalf("timed out waiting for connection to close, got %q", bs)
+	}
+	if len(bs) > 0 {
+		c.t.Fatalf("unexpected data before close: %q", bs)
+	}
+}
+
+// ExpectNothing checks that the server sends nothing for d.
+func (c *Conn) ExpectNothing(d time.Duration) {
+	c.t.Helper()
+
+	buf := make([]byte, internal.MaxDatagramSize)
+	_ = c.SetReadDeadline(time.Now().Add(d))
+	n, err := c.reader.Read(buf)
+	if n > 0 {
+		c.t.Fatalf("unexpected data: %q", buf[:n])
+	}
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		c.t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,261 @@
+package internal_test
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"github.com/bbeck/protohackers/internal"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// authority is a certificate authority that issues certificates for tests.
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+	pem  []byte
+}
+
+func newAuthority(t *testing.T, name string) *authority {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("error creating CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("error parsing CA certificate: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return &authority{
+		cert: cert,
+		key:  key,
+		pool: pool,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue returns a certificate signed by the authority for either a server on
+// the loopback address or a client.
+func (a *authority) issue(t *testing.T, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// writeFile writes contents to a file in dir, returning its path.
+func writeFile(t *testing.T, dir, name string, contents []byte) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, contents, 0o600); err != nil {
+		t.Fatalf("error writing %s: %v", name, err)
+	}
+	return path
+}
+
+// writeKeyPair writes a certificate and its key as PEM files in dir, returning
+// their paths.
+func writeKeyPair(t *testing.T, dir string, cert tls.Certificate) (string, string) {
+	t.Helper()
+
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatalf("error marshalling key: %v", err)
+	}
+
+	certFile := writeFile(t, dir, "cert.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}))
+	keyFile := writeFile(t, dir, "key.pem", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}))
+	return certFile, keyFile
+}
+
+// roundTrip connects to the echo server at addr over TLS and checks that a line
+// is echoed back.  It returns the error from the handshake or the exchange.
+func roundTrip(addr string, config *tls.Config) error {
+	dialer := &tls.Dialer{NetDialer: &net.Dialer{Timeout: time.Second}, Config: config}
+	conn, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// With TLS 1.3 the server checks the client's certificate after the client
+	// has finished its side of the handshake, so a rejected client only finds
+	// out when it reads.
+	_ = conn.SetDeadline(time.Now().Add(time.Second))
+	if _, err := io.WriteString(conn, "hello\n"); err != nil {
+		return err
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if line != "hello\n" {
+		return fmt.Errorf("unexpected response: %q", line)
+	}
+	return nil
+}
+
+func TestTLSKeyPair(t *testing.T) {
+	ca := newAuthority(t, "server CA")
+	certFile, keyFile := writeKeyPair(t, t.TempDir(), ca.issue(t, x509.ExtKeyUsageServerAuth))
+
+	addr := serve(t, echo, func(config *internal.Config) {
+		config.TLSCertFile = certFile
+		config.TLSKeyFile = keyFile
+	})
+
+	if err := roundTrip(addr, &tls.Config{RootCAs: ca.pool}); err != nil {
+		t.Errorf("error talking to server: %v", err)
+	}
+
+	// A client that doesn't trust the server's CA can't connect.
+	other := newAuthority(t, "other CA")
+	if err := roundTrip(addr, &tls.Config{RootCAs: other.pool}); err == nil {
+		t.Error("client that doesn't trust the server connected")
+	}
+}
+
+func TestTLSSelfSigned(t *testing.T) {
+	addr := serve(t, echo, func(config *internal.Config) {
+		config.TLSSelfSigned = true
+	})
+
+	// The certificate is its own issuer and is valid for the loopback address.
+	var verified bool
+	config := &tls.Config{
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			cert := state.PeerCertificates[0]
+			roots := x509.NewCertPool()
+			roots.AddCert(cert)
+			_, err := cert.Verify(x509.VerifyOptions{Roots: roots, DNSName: "127.0.0.1"})
+			verified = err == nil
+			return err
+		},
+	}
+	if err := roundTrip(addr, config); err != nil || !verified {
+		t.Errorf("error talking to server: %v", err)
+	}
+
+	// A client that verifies certificates as normal doesn't trust it.
+	if err := roundTrip(addr, &tls.Config{}); err == nil {
+		t.Error("client trusted a self-signed certificate")
+	}
+}
+
+func TestTLSClientCA(t *testing.T) {
+	dir := t.TempDir()
+	serverCA := newAuthority(t, "server CA")
+	clientCA := newAuthority(t, "client CA")
+	certFile, keyFile := writeKeyPair(t, dir, serverCA.issue(t, x509.ExtKeyUsageServerAuth))
+	caFile := writeFile(t, dir, "client-ca.pem", clientCA.pem)
+
+	addr := serve(t, echo, func(config *internal.Config) {
+		config.TLSCertFile = certFile
+		config.TLSKeyFile = keyFile
+		config.TLSClientCAFile = caFile
+	})
+
+	tests := []struct {
+		name  string
+		certs []tls.Certificate
+		ok    bool
+	}{
+		{"certificate from client CA", []tls.Certificate{clientCA.issue(t, x509.ExtKeyUsageClientAuth)}, true},
+		{"no certificate", nil, false},
+		{"certificate from another CA", []tls.Certificate{newAuthority(t, "other CA").issue(t, x509.ExtKeyUsageClientAuth)}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := roundTrip(addr, &tls.Config{RootCAs: serverCA.pool, Certificates: test.certs})
+			if test.ok && err != nil {
+				t.Errorf("error talking to server: %v", err)
+			}
+			if !test.ok && err == nil {
+				t.Error("client was accepted, want it rejected")
+			}
+		})
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	ca := newAuthority(t, "CA")
+	certFile, keyFile := writeKeyPair(t, dir, ca.issue(t, x509.ExtKeyUsageServerAuth))
+	empty := writeFile(t, dir, "empty.pem", nil)
+
+	tests := []struct {
+		name   string
+		config internal.Config
+	}{
+		{"missing certificate", internal.Config{TLSCertFile: filepath.Join(dir, "missing.pem"), TLSKeyFile: keyFile}},
+		{"missing key", internal.Config{TLSCertFile: certFile}},
+		{"mismatched key", internal.Config{TLSCertFile: certFile, TLSKeyFile: certFile}},
+		{"missing client CA", internal.Config{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSClientCAFile: filepath.Join(dir, "missing.pem")}},
+		{"empty client CA", internal.Config{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSClientCAFile: empty}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := test.config.TLSConfig(); err == nil {
+				t.Error("TLSConfig() succeeded, want an error")
+			}
+		})
+	}
+
+	// Without a certificate TLS isn't used at all.
+	if config, err := (&internal.Config{}).TLSConfig(); config != nil || err != nil {
+		t.Errorf("TLSConfig() = %v, %v, want nil, nil", config, err)
+	}
+}